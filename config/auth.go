@@ -0,0 +1,38 @@
+package config
+
+import (
+	"time"
+
+	"github.com/joeshaw/envdecode"
+)
+
+// Auth config info. Requests must carry an API key or a bearer token, so at
+// least one of the API key file, the JWT secret and the JWT public key has to
+// be set unless auth is disabled.
+type authConfig struct {
+
+	// Disabled serves every request without authentication, only ever use
+	// it for local development
+	Disabled bool `env:"AUTH_DISABLED,default=false"`
+
+	// APIKeysFile is a JSON file listing API keys, see auth.LoadAPIKeys
+	APIKeysFile string `env:"AUTH_API_KEYS_FILE"`
+
+	// JWTSecret verifies HMAC signed tokens, JWTPublicKeyFile is a PEM file
+	// verifying RSA signed tokens
+	JWTSecret        string `env:"AUTH_JWT_SECRET"`
+	JWTPublicKeyFile string `env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+
+	// JWTIssuer and JWTAudience are required in tokens when set
+	JWTIssuer   string `env:"AUTH_JWT_ISSUER"`
+	JWTAudience string `env:"AUTH_JWT_AUDIENCE"`
+
+	// JWTLeeway tolerates clock skew when checking token expiry
+	JWTLeeway time.Duration `env:"AUTH_JWT_LEEWAY,default=1m"`
+}
+
+var Auth authConfig
+
+func init() {
+	envdecode.Decode(&Auth)
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"time"
+
+	"github.com/joeshaw/envdecode"
+)
+
+// Dishes service config info
+type dishesConfig struct {
+	DefaultPageSize int `env:"DISHES_DEFAULT_PAGE_SIZE,default=10"`
+	MaxPageSize     int `env:"DISHES_MAX_PAGE_SIZE,default=100"`
+
+	// CursorSecret signs page tokens, a random one is used when empty
+	CursorSecret string `env:"DISHES_CURSOR_SECRET"`
+
+	// IdempotencyTTL is how long idempotency keys are remembered
+	IdempotencyTTL time.Duration `env:"DISHES_IDEMPOTENCY_TTL,default=24h"`
+}
+
+var Dishes dishesConfig
+
+func init() {
+	envdecode.Decode(&Dishes)
+}
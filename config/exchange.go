@@ -0,0 +1,28 @@
+package config
+
+import (
+	"time"
+
+	"github.com/joeshaw/envdecode"
+)
+
+// Exchange rate config info
+type exchangeConfig struct {
+
+	// RatesFile is an exchange rate file, prices are only quoted in their
+	// local currencies without one
+	RatesFile string `env:"EXCHANGE_RATES_FILE"`
+
+	// ReloadEvery is how often the rates file is checked for changes
+	ReloadEvery time.Duration `env:"EXCHANGE_RATES_RELOAD_EVERY,default=1m"`
+
+	// Rounding holds rounding rules for converted prices per currency, e.g.
+	// "CHF=half_up/0.05,JPY=up/10"
+	Rounding string `env:"EXCHANGE_ROUNDING"`
+}
+
+var Exchange exchangeConfig
+
+func init() {
+	envdecode.Decode(&Exchange)
+}
@@ -0,0 +1,18 @@
+package config
+
+import "github.com/joeshaw/envdecode"
+
+// Inventory config info
+type inventoryConfig struct {
+
+	// BusinessDayEnd is the "HH:MM" the business day ends at in the
+	// restaurant's time zone, dishes 86'd by hand go back on sale then. It
+	// defaults to the early hours so late service counts as the same day.
+	BusinessDayEnd string `env:"BUSINESS_DAY_END,default=04:00"`
+}
+
+var Inventory inventoryConfig
+
+func init() {
+	envdecode.Decode(&Inventory)
+}
@@ -0,0 +1,18 @@
+package config
+
+import "github.com/joeshaw/envdecode"
+
+// Menu config info
+type menusConfig struct {
+
+	// TimeZone is the IANA name of the restaurant's time zone, menu
+	// availability windows are in its local time. Tenants may set their
+	// own, see Tenants.
+	TimeZone string `env:"RESTAURANT_TIME_ZONE,default=UTC"`
+}
+
+var Menus menusConfig
+
+func init() {
+	envdecode.Decode(&Menus)
+}
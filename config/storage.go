@@ -0,0 +1,29 @@
+package config
+
+import (
+	"github.com/joeshaw/envdecode"
+)
+
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Storage config info
+type storageConfig struct {
+
+	// Backend is either "memory" or "file"
+	Backend string `env:"STORAGE_BACKEND,default=memory"`
+
+	// Dir holds the data of the file backend
+	Dir string `env:"STORAGE_DIR,default=data"`
+
+	// SnapshotEvery is how many writes the file backend logs between snapshots
+	SnapshotEvery int `env:"STORAGE_SNAPSHOT_EVERY,default=1000"`
+}
+
+var Storage storageConfig
+
+func init() {
+	envdecode.Decode(&Storage)
+}
@@ -0,0 +1,18 @@
+package config
+
+import "github.com/joeshaw/envdecode"
+
+// Tax config info
+type taxesConfig struct {
+
+	// Jurisdiction is the ID of the jurisdiction orders are taxed in, it is
+	// set up through the /jurisdictions API. Orders are not taxed without
+	// one.
+	Jurisdiction string `env:"TAX_JURISDICTION"`
+}
+
+var Taxes taxesConfig
+
+func init() {
+	envdecode.Decode(&Taxes)
+}
@@ -0,0 +1,19 @@
+package config
+
+import "github.com/joeshaw/envdecode"
+
+// Tenant config info
+type tenantsConfig struct {
+
+	// Tenants lists the restaurants every service serves along with their
+	// currency, max page size and time zone, e.g.
+	// "bistro=EUR/50@Europe/Paris,diner=USD". Every tenant is served with
+	// the defaults when empty.
+	Tenants string `env:"TENANTS"`
+}
+
+var Tenants tenantsConfig
+
+func init() {
+	envdecode.Decode(&Tenants)
+}
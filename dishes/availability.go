@@ -0,0 +1,86 @@
+// Availability works out whether dishes can be ordered from stock levels and
+// 86s.
+package dishes
+
+import (
+	"context"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Inventory looks up stock levels and 86s, it is implemented by
+// inventory.Service
+type Inventory interface {
+	GetStock(ctx context.Context, kind models.StockKind, id string) (*models.StockLevel, error)
+	GetEightySix(ctx context.Context, dishID string) (*models.EightySix, error)
+}
+
+// WithInventory adds availability to every dish returned, and lets
+// ListDishes hide dishes that cannot be ordered. Without an inventory every
+// dish is available.
+func WithInventory(i Inventory) Option {
+	return func(r *resource) { r.inventory = i }
+}
+
+// checkAvailability sets the availability of a dish. A dish is unavailable
+// while 86'd, when its own stock is sold out, or when any ingredient of its
+// recipe has less left than a portion takes. Items without stock levels
+// never run out.
+func (r *resource) checkAvailability(ctx context.Context, d *models.Dish) error {
+	d.Availability = nil
+	if r.inventory == nil {
+		return nil
+	}
+
+	// 86'd by hand
+	e, err := r.inventory.GetEightySix(ctx, d.ID)
+	switch {
+	case err == nil:
+		until := e.Until
+		d.Availability = &models.DishAvailability{Reason: models.UnavailableEightySixed, Until: &until}
+		return nil
+	case models.KindOf(err) != models.KindNotFound:
+		return err
+	}
+
+	// Sold out
+	portion := models.Quantity{Amount: 1, Unit: models.UnitEach}
+	level, err := r.inventory.GetStock(ctx, models.StockDish, d.ID)
+	switch {
+	case err == nil && !level.Covers(portion):
+		d.Availability = &models.DishAvailability{Reason: models.UnavailableSoldOut}
+		return nil
+	case err != nil && models.KindOf(err) != models.KindNotFound:
+		return err
+	}
+
+	// Out of an ingredient
+	var out []string
+	for _, l := range d.Recipe {
+		level, err := r.inventory.GetStock(ctx, models.StockIngredient, l.IngredientID)
+		if models.KindOf(err) == models.KindNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !level.Covers(l.Quantity) {
+			out = append(out, l.IngredientID)
+		}
+	}
+	if len(out) > 0 {
+		d.Availability = &models.DishAvailability{Reason: models.UnavailableOutOfStock, OutOfStock: out}
+		return nil
+	}
+	d.Availability = &models.DishAvailability{Available: true}
+	return nil
+}
+
+// available reports if the dish can be ordered, checking its availability
+// first
+func (r *resource) available(ctx context.Context, d *models.Dish) (bool, error) {
+	if err := r.checkAvailability(ctx, d); err != nil {
+		return false, err
+	}
+	return d.Availability == nil || d.Availability.Available, nil
+}
@@ -0,0 +1,218 @@
+// Category implements menu sections grouping dishes.
+package dishes
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// checkCategory verifies a dish refers to an existing category of the
+// tenant, if any
+func (r *resource) checkCategory(tenant, id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, err := r.repo.GetCategory(tenant, id); err != nil {
+		if models.KindOf(err) == models.KindNotFound {
+			return models.InvalidArgument("invalid dish").WithField("categoryId", "unknown category")
+		}
+		return err
+	}
+	return nil
+}
+
+// categorize moves a dish between categories in the category index
+func (c *catalog) categorize(dishID, from, to string) {
+	if from == to {
+		return
+	}
+	if from != "" {
+		delete(c.categoryDishes[from], dishID)
+		if len(c.categoryDishes[from]) == 0 {
+			delete(c.categoryDishes, from)
+		}
+	}
+	if to != "" {
+		if c.categoryDishes[to] == nil {
+			c.categoryDishes[to] = make(map[string]bool)
+		}
+		c.categoryDishes[to][dishID] = true
+	}
+}
+
+func (r *resource) CreateCategory(ctx context.Context, params models.CategoryParams) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create model
+	category := models.NewCategory(params)
+	category.TenantID = c.id
+
+	// Validate
+	if err := category.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	if err := r.repo.PutCategory(*category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r *resource) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	return r.repo.GetCategory(c.id, id)
+}
+
+func (r *resource) UpdateCategory(ctx context.Context, id string, params models.CategoryParams) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	current, err := r.repo.GetCategory(c.id, id)
+	if err != nil {
+		return nil, err
+	}
+	if params.Version != nil {
+		if err := current.CheckVersion(*params.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update a copy, so a failed validation leaves the stored model alone
+	category := *current
+	if params.Name != nil {
+		category.Name = *params.Name
+	}
+	if params.Position != nil {
+		category.Position = *params.Position
+	}
+	category.Version++
+	category.Updated = time.Now()
+
+	// Validate
+	if err := category.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	if err := r.repo.PutCategory(category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *resource) DeleteCategory(ctx context.Context, id string, version int64, reassignTo string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	category, err := r.repo.GetCategory(c.id, id)
+	if err != nil {
+		return err
+	}
+	if err := category.CheckVersion(version); err != nil {
+		return err
+	}
+
+	// Validate the new home of its dishes
+	if reassignTo != "" {
+		if reassignTo == id {
+			return models.InvalidArgument("invalid reassignment").WithField("reassignTo", "cannot be the deleted category")
+		}
+		if _, err := r.repo.GetCategory(c.id, reassignTo); err != nil {
+			if models.KindOf(err) == models.KindNotFound {
+				return models.InvalidArgument("invalid reassignment").WithField("reassignTo", "unknown category")
+			}
+			return err
+		}
+	}
+
+	// Never leave dishes pointing at a deleted category
+	ids := make([]string, 0, len(c.categoryDishes[id]))
+	for dishID := range c.categoryDishes[id] {
+		ids = append(ids, dishID)
+	}
+	sort.Strings(ids)
+	if len(ids) > 0 && reassignTo == "" {
+		return models.Conflict("category still has %d dishes", len(ids)).
+			WithField("reassignTo", "required while the category has dishes")
+	}
+	for _, dishID := range ids {
+		if err := r.moveDish(c, dishID, reassignTo); err != nil {
+			return err
+		}
+	}
+
+	// Delete model
+	return r.repo.DeleteCategory(c.id, id)
+}
+
+// moveDish puts a dish of the tenant into another category, as a new version
+// of the dish
+func (r *resource) moveDish(c *catalog, id, categoryID string) error {
+	current, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return err
+	}
+	dish := *current
+	dish.CategoryID = categoryID
+	dish.Version++
+	dish.Updated = time.Now()
+	if err := r.repo.Put(dish); err != nil {
+		return err
+	}
+	c.secondaryIndex.Put(&dish)
+	c.categorize(id, current.CategoryID, categoryID)
+	return nil
+}
+
+func (r *resource) ListCategories(ctx context.Context) ([]models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := r.repo.AllCategories(c.id)
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := &categories[i], &categories[j]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return categories, nil
+}
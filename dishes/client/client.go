@@ -0,0 +1,18 @@
+// Client provides a Go client library for the dishes service.
+package client
+
+import (
+	"github.com/jeffizhungry/polygon/dishes"
+)
+
+// New returns a dishes.Service backed by the HTTP API reachable at instance,
+// e.g. "localhost:8008" or "https://dishes.example.com/api". The returned
+// service behaves like the one from dishes.NewService(), including returning
+// models.ErrNotFound for missing dishes.
+func New(instance string) (dishes.Service, error) {
+	endpoints, err := dishes.MakeClientEndpoints(instance)
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,447 @@
+//go:build integration
+// +build integration
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/jeffizhungry/polygon/dishes"
+	"github.com/jeffizhungry/polygon/ingredients"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeString(s string) *string {
+	return &s
+}
+
+func makePrice(amount string) *models.Money {
+	m := models.MustParseMoney(amount, "USD")
+	return &m
+}
+
+func TestIntegrationClientCRUD(t *testing.T) {
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(dishes.NewService())))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	// Create
+	dish, err := c.CreateDish(context.TODO(), models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dish)
+	assert.NotEmpty(t, dish.ID)
+	assert.Equal(t, "Pasta", dish.Name)
+
+	// Retried create
+	ctx := dishes.WithIdempotencyKey(context.TODO(), "create-pasta")
+	first, err := c.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	})
+	require.NoError(t, err)
+	retried, err := c.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, retried.ID)
+	require.NoError(t, c.DeleteDish(context.TODO(), first.ID, 0))
+
+	// Invalid create
+	_, err = c.CreateDish(context.TODO(), models.DishParams{
+		Price: makePrice("10"),
+	})
+	require.Error(t, err)
+	require.IsType(t, &models.Error{}, err)
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	assert.Equal(t, []models.FieldError{{Field: "name", Message: "cannot be empty string"}}, err.(*models.Error).Fields)
+
+	// Get
+	actual, err := c.GetDish(context.TODO(), dish.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, dish.ID, actual.ID)
+	assert.True(t, dish.Created.Equal(actual.Created))
+
+	// IDs stay a single path segment
+	for _, id := range []string{dish.ID + "/nutrition", dish.ID + "?currency=EUR", dish.ID + "%2F", "%" + dish.ID} {
+		_, err = c.GetDish(context.TODO(), id, "")
+		assert.Equal(t, models.ErrNotFound, err, id)
+	}
+
+	// Update
+	updated, err := c.UpdateDish(context.TODO(), dish.ID, models.DishParams{
+		Price: makePrice("20"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Pasta", updated.Name)
+	assert.Equal(t, models.MustParseMoney("20.00", "USD"), updated.Price)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// Quote
+	quoted, err := c.GetDish(context.TODO(), dish.ID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, &models.PriceQuote{Price: models.MustParseMoney("20", "USD")}, quoted.Quote)
+
+	// Stale update and delete
+	stale := int64(1)
+	_, err = c.UpdateDish(context.TODO(), dish.ID, models.DishParams{
+		Price:   makePrice("30"),
+		Version: &stale,
+	})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	err = c.DeleteDish(context.TODO(), dish.ID, stale)
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+
+	// List
+	list, next, err := c.ListDishes(context.TODO(), dishes.ListDishesQuery{
+		PageSize:   5,
+		NamePrefix: "pas",
+		MinPrice:   makePrice("15"),
+		Sort:       dishes.SortByPrice,
+		Descending: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, dish.ID, list[0].ID)
+	assert.Empty(t, next)
+
+	// Search
+	results, err := c.SearchDishes(context.TODO(), "psta", 5, dishes.DietaryFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, dish.ID, results[0].Dish.ID)
+	assert.Equal(t, "<em>Pasta</em>", results[0].Highlights[0].Value)
+
+	// Delete
+	err = c.DeleteDish(context.TODO(), dish.ID, 0)
+	require.NoError(t, err)
+
+	// Get
+	_, err = c.GetDish(context.TODO(), dish.ID, "")
+	require.Equal(t, models.ErrNotFound, err)
+
+	// Delete
+	err = c.DeleteDish(context.TODO(), dish.ID, 0)
+	require.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationClientListCurrencies(t *testing.T) {
+	s := dishes.NewService()
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(s)))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	euros := models.MustParseMoney("9", "EUR")
+	_, err = c.CreateDish(context.TODO(), models.DishParams{Name: makeString("Pasta"), Price: &euros})
+	require.NoError(t, err)
+	_, err = c.CreateDish(context.TODO(), models.DishParams{Name: makeString("Pizza"), Price: makePrice("12")})
+	require.NoError(t, err)
+
+	// A price range in another currency does not quote the dishes, over
+	// HTTP just like in process
+	for _, q := range []dishes.ListDishesQuery{
+		{MinPrice: &models.Money{Amount: 500, Currency: "EUR"}},
+		{MaxPrice: &models.Money{Amount: 1000, Currency: "EUR"}},
+	} {
+		expected, _, err := s.ListDishes(context.TODO(), q)
+		require.NoError(t, err)
+		require.Len(t, expected, 1)
+		actual, _, err := c.ListDishes(context.TODO(), q)
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Equal(t, expected[0].ID, actual[0].ID)
+		assert.Nil(t, actual[0].Quote)
+	}
+
+	// Quoting is asked for separately
+	page, _, err := c.ListDishes(context.TODO(), dishes.ListDishesQuery{
+		Currency: "EUR",
+		MinPrice: &models.Money{Amount: 500, Currency: "EUR"},
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.NotNil(t, page[0].Quote)
+	assert.Equal(t, euros, page[0].Quote.Price)
+}
+
+func TestIntegrationClientCategories(t *testing.T) {
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(dishes.NewService())))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	// Create
+	starters, err := c.CreateCategory(context.TODO(), models.CategoryParams{Name: makeString("Starters")})
+	require.NoError(t, err)
+	mains, err := c.CreateCategory(context.TODO(), models.CategoryParams{Name: makeString("Mains")})
+	require.NoError(t, err)
+	dish, err := c.CreateDish(context.TODO(), models.DishParams{
+		Name:       makeString("Soup"),
+		Price:      makePrice("6"),
+		CategoryID: &starters.ID,
+		Tags:       &[]string{"vegan", "hot"},
+	})
+	require.NoError(t, err)
+
+	// List
+	categories, err := c.ListCategories(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, categories, 2)
+	list, _, err := c.ListDishes(context.TODO(), dishes.ListDishesQuery{
+		CategoryID: starters.ID,
+		Tags:       []string{"vegan", "hot"},
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, dish.ID, list[0].ID)
+
+	// Update
+	stale := int64(5)
+	_, err = c.UpdateCategory(context.TODO(), mains.ID, models.CategoryParams{Name: makeString("Main courses"), Version: &stale})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	updated, err := c.UpdateCategory(context.TODO(), mains.ID, models.CategoryParams{Name: makeString("Main courses")})
+	require.NoError(t, err)
+	assert.Equal(t, "Main courses", updated.Name)
+
+	// Delete
+	err = c.DeleteCategory(context.TODO(), starters.ID, 0, "")
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	require.NoError(t, c.DeleteCategory(context.TODO(), starters.ID, starters.Version, mains.ID))
+	_, err = c.GetCategory(context.TODO(), starters.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+	moved, err := c.GetDish(context.TODO(), dish.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, mains.ID, moved.CategoryID)
+}
+
+func TestIntegrationClientPriceDish(t *testing.T) {
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(dishes.NewService())))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	dish, err := c.CreateDish(context.TODO(), models.DishParams{
+		Name:  makeString("Coffee"),
+		Price: makePrice("3"),
+		ModifierGroups: &[]models.ModifierGroup{{
+			ID: "milk", Name: "Milk", Required: true, Max: 1,
+			Modifiers: []models.Modifier{
+				{ID: "whole", Name: "Whole", PriceDelta: *makePrice("0")},
+				{ID: "oat", Name: "Oat", PriceDelta: *makePrice("0.60")},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, dish.ModifierGroups, 1)
+
+	// Price
+	breakdown, err := c.PriceDish(context.TODO(), dish.ID, models.Configuration{
+		Quantity:  3,
+		Modifiers: []models.ModifierChoice{{GroupID: "milk", ModifierID: "oat"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, breakdown.Lines, 2)
+	assert.Equal(t, "3.60 USD", breakdown.UnitPrice.String())
+	assert.Equal(t, "10.80 USD", breakdown.Total.String())
+
+	// Invalid configuration
+	_, err = c.PriceDish(context.TODO(), dish.ID, models.Configuration{})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	_, err = c.PriceDish(context.TODO(), "missing", models.Configuration{})
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationClientNutritionLabel(t *testing.T) {
+	pantry := ingredients.NewService()
+	service := dishes.NewService(dishes.WithIngredients(pantry))
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(service)))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	kg := models.UnitKilogram
+	rice, err := pantry.CreateIngredient(context.TODO(), models.IngredientParams{
+		Name:      makeString("Rice"),
+		Cost:      makePrice("2"),
+		Unit:      &kg,
+		Nutrition: &models.Nutrition{Calories: 130, Fat: 0.3, Carbohydrates: 28, Protein: 2.7, Sodium: 1},
+	})
+	require.NoError(t, err)
+	dish, err := c.CreateDish(context.TODO(), models.DishParams{
+		Name:   makeString("Rice"),
+		Price:  makePrice("3"),
+		Recipe: &[]models.RecipeLine{{IngredientID: rice.ID, Quantity: models.Quantity{Amount: 200, Unit: models.UnitGram}}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dish.Nutrition)
+
+	// JSON
+	label, err := c.NutritionLabel(context.TODO(), dish.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "200 g", label.ServingSize)
+	assert.Equal(t, 260.0, label.Calories)
+
+	// Plain text
+	resp, err := http.Get(server.URL + "/dishes/" + dish.ID + "/nutrition?format=text")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, label.Text(), string(body))
+
+	_, err = c.NutritionLabel(context.TODO(), "missing")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationClientTenants(t *testing.T) {
+	server := httptest.NewServer(dishes.MakeHTTPHandler(dishes.MakeServerEndpoints(dishes.NewService())))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	// The tenant travels in the X-Tenant-ID header
+	bistro := tenant.NewContext(context.TODO(), "bistro")
+	dish, err := c.CreateDish(bistro, models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bistro", dish.TenantID)
+	_, err = c.GetDish(bistro, dish.ID, "")
+	require.NoError(t, err)
+	_, err = c.GetDish(context.TODO(), dish.ID, "")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationClientAuth(t *testing.T) {
+	key, stored := auth.NewAPIKey("kitchen", "", auth.RoleAdmin)
+	authenticator := auth.NewAuthenticator(auth.WithAPIKeys([]auth.APIKey{stored}))
+	endpoints := dishes.MakeServerEndpoints(dishes.NewService()).Wrap(auth.Middleware)
+	server := httptest.NewServer(dishes.MakeHTTPHandler(endpoints, httptransport.ServerBefore(authenticator.ToContext)))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	params := models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	}
+
+	// Anonymous calls are turned away
+	_, err = c.CreateDish(context.TODO(), params)
+	assert.Equal(t, auth.ErrMissingCredentials, err)
+	_, err = c.CreateDish(auth.WithAPIKey(context.TODO(), key+"x"), params)
+	assert.Equal(t, models.KindUnauthenticated, models.KindOf(err))
+	resp, err := http.Get(server.URL + "/dishes")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+
+	// The client sends the key along
+	ctx := auth.WithAPIKey(context.TODO(), key)
+	dish, err := c.CreateDish(ctx, params)
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteDish(ctx, dish.ID, 0))
+}
+
+func TestIntegrationClientRoles(t *testing.T) {
+	var keys []auth.APIKey
+	var managerKey string
+	newKey := func(role auth.Role) context.Context {
+		key, stored := auth.NewAPIKey(string(role), "", role)
+		keys = append(keys, stored)
+		if role == auth.RoleManager {
+			managerKey = key
+		}
+		return auth.WithAPIKey(context.TODO(), key)
+	}
+	viewer, editor, manager := newKey(auth.RoleViewer), newKey(auth.RoleEditor), newKey(auth.RoleManager)
+	authenticator := auth.NewAuthenticator(auth.WithAPIKeys(keys))
+	endpoints := dishes.MakeServerEndpoints(dishes.NewService()).Wrap(func(method string) endpoint.Middleware {
+		return endpoint.Chain(auth.Middleware(method), dishes.Authorize(method))
+	})
+	server := httptest.NewServer(dishes.MakeHTTPHandler(endpoints, httptransport.ServerBefore(authenticator.ToContext)))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	// Only managers create dishes, since that sets their price
+	params := models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	}
+	_, err = c.CreateDish(editor, params)
+	assert.Equal(t, models.KindPermissionDenied, models.KindOf(err))
+	dish, err := c.CreateDish(manager, params)
+	require.NoError(t, err)
+
+	// Editors change everything but the price
+	_, err = c.GetDish(viewer, dish.ID, "")
+	require.NoError(t, err)
+	_, err = c.UpdateDish(viewer, dish.ID, models.DishParams{Name: makeString("Penne")})
+	assert.Equal(t, models.KindPermissionDenied, models.KindOf(err))
+	dish, err = c.UpdateDish(editor, dish.ID, models.DishParams{Name: makeString("Penne")})
+	require.NoError(t, err)
+	assert.Equal(t, "Penne", dish.Name)
+	_, err = c.UpdateDish(editor, dish.ID, models.DishParams{Price: makePrice("12")})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "price", Message: "requires the manager role"}}, err.(*models.Error).Fields)
+	dish, err = c.UpdateDish(manager, dish.ID, models.DishParams{Price: makePrice("12")})
+	require.NoError(t, err)
+	assert.Equal(t, makePrice("12"), &dish.Price)
+
+	// Modifier price deltas are prices too
+	sizes := func(delta string) *[]models.ModifierGroup {
+		return &[]models.ModifierGroup{{
+			ID: "size", Name: "Size", Required: true, Max: 1,
+			Modifiers: []models.Modifier{
+				{ID: "regular", Name: "Regular", PriceDelta: *makePrice("0")},
+				{ID: "large", Name: "Large", PriceDelta: *makePrice(delta)},
+			},
+		}}
+	}
+	_, err = c.UpdateDish(editor, dish.ID, models.DishParams{ModifierGroups: sizes("3")})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "modifierGroups[0].modifiers[1].priceDelta", Message: "requires the manager role"}}, err.(*models.Error).Fields)
+	dish, err = c.UpdateDish(editor, dish.ID, models.DishParams{ModifierGroups: sizes("0")})
+	require.NoError(t, err)
+	assert.Len(t, dish.ModifierGroups, 1)
+	dish, err = c.UpdateDish(manager, dish.ID, models.DishParams{ModifierGroups: sizes("3")})
+	require.NoError(t, err)
+	assert.Equal(t, "3.00 USD", dish.ModifierGroups[0].Modifiers[1].PriceDelta.String())
+
+	// And only admins delete
+	err = c.DeleteDish(manager, dish.ID, 0)
+	assert.Equal(t, models.KindPermissionDenied, models.KindOf(err))
+	req, err := http.NewRequest("DELETE", server.URL+"/dishes/"+dish.ID, nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.APIKeyHeader, managerKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
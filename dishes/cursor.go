@@ -0,0 +1,109 @@
+// Cursor implements opaque page tokens for ListDishes.
+package dishes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// ErrInvalidPageToken is returned for page tokens that were not issued by
+// this service, were issued under a different secret, or were issued to
+// another tenant.
+var ErrInvalidPageToken = models.InvalidArgument("invalid page token")
+
+// cursor marks a position in the listing order. It holds the sort keys of
+// the last dish on a page rather than a reference to the dish itself, so
+// listing can resume from the same position even if that dish was deleted
+// or changed meanwhile. The payload is signed but readable, it only holds
+// what the page it was issued with already showed.
+type cursor struct {
+	ID      string       `json:"i"`
+	Name    string       `json:"n,omitempty"`
+	Price   models.Money `json:"p"`
+	Created time.Time    `json:"c"`
+	Updated time.Time    `json:"u"`
+
+	// Query is the fingerprint of the query the cursor was issued for
+	Query string `json:"q"`
+
+	// Tenant is the tenant the cursor was issued to, so that its signature
+	// does not vouch for it elsewhere
+	Tenant string `json:"t,omitempty"`
+}
+
+func newCursor(tenantID string, q ListDishesQuery, last models.Dish) cursor {
+	return cursor{
+		ID:      last.ID,
+		Name:    last.Name,
+		Price:   effectivePrice(&last),
+		Created: last.Created,
+		Updated: last.Updated,
+		Query:   q.fingerprint(),
+		Tenant:  tenantID,
+	}
+}
+
+// anchor returns a dish with the cursor's sort keys, for comparing against
+func (c cursor) anchor() *models.Dish {
+	return &models.Dish{
+		ID:      c.ID,
+		Name:    c.Name,
+		Price:   c.Price,
+		Created: c.Created,
+		Updated: c.Updated,
+	}
+}
+
+// cursorCodec signs cursors so clients cannot forge or tamper with them
+type cursorCodec struct {
+	secret []byte
+}
+
+// Encode returns the opaque token for c, formatted as payload.signature
+func (cc cursorCodec) Encode(c cursor) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(cc.sign(payload))
+}
+
+// Decode verifies and parses a token produced by Encode for the tenant
+func (cc cursorCodec) Decode(token string, tenantID string) (cursor, error) {
+	var c cursor
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return c, ErrInvalidPageToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, ErrInvalidPageToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return c, ErrInvalidPageToken
+	}
+	if !hmac.Equal(signature, cc.sign(payload)) {
+		return c, ErrInvalidPageToken
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalidPageToken
+	}
+	if c.Tenant != tenantID {
+		return cursor{}, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+func (cc cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cc.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -5,8 +5,12 @@ package dishes
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
 	"github.com/jeffizhungry/polygon/models"
 )
 
@@ -15,35 +19,296 @@ import (
 //
 // Mainly a helper struct for aggregating various endpoints
 type Endpoints struct {
-	CreateDishEndpoint endpoint.Endpoint
-	UpdateDishEndpoint endpoint.Endpoint
-	DeleteDishEndpoint endpoint.Endpoint
-	GetDishEndpoint    endpoint.Endpoint
-	ListDishesEndpoint endpoint.Endpoint
+	CreateDishEndpoint   endpoint.Endpoint
+	UpdateDishEndpoint   endpoint.Endpoint
+	DeleteDishEndpoint   endpoint.Endpoint
+	GetDishEndpoint      endpoint.Endpoint
+	ListDishesEndpoint   endpoint.Endpoint
+	SearchDishesEndpoint endpoint.Endpoint
+	PriceDishEndpoint    endpoint.Endpoint
+	NutritionEndpoint    endpoint.Endpoint
+
+	CreateCategoryEndpoint endpoint.Endpoint
+	UpdateCategoryEndpoint endpoint.Endpoint
+	DeleteCategoryEndpoint endpoint.Endpoint
+	GetCategoryEndpoint    endpoint.Endpoint
+	ListCategoriesEndpoint endpoint.Endpoint
 }
 
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in a dishes server.
 func MakeServerEndpoints(s Service) Endpoints {
 	return Endpoints{
-		CreateDishEndpoint: MakeCreateDishEndpoint(s),
-		UpdateDishEndpoint: MakeUpdateDishEndpoint(s),
-		DeleteDishEndpoint: MakeDeleteDishEndpoint(s),
-		GetDishEndpoint:    MakeGetDishEndpoint(s),
-		ListDishesEndpoint: MakeListDishesEndpoint(s),
+		CreateDishEndpoint:   MakeCreateDishEndpoint(s),
+		UpdateDishEndpoint:   MakeUpdateDishEndpoint(s),
+		DeleteDishEndpoint:   MakeDeleteDishEndpoint(s),
+		GetDishEndpoint:      MakeGetDishEndpoint(s),
+		ListDishesEndpoint:   MakeListDishesEndpoint(s),
+		SearchDishesEndpoint: MakeSearchDishesEndpoint(s),
+		PriceDishEndpoint:    MakePriceDishEndpoint(s),
+		NutritionEndpoint:    MakeNutritionEndpoint(s),
+
+		CreateCategoryEndpoint: MakeCreateCategoryEndpoint(s),
+		UpdateCategoryEndpoint: MakeUpdateCategoryEndpoint(s),
+		DeleteCategoryEndpoint: MakeDeleteCategoryEndpoint(s),
+		GetCategoryEndpoint:    MakeGetCategoryEndpoint(s),
+		ListCategoriesEndpoint: MakeListCategoriesEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "DeleteDish".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		CreateDishEndpoint:   mw("CreateDish")(e.CreateDishEndpoint),
+		UpdateDishEndpoint:   mw("UpdateDish")(e.UpdateDishEndpoint),
+		DeleteDishEndpoint:   mw("DeleteDish")(e.DeleteDishEndpoint),
+		GetDishEndpoint:      mw("GetDish")(e.GetDishEndpoint),
+		ListDishesEndpoint:   mw("ListDishes")(e.ListDishesEndpoint),
+		SearchDishesEndpoint: mw("SearchDishes")(e.SearchDishesEndpoint),
+		PriceDishEndpoint:    mw("PriceDish")(e.PriceDishEndpoint),
+		NutritionEndpoint:    mw("NutritionLabel")(e.NutritionEndpoint),
+
+		CreateCategoryEndpoint: mw("CreateCategory")(e.CreateCategoryEndpoint),
+		UpdateCategoryEndpoint: mw("UpdateCategory")(e.UpdateCategoryEndpoint),
+		DeleteCategoryEndpoint: mw("DeleteCategory")(e.DeleteCategoryEndpoint),
+		GetCategoryEndpoint:    mw("GetCategory")(e.GetCategoryEndpoint),
+		ListCategoriesEndpoint: mw("ListCategories")(e.ListCategoriesEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service.
+// Creating a dish sets its price, so it takes a manager, see Authorize for
+// the fields of an update.
+var Policy = auth.Policy{
+	"GetDish":        auth.RoleViewer,
+	"ListDishes":     auth.RoleViewer,
+	"SearchDishes":   auth.RoleViewer,
+	"PriceDish":      auth.RoleViewer,
+	"NutritionLabel": auth.RoleViewer,
+	"GetCategory":    auth.RoleViewer,
+	"ListCategories": auth.RoleViewer,
+	"CreateDish":     auth.RoleManager,
+	"UpdateDish":     auth.RoleEditor,
+	"CreateCategory": auth.RoleEditor,
+	"UpdateCategory": auth.RoleEditor,
+	"DeleteDish":     auth.RoleAdmin,
+	"DeleteCategory": auth.RoleAdmin,
+}
+
+// Authorize returns middleware enforcing Policy for the method, and on top of
+// it that only managers change a dish's Price, Prices, or the PriceDelta of
+// its modifiers. Editors may still set modifier groups whose deltas are all
+// zero. Since PUT replaces the price along with everything else, editors
+// update dishes with PATCH. Use it after auth.Middleware, which puts the
+// principal into the context.
+func Authorize(method string) endpoint.Middleware {
+	policy := Policy.Middleware(method)
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return policy(func(ctx context.Context, request interface{}) (interface{}, error) {
+			var params models.DishParams
+			switch req := request.(type) {
+			case createDishRequest:
+				params = req.DishParams
+			case updateDishRequest:
+				params = req.DishParams
+			default:
+				return next(ctx, request)
+			}
+			if auth.Require(ctx, auth.RoleManager) == nil {
+				return next(ctx, request)
+			}
+			err := models.PermissionDenied("only managers can change prices")
+			if params.Price != nil {
+				err = err.WithField("price", "requires the manager role")
+			}
+			if params.Prices != nil {
+				err = err.WithField("prices", "requires the manager role")
+			}
+			if params.ModifierGroups != nil {
+				for i, g := range *params.ModifierGroups {
+					for j, m := range g.Modifiers {
+						if !m.PriceDelta.IsZero() {
+							err = err.WithField(fmt.Sprintf("modifierGroups[%d].modifiers[%d].priceDelta", i, j), "requires the manager role")
+						}
+					}
+				}
+			}
+			if len(err.Fields) > 0 {
+				return nil, err
+			}
+			return next(ctx, request)
+		})
+	}
+}
+
+// CreateDish implements Service. Primarily useful in a client.
+func (e Endpoints) CreateDish(ctx context.Context, d models.DishParams) (*models.Dish, error) {
+	response, err := e.CreateDishEndpoint(ctx, createDishRequest{DishParams: d})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(createDishResponse)
+	return resp.Dish, resp.Err
+}
+
+// GetDish implements Service. Primarily useful in a client.
+func (e Endpoints) GetDish(ctx context.Context, id string, currency string) (*models.Dish, error) {
+	response, err := e.GetDishEndpoint(ctx, getDishRequest{ID: id, Currency: currency})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(getDishResponse)
+	return resp.Dish, resp.Err
+}
+
+// UpdateDish implements Service. Primarily useful in a client.
+func (e Endpoints) UpdateDish(ctx context.Context, id string, d models.DishParams) (*models.Dish, error) {
+	response, err := e.UpdateDishEndpoint(ctx, updateDishRequest{ID: id, DishParams: d})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(updateDishResponse)
+	return resp.Dish, resp.Err
+}
+
+// DeleteDish implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteDish(ctx context.Context, id string, version int64) error {
+	response, err := e.DeleteDishEndpoint(ctx, deleteDishRequest{ID: id, Version: version})
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteDishResponse)
+	return resp.Err
+}
+
+// ListDishes implements Service. Primarily useful in a client.
+func (e Endpoints) ListDishes(ctx context.Context, q ListDishesQuery) ([]models.Dish, string, error) {
+	response, err := e.ListDishesEndpoint(ctx, listDishesRequest{ListDishesQuery: q})
+	if err != nil {
+		return nil, "", err
+	}
+	resp := response.(listDishesResponse)
+	return resp.Dishes, resp.NextPageToken, resp.Err
+}
+
+// SearchDishes implements Service. Primarily useful in a client.
+func (e Endpoints) SearchDishes(ctx context.Context, query string, limit int, filter DietaryFilter) ([]SearchResult, error) {
+	response, err := e.SearchDishesEndpoint(ctx, searchDishesRequest{Query: query, Limit: limit, DietaryFilter: filter})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(searchDishesResponse)
+	return resp.Results, resp.Err
+}
+
+// PriceDish implements Service. Primarily useful in a client.
+func (e Endpoints) PriceDish(ctx context.Context, id string, c models.Configuration) (*models.PriceBreakdown, error) {
+	response, err := e.PriceDishEndpoint(ctx, priceDishRequest{ID: id, Configuration: c})
+	if err != nil {
+		return nil, err
 	}
+	resp := response.(priceDishResponse)
+	return resp.PriceBreakdown, resp.Err
+}
+
+// NutritionLabel implements Service. Primarily useful in a client.
+func (e Endpoints) NutritionLabel(ctx context.Context, id string) (*models.NutritionLabel, error) {
+	response, err := e.NutritionEndpoint(ctx, nutritionRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(nutritionResponse)
+	return resp.NutritionLabel, resp.Err
+}
+
+// CreateCategory implements Service. Primarily useful in a client.
+func (e Endpoints) CreateCategory(ctx context.Context, c models.CategoryParams) (*models.Category, error) {
+	response, err := e.CreateCategoryEndpoint(ctx, createCategoryRequest{CategoryParams: c})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(createCategoryResponse)
+	return resp.Category, resp.Err
+}
+
+// GetCategory implements Service. Primarily useful in a client.
+func (e Endpoints) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	response, err := e.GetCategoryEndpoint(ctx, getCategoryRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(getCategoryResponse)
+	return resp.Category, resp.Err
+}
+
+// UpdateCategory implements Service. Primarily useful in a client.
+func (e Endpoints) UpdateCategory(ctx context.Context, id string, c models.CategoryParams) (*models.Category, error) {
+	response, err := e.UpdateCategoryEndpoint(ctx, updateCategoryRequest{ID: id, CategoryParams: c})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(updateCategoryResponse)
+	return resp.Category, resp.Err
+}
+
+// DeleteCategory implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteCategory(ctx context.Context, id string, version int64, reassignTo string) error {
+	response, err := e.DeleteCategoryEndpoint(ctx, deleteCategoryRequest{ID: id, Version: version, ReassignTo: reassignTo})
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteCategoryResponse)
+	return resp.Err
+}
+
+// ListCategories implements Service. Primarily useful in a client.
+func (e Endpoints) ListCategories(ctx context.Context) ([]models.Category, error) {
+	response, err := e.ListCategoriesEndpoint(ctx, listCategoriesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listCategoriesResponse)
+	return resp.Categories, resp.Err
 }
 
 // Translate request payloads to service arguments and
 // services return values into response payloads.
 
+// etagHeader exposes the dish version as a strong entity tag, which clients
+// send back in If-Match to update or delete only that version.
+func etagHeader(d *models.Dish) http.Header {
+	if d == nil {
+		return http.Header{}
+	}
+	return etag.Header(d.Version)
+}
+
+// categoryETagHeader is etagHeader for categories
+func categoryETagHeader(c *models.Category) http.Header {
+	if c == nil {
+		return http.Header{}
+	}
+	return etag.Header(c.Version)
+}
+
 type createDishRequest struct {
 	models.DishParams
 }
 
 type createDishResponse struct {
 	*models.Dish
-	Err error `json:"error,omitempty"`
+	Err error `json:"-"`
 }
 
+func (r createDishResponse) error() error { return r.Err }
+
+func (r createDishResponse) Headers() http.Header { return etagHeader(r.Dish) }
+
+// StatusCode reports 201 since a new dish was created
+func (r createDishResponse) StatusCode() int { return http.StatusCreated }
+
 func MakeCreateDishEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req, ok := request.(createDishRequest)
@@ -57,21 +322,26 @@ func MakeCreateDishEndpoint(s Service) endpoint.Endpoint {
 }
 
 type getDishRequest struct {
-	ID string `json:"id"`
+	ID       string `json:"id"`
+	Currency string `json:"currency"`
 }
 
 type getDishResponse struct {
 	*models.Dish
-	Err error `json:"error,omitempty"`
+	Err error `json:"-"`
 }
 
+func (r getDishResponse) error() error { return r.Err }
+
+func (r getDishResponse) Headers() http.Header { return etagHeader(r.Dish) }
+
 func MakeGetDishEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req, ok := request.(getDishRequest)
 		if !ok {
 			return nil, errors.New("programmer error")
 		}
-		dish, err := s.GetDish(ctx, req.ID)
+		dish, err := s.GetDish(ctx, req.ID, req.Currency)
 		resp := getDishResponse{Dish: dish, Err: err}
 		return resp, nil
 	}
@@ -84,9 +354,13 @@ type updateDishRequest struct {
 
 type updateDishResponse struct {
 	*models.Dish
-	Err error `json:"error,omitempty"`
+	Err error `json:"-"`
 }
 
+func (r updateDishResponse) error() error { return r.Err }
+
+func (r updateDishResponse) Headers() http.Header { return etagHeader(r.Dish) }
+
 func MakeUpdateDishEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req, ok := request.(updateDishRequest)
@@ -100,43 +374,259 @@ func MakeUpdateDishEndpoint(s Service) endpoint.Endpoint {
 }
 
 type deleteDishRequest struct {
-	ID string `json:"id"`
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
 }
 
 type deleteDishResponse struct {
-	Err error `json:"error,omitempty"`
+	Err error `json:"-"`
 }
 
+func (r deleteDishResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing left to return
+func (r deleteDishResponse) StatusCode() int { return http.StatusNoContent }
+
 func MakeDeleteDishEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req, ok := request.(deleteDishRequest)
 		if !ok {
 			return nil, errors.New("programmer error")
 		}
-		err = s.DeleteDish(ctx, req.ID)
+		err = s.DeleteDish(ctx, req.ID, req.Version)
 		resp := deleteDishResponse{Err: err}
 		return resp, nil
 	}
 }
 
 type listDishesRequest struct {
-	Offset   string `json:"offset"`
-	PageSize int    `json:"pageSize"`
+	ListDishesQuery
 }
 
 type listDishesResponse struct {
-	Dishes []models.Dish `json:"values"`
-	Err    error         `json:"error,omitempty"`
+	Dishes        []models.Dish `json:"values"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+	HasMore       bool          `json:"hasMore"`
+	Err           error         `json:"-"`
 }
 
+func (r listDishesResponse) error() error { return r.Err }
+
 func MakeListDishesEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req, ok := request.(listDishesRequest)
 		if !ok {
 			return nil, errors.New("programmer error")
 		}
-		dishes, err := s.ListDishes(ctx, req.Offset, req.PageSize)
-		resp := listDishesResponse{Dishes: dishes, Err: err}
+		dishes, next, err := s.ListDishes(ctx, req.ListDishesQuery)
+		resp := listDishesResponse{
+			Dishes:        dishes,
+			NextPageToken: next,
+			HasMore:       next != "",
+			Err:           err,
+		}
+		return resp, nil
+	}
+}
+
+type searchDishesRequest struct {
+	Query string `json:"q"`
+	Limit int    `json:"limit"`
+	DietaryFilter
+}
+
+type searchDishesResponse struct {
+	Results []SearchResult `json:"values"`
+	Err     error          `json:"-"`
+}
+
+func (r searchDishesResponse) error() error { return r.Err }
+
+func MakeSearchDishesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(searchDishesRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		results, err := s.SearchDishes(ctx, req.Query, req.Limit, req.DietaryFilter)
+		resp := searchDishesResponse{Results: results, Err: err}
+		return resp, nil
+	}
+}
+
+type priceDishRequest struct {
+	ID string `json:"id"`
+	models.Configuration
+}
+
+type priceDishResponse struct {
+	*models.PriceBreakdown
+	Err error `json:"-"`
+}
+
+func (r priceDishResponse) error() error { return r.Err }
+
+func MakePriceDishEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(priceDishRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		breakdown, err := s.PriceDish(ctx, req.ID, req.Configuration)
+		resp := priceDishResponse{PriceBreakdown: breakdown, Err: err}
+		return resp, nil
+	}
+}
+
+type nutritionRequest struct {
+	ID string `json:"id"`
+
+	// Text renders the label as a plain text table instead of JSON
+	Text bool `json:"-"`
+}
+
+type nutritionResponse struct {
+	*models.NutritionLabel
+	Text bool  `json:"-"`
+	Err  error `json:"-"`
+}
+
+func (r nutritionResponse) error() error { return r.Err }
+
+func MakeNutritionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(nutritionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		label, err := s.NutritionLabel(ctx, req.ID)
+		resp := nutritionResponse{NutritionLabel: label, Text: req.Text, Err: err}
+		return resp, nil
+	}
+}
+
+type createCategoryRequest struct {
+	models.CategoryParams
+}
+
+type createCategoryResponse struct {
+	*models.Category
+	Err error `json:"-"`
+}
+
+func (r createCategoryResponse) error() error { return r.Err }
+
+func (r createCategoryResponse) Headers() http.Header { return categoryETagHeader(r.Category) }
+
+// StatusCode reports 201 since a new category was created
+func (r createCategoryResponse) StatusCode() int { return http.StatusCreated }
+
+func MakeCreateCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(createCategoryRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		category, err := s.CreateCategory(ctx, req.CategoryParams)
+		resp := createCategoryResponse{Category: category, Err: err}
+		return resp, nil
+	}
+}
+
+type getCategoryRequest struct {
+	ID string `json:"id"`
+}
+
+type getCategoryResponse struct {
+	*models.Category
+	Err error `json:"-"`
+}
+
+func (r getCategoryResponse) error() error { return r.Err }
+
+func (r getCategoryResponse) Headers() http.Header { return categoryETagHeader(r.Category) }
+
+func MakeGetCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(getCategoryRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		category, err := s.GetCategory(ctx, req.ID)
+		resp := getCategoryResponse{Category: category, Err: err}
+		return resp, nil
+	}
+}
+
+type updateCategoryRequest struct {
+	ID string `json:"id"`
+	models.CategoryParams
+}
+
+type updateCategoryResponse struct {
+	*models.Category
+	Err error `json:"-"`
+}
+
+func (r updateCategoryResponse) error() error { return r.Err }
+
+func (r updateCategoryResponse) Headers() http.Header { return categoryETagHeader(r.Category) }
+
+func MakeUpdateCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(updateCategoryRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		category, err := s.UpdateCategory(ctx, req.ID, req.CategoryParams)
+		resp := updateCategoryResponse{Category: category, Err: err}
+		return resp, nil
+	}
+}
+
+type deleteCategoryRequest struct {
+	ID         string `json:"id"`
+	Version    int64  `json:"version"`
+	ReassignTo string `json:"reassignTo"`
+}
+
+type deleteCategoryResponse struct {
+	Err error `json:"-"`
+}
+
+func (r deleteCategoryResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing left to return
+func (r deleteCategoryResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeDeleteCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(deleteCategoryRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeleteCategory(ctx, req.ID, req.Version, req.ReassignTo)
+		resp := deleteCategoryResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listCategoriesRequest struct{}
+
+type listCategoriesResponse struct {
+	Categories []models.Category `json:"values"`
+	Err        error             `json:"-"`
+}
+
+func (r listCategoriesResponse) error() error { return r.Err }
+
+func MakeListCategoriesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if _, ok := request.(listCategoriesRequest); !ok {
+			return nil, errors.New("programmer error")
+		}
+		categories, err := s.ListCategories(ctx)
+		resp := listCategoriesResponse{Categories: categories, Err: err}
 		return resp, nil
 	}
 }
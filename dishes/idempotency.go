@@ -0,0 +1,78 @@
+// Idempotency lets clients safely retry CreateDish.
+package dishes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const (
+	// IdempotencyKeyHeader carries the idempotency key over HTTP
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLength = 255
+)
+
+type contextKey int
+
+const (
+	idempotencyKeyContextKey contextKey = iota
+)
+
+// WithIdempotencyKey returns a context making CreateDish idempotent under
+// key. Retrying with the same key and params returns the dish created by the
+// first call instead of creating another one, while reusing the key with
+// different params fails with a conflict error. So does retrying once that
+// dish was deleted, rather than bringing it back.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey, key)
+}
+
+// IdempotencyKeyFrom returns the idempotency key set on the context, if any
+func IdempotencyKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContextKey).(string)
+	return key, ok && key != ""
+}
+
+// idempotencyKeyToContext is a server RequestFunc moving the Idempotency-Key
+// header into the context
+func idempotencyKeyToContext(ctx context.Context, r *http.Request) context.Context {
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		return WithIdempotencyKey(ctx, key)
+	}
+	return ctx
+}
+
+// idempotencyKeyToHTTP is a client RequestFunc moving the context's
+// idempotency key into the Idempotency-Key header
+func idempotencyKeyToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if key, ok := IdempotencyKeyFrom(ctx); ok {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return ctx
+}
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return models.InvalidArgument("invalid idempotency key").
+			WithField(IdempotencyKeyHeader, "cannot be longer than 255 characters")
+	}
+	return nil
+}
+
+// paramsFingerprint identifies creation params. The version is ignored since
+// CreateDish ignores it too.
+func paramsFingerprint(params models.DishParams) string {
+	params.Version = nil
+	b, err := json.Marshal(params)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
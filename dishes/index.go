@@ -0,0 +1,174 @@
+// Index keeps dishes ordered by creation time for ListDishes.
+package dishes
+
+import (
+	"math/rand"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const (
+	maxIndexLevel = 32
+	indexP        = 0.25 // chance of a node reaching the next level
+)
+
+// dishIndex is a skip list of dishes ordered by Created, then ID. Insert,
+// delete and seek are O(log n) on average, and neighbours are a pointer away
+// in either direction. It is not safe for concurrent use.
+//
+// Nodes hold a pointer to the current version of each dish, replace it on
+// update rather than mutating it so that pages already returned stay intact.
+type dishIndex struct {
+	head  *indexNode
+	tail  *indexNode
+	level int
+	len   int
+	rnd   *rand.Rand
+}
+
+type indexNode struct {
+	dish *models.Dish
+	next []*indexNode
+	prev *indexNode
+}
+
+func newDishIndex() *dishIndex {
+	head := &indexNode{next: make([]*indexNode, maxIndexLevel)}
+	return &dishIndex{
+		head:  head,
+		tail:  head,
+		level: 1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+func indexLess(a, b *models.Dish) bool {
+	return lessBy(SortByCreated, a, b)
+}
+
+// Len returns the number of indexed dishes
+func (ix *dishIndex) Len() int {
+	return ix.len
+}
+
+// predecessors returns, per level, the last node ordered before d
+func (ix *dishIndex) predecessors(d *models.Dish) [maxIndexLevel]*indexNode {
+	var preds [maxIndexLevel]*indexNode
+	n := ix.head
+	for l := ix.level - 1; l >= 0; l-- {
+		for n.next[l] != nil && indexLess(n.next[l].dish, d) {
+			n = n.next[l]
+		}
+		preds[l] = n
+	}
+	return preds
+}
+
+func (ix *dishIndex) randomLevel() int {
+	l := 1
+	for l < maxIndexLevel && ix.rnd.Float64() < indexP {
+		l++
+	}
+	return l
+}
+
+// Put adds the dish, or replaces the indexed version of it. Created must not
+// change between versions of a dish.
+func (ix *dishIndex) Put(d *models.Dish) {
+	preds := ix.predecessors(d)
+	if n := preds[0].next[0]; n != nil && n.dish.ID == d.ID {
+		n.dish = d
+		return
+	}
+
+	level := ix.randomLevel()
+	if level > ix.level {
+		for l := ix.level; l < level; l++ {
+			preds[l] = ix.head
+		}
+		ix.level = level
+	}
+	n := &indexNode{dish: d, next: make([]*indexNode, level)}
+	for l := 0; l < level; l++ {
+		n.next[l] = preds[l].next[l]
+		preds[l].next[l] = n
+	}
+	n.prev = preds[0]
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		ix.tail = n
+	}
+	ix.len++
+}
+
+// Delete removes the dish, reporting if it was indexed
+func (ix *dishIndex) Delete(d *models.Dish) bool {
+	preds := ix.predecessors(d)
+	n := preds[0].next[0]
+	if n == nil || n.dish.ID != d.ID {
+		return false
+	}
+	for l := range n.next {
+		preds[l].next[l] = n.next[l]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n.prev
+	} else {
+		ix.tail = n.prev
+	}
+	for ix.level > 1 && ix.head.next[ix.level-1] == nil {
+		ix.level--
+	}
+	ix.len--
+	return true
+}
+
+// First returns the earliest dish, or nil if the index is empty
+func (ix *dishIndex) First() *indexNode {
+	return ix.head.next[0]
+}
+
+// Last returns the latest dish, or nil if the index is empty
+func (ix *dishIndex) Last() *indexNode {
+	if ix.tail == ix.head {
+		return nil
+	}
+	return ix.tail
+}
+
+// Ceiling returns the first dish ordered at or after d
+func (ix *dishIndex) Ceiling(d *models.Dish) *indexNode {
+	return ix.predecessors(d)[0].next[0]
+}
+
+// After returns the first dish ordered strictly after d
+func (ix *dishIndex) After(d *models.Dish) *indexNode {
+	n := ix.Ceiling(d)
+	if n != nil && !indexLess(d, n.dish) {
+		n = n.Next()
+	}
+	return n
+}
+
+// Before returns the last dish ordered strictly before d
+func (ix *dishIndex) Before(d *models.Dish) *indexNode {
+	n := ix.predecessors(d)[0]
+	if n == ix.head {
+		return nil
+	}
+	return n
+}
+
+// Next returns the following dish, or nil at the end of the index
+func (n *indexNode) Next() *indexNode {
+	return n.next[0]
+}
+
+// Prev returns the preceding dish, or nil at the start of the index
+func (n *indexNode) Prev() *indexNode {
+	if n.prev.dish == nil {
+		return nil
+	}
+	return n.prev
+}
@@ -0,0 +1,181 @@
+package dishes
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/dishes/storage"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func indexedIDs(ix *dishIndex) []string {
+	var ids []string
+	for n := ix.First(); n != nil; n = n.Next() {
+		ids = append(ids, n.dish.ID)
+	}
+	return ids
+}
+
+func TestDishIndex(t *testing.T) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	rnd := rand.New(rand.NewSource(42))
+
+	// Random inserts and deletes, checked against a sorted slice. Creation
+	// times collide on purpose so the ID tie-break is exercised.
+	ix := newDishIndex()
+	expected := map[string]*models.Dish{}
+	for i := 0; i < 2000; i++ {
+		if len(expected) > 0 && rnd.Intn(3) == 0 {
+			for id, d := range expected {
+				require.True(t, ix.Delete(d))
+				delete(expected, id)
+				break
+			}
+			continue
+		}
+		d := &models.Dish{
+			ID:      fmt.Sprintf("%04d", rnd.Intn(10000)),
+			Created: start.Add(time.Duration(rnd.Intn(50)) * time.Second),
+		}
+		if old, found := expected[d.ID]; found {
+			d.Created = old.Created
+		}
+		expected[d.ID] = d
+		ix.Put(d)
+	}
+
+	var sorted []*models.Dish
+	for _, d := range expected {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return indexLess(sorted[i], sorted[j]) })
+	var ids []string
+	for _, d := range sorted {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, len(expected), ix.Len())
+	assert.Equal(t, ids, indexedIDs(ix))
+
+	// Backwards
+	var reversed []string
+	for n := ix.Last(); n != nil; n = n.Prev() {
+		reversed = append([]string{n.dish.ID}, reversed...)
+	}
+	assert.Equal(t, ids, reversed)
+
+	// Seeks
+	mid := sorted[len(sorted)/2]
+	assert.Equal(t, mid.ID, ix.Ceiling(mid).dish.ID)
+	assert.Equal(t, sorted[len(sorted)/2+1].ID, ix.After(mid).dish.ID)
+	assert.Equal(t, sorted[len(sorted)/2-1].ID, ix.Before(mid).dish.ID)
+	assert.Nil(t, ix.Before(sorted[0]))
+	assert.Nil(t, ix.After(sorted[len(sorted)-1]))
+	assert.False(t, ix.Delete(&models.Dish{ID: "missing", Created: start}))
+}
+
+func TestDishIndexReplace(t *testing.T) {
+	created := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	ix := newDishIndex()
+	ix.Put(&models.Dish{ID: "a", Name: "Pasta", Created: created})
+	ix.Put(&models.Dish{ID: "b", Name: "Pizza", Created: created})
+	ix.Put(&models.Dish{ID: "a", Name: "Penne", Created: created})
+
+	assert.Equal(t, 2, ix.Len())
+	assert.Equal(t, "Penne", ix.First().dish.Name)
+
+	// Empty again
+	require.True(t, ix.Delete(&models.Dish{ID: "a", Created: created}))
+	require.True(t, ix.Delete(&models.Dish{ID: "b", Created: created}))
+	assert.Nil(t, ix.First())
+	assert.Nil(t, ix.Last())
+}
+
+/**************************************
+ * Benchmarks
+ *************************************/
+
+const benchmarkDishes = 1000000
+
+var benchmarkNames = []string{"Pasta", "Pizza", "Salad", "Soup", "Burger", "Tacos", "Ramen", "Curry"}
+
+// benchmarkService returns a service holding 1M dishes, along with their IDs
+func benchmarkService(b *testing.B) (*resource, []string) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := storage.NewMemory()
+	ids := make([]string, benchmarkDishes)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("dish%07d", i)
+		repo.Put(models.Dish{
+			ID:      ids[i],
+			Name:    benchmarkNames[i%len(benchmarkNames)],
+			Price:   models.NewMoney(int64(i%100+1)*100, "USD"),
+			Version: 1,
+			Created: start.Add(time.Duration(i) * time.Millisecond),
+			Updated: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	r := NewService(WithRepository(repo)).(*resource)
+	b.ResetTimer()
+	return r, ids
+}
+
+func BenchmarkListDishes(b *testing.B) {
+	r, _ := benchmarkService(b)
+	token := ""
+	for i := 0; i < b.N; i++ {
+		_, next, err := r.ListDishes(context.TODO(), ListDishesQuery{PageToken: token, PageSize: 100})
+		if err != nil {
+			b.Fatal(err)
+		}
+		token = next
+	}
+}
+
+func BenchmarkListDishesDescending(b *testing.B) {
+	r, _ := benchmarkService(b)
+	token := ""
+	for i := 0; i < b.N; i++ {
+		q := ListDishesQuery{PageToken: token, PageSize: 100, Descending: true}
+		_, next, err := r.ListDishes(context.TODO(), q)
+		if err != nil {
+			b.Fatal(err)
+		}
+		token = next
+	}
+}
+
+func BenchmarkUpdateDish(b *testing.B) {
+	r, ids := benchmarkService(b)
+	for i := 0; i < b.N; i++ {
+		price := models.NewMoney(int64(i%50+1)*100, "USD")
+		_, err := r.UpdateDish(context.TODO(), ids[(i*7919)%len(ids)], models.DishParams{Price: &price})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeleteDish(b *testing.B) {
+	r, ids := benchmarkService(b)
+	for i := 0; i < b.N; i++ {
+		// Put the dish back so the service stays at 1M dishes
+		id := ids[(i*7919)%len(ids)]
+		dish, _ := r.repo.Get("", id)
+		if err := r.DeleteDish(context.TODO(), id, 0); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		r.repo.Put(*dish)
+		c := r.catalog("")
+		c.secondaryIndex.Put(dish)
+		c.categorize(dish.ID, "", dish.CategoryID)
+		c.search.Add(dish.ID, searchFields(dish)...)
+		b.StartTimer()
+	}
+}
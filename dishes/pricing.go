@@ -0,0 +1,77 @@
+// Pricing quotes dishes in the currency they are requested in.
+package dishes
+
+import (
+	"context"
+
+	"github.com/jeffizhungry/polygon/lib/exchange"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// WithExchangeRates lets dishes be quoted in currencies they have no local
+// price in, by converting their base price. Without rates only local prices
+// can be quoted.
+func WithExchangeRates(p exchange.Provider) Option {
+	return func(r *resource) { r.rates = p }
+}
+
+// WithRounding sets how converted prices are rounded, per target currency.
+// Currencies without a rule are rounded half up to a single minor unit.
+func WithRounding(rules map[string]models.Rounding) Option {
+	return func(r *resource) { r.rounding = rules }
+}
+
+func validateCurrency(currency string) error {
+	if _, ok := models.CurrencyExponent(currency); !ok {
+		return models.InvalidArgument("invalid currency").
+			WithField("currency", "must be a supported ISO 4217 code")
+	}
+	return nil
+}
+
+// quote returns a copy of the dish carrying its price in currency, either its
+// local price or its converted base price. An empty currency returns the dish
+// unquoted.
+func (r *resource) quote(d *models.Dish, currency string) (models.Dish, error) {
+	dish := *d
+	if currency == "" {
+		return dish, nil
+	}
+	if price, ok := d.LocalPrice(currency); ok {
+		dish.Quote = &models.PriceQuote{Price: price}
+		return dish, nil
+	}
+
+	// Convert
+	if r.rates == nil {
+		return dish, models.InvalidArgument("dish %v has no %v price", d.ID, currency).
+			WithField("currency", "no exchange rates are configured")
+	}
+	price, err := exchange.Convert(r.rates, d.Price, currency, r.rounding[currency])
+	if models.KindOf(err) == models.KindNotFound {
+		return dish, models.InvalidArgument("dish %v has no %v price", d.ID, currency).
+			WithField("currency", "no exchange rate from "+d.Price.Currency)
+	}
+	if err != nil {
+		return dish, err
+	}
+	dish.Quote = &models.PriceQuote{Price: price, Converted: true}
+	return dish, nil
+}
+
+func (r *resource) PriceDish(ctx context.Context, id string, config models.Configuration) (*models.PriceBreakdown, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	dish, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return nil, err
+	}
+	return dish.CalculatePrice(config)
+}
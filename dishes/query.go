@@ -0,0 +1,262 @@
+// Query describes which dishes ListDishes returns and in what order.
+package dishes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// SortField is a dish field ListDishes can order by
+type SortField string
+
+const (
+	SortByCreated SortField = "created"
+	SortByUpdated SortField = "updated"
+	SortByName    SortField = "name"
+	SortByPrice   SortField = "price"
+)
+
+// ListDishesQuery selects, orders and paginates dishes. Zero valued filters
+// are ignored. A price range only matches dishes priced in its currency.
+// Ties in the sort order are always broken by ID, so the order is total and
+// pages are stable.
+type ListDishesQuery struct {
+	PageToken string
+	PageSize  int
+
+	// Filters
+	MinPrice      *models.Money
+	MaxPrice      *models.Money
+	NamePrefix    string
+	NameContains  string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// CategoryID only matches dishes in the category, Tags only dishes
+	// carrying every tag
+	CategoryID string
+	Tags       []string
+
+	DietaryFilter
+
+	// AvailableOnly hides dishes that cannot be ordered right now, see
+	// WithInventory
+	AvailableOnly bool
+
+	// Currency quotes every dish in the currency, see models.Dish.Quote.
+	// The price range and price ordering then apply to the quoted prices.
+	// Dishes that cannot be quoted in the currency are left out.
+	Currency string
+
+	// Sort defaults to SortByCreated
+	Sort       SortField
+	Descending bool
+}
+
+// DietaryFilter excludes dishes containing any of ExcludeAllergens and
+// requires every one of Diets, see models.Dish.Suits. It is shared by
+// ListDishes and SearchDishes.
+type DietaryFilter struct {
+	ExcludeAllergens []models.Allergen
+	Diets            []models.Diet
+}
+
+// validate adds every unknown allergen and diet to err
+func (f DietaryFilter) validate(err *models.Error) *models.Error {
+	for _, a := range f.ExcludeAllergens {
+		if !a.Valid() {
+			err = err.WithField("excludeAllergen", fmt.Sprintf("unknown allergen %q", a))
+		}
+	}
+	for _, d := range f.Diets {
+		if !d.Valid() {
+			err = err.WithField("diet", fmt.Sprintf("unknown diet %q", d))
+		}
+	}
+	return err
+}
+
+// match reports if the dish passes the filter
+func (f DietaryFilter) match(d *models.Dish) bool {
+	for _, a := range f.ExcludeAllergens {
+		if d.Contains(a) {
+			return false
+		}
+	}
+	for _, diet := range f.Diets {
+		if !d.Suits(diet) {
+			return false
+		}
+	}
+	return true
+}
+
+// String identifies the filter in fingerprints, ignoring order
+func (f DietaryFilter) String() string {
+	allergens := make([]string, len(f.ExcludeAllergens))
+	for i, a := range f.ExcludeAllergens {
+		allergens[i] = string(a)
+	}
+	diets := make([]string, len(f.Diets))
+	for i, d := range f.Diets {
+		diets[i] = string(d)
+	}
+	sort.Strings(allergens)
+	sort.Strings(diets)
+	return fmt.Sprintf("%q|%q", allergens, diets)
+}
+
+// Validate checks the filters and sort order, the page token is checked
+// separately since only the service can verify it.
+func (q ListDishesQuery) Validate() error {
+	err := models.InvalidArgument("invalid query")
+	if q.PageSize < 0 {
+		err = err.WithField("pageSize", "cannot be negative")
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil {
+		if c, cerr := q.MinPrice.Cmp(*q.MaxPrice); cerr != nil {
+			err = err.WithField("minPrice", "must be in the same currency as maxPrice")
+		} else if c > 0 {
+			err = err.WithField("minPrice", "cannot be greater than maxPrice")
+		}
+	}
+	if !q.CreatedAfter.IsZero() && !q.CreatedBefore.IsZero() && q.CreatedAfter.After(q.CreatedBefore) {
+		err = err.WithField("createdAfter", "cannot be after createdBefore")
+	}
+	if q.Currency != "" {
+		if _, ok := models.CurrencyExponent(q.Currency); !ok {
+			err = err.WithField("currency", "must be a supported ISO 4217 code")
+		}
+		for _, p := range []struct {
+			name  string
+			price *models.Money
+		}{
+			{"minPrice", q.MinPrice},
+			{"maxPrice", q.MaxPrice},
+		} {
+			if p.price != nil && p.price.Currency != q.Currency {
+				err = err.WithField(p.name, "must be in "+q.Currency)
+			}
+		}
+	}
+	err = q.DietaryFilter.validate(err)
+	switch q.Sort {
+	case "", SortByCreated, SortByUpdated, SortByName, SortByPrice:
+	default:
+		err = err.WithField("sort", fmt.Sprintf("unknown sort field %q", q.Sort))
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// match reports if the dish passes every filter
+func (q ListDishesQuery) match(d *models.Dish) bool {
+	price := effectivePrice(d)
+	if q.MinPrice != nil {
+		if c, err := price.Cmp(*q.MinPrice); err != nil || c < 0 {
+			return false
+		}
+	}
+	if q.MaxPrice != nil {
+		if c, err := price.Cmp(*q.MaxPrice); err != nil || c > 0 {
+			return false
+		}
+	}
+	name := strings.ToLower(d.Name)
+	if q.NamePrefix != "" && !strings.HasPrefix(name, strings.ToLower(q.NamePrefix)) {
+		return false
+	}
+	if q.NameContains != "" && !strings.Contains(name, strings.ToLower(q.NameContains)) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && d.Created.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !d.Created.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.CategoryID != "" && d.CategoryID != q.CategoryID {
+		return false
+	}
+	for _, t := range q.Tags {
+		if !d.HasTag(strings.ToLower(strings.TrimSpace(t))) {
+			return false
+		}
+	}
+	return q.DietaryFilter.match(d)
+}
+
+// sortField returns the effective sort field
+func (q ListDishesQuery) sortField() SortField {
+	if q.Sort == "" {
+		return SortByCreated
+	}
+	return q.Sort
+}
+
+// less orders dishes according to the query
+func (q ListDishesQuery) less(a, b *models.Dish) bool {
+	if q.Descending {
+		return lessBy(q.sortField(), b, a)
+	}
+	return lessBy(q.sortField(), a, b)
+}
+
+// lessBy orders dishes ascending by field, then by ID
+func lessBy(field SortField, a, b *models.Dish) bool {
+	switch field {
+	case SortByUpdated:
+		if !a.Updated.Equal(b.Updated) {
+			return a.Updated.Before(b.Updated)
+		}
+	case SortByName:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	case SortByPrice:
+		if pa, pb := effectivePrice(a), effectivePrice(b); pa != pb {
+			return pa.Less(pb)
+		}
+	default:
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// fingerprint identifies the filters and sort order, so a page token can
+// only be used to continue the listing it was issued for.
+func (q ListDishesQuery) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v|%v|%v|%q|%q|%q|%q|%v|%v|%v|%v|%v|%v",
+		q.Currency, moneyString(q.MinPrice), moneyString(q.MaxPrice),
+		q.NamePrefix, q.NameContains, q.CategoryID, models.NormalizeTags(q.Tags), q.DietaryFilter,
+		q.AvailableOnly, q.CreatedAfter.Format(time.RFC3339Nano), q.CreatedBefore.Format(time.RFC3339Nano),
+		q.sortField(), q.Descending)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// effectivePrice is the price a dish is filtered and ordered by, its quoted
+// price if it was quoted
+func effectivePrice(d *models.Dish) models.Money {
+	if d.Quote != nil {
+		return d.Quote.Price
+	}
+	return d.Price
+}
+
+func moneyString(v *models.Money) string {
+	if v == nil {
+		return "-"
+	}
+	return v.String()
+}
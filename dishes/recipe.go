@@ -0,0 +1,179 @@
+// Recipes work out what dishes cost to make, and their nutrition facts.
+package dishes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeffizhungry/polygon/lib/exchange"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Ingredients looks up the ingredients recipes use, it is implemented by
+// ingredients.Service
+type Ingredients interface {
+	GetIngredient(ctx context.Context, id string) (*models.Ingredient, error)
+}
+
+// WithIngredients lets dishes have recipes, and adds a costing with the cost
+// and gross margin, and nutrition facts, to every dish returned. Without
+// ingredients dishes cannot have recipes.
+func WithIngredients(i Ingredients) Option {
+	return func(r *resource) { r.ingredients = i }
+}
+
+// checkRecipe verifies every ingredient in a recipe exists and is measured
+// in a unit the recipe quantity converts to
+func (r *resource) checkRecipe(ctx context.Context, recipe []models.RecipeLine) error {
+	if len(recipe) == 0 {
+		return nil
+	}
+	bad := models.InvalidArgument("invalid dish")
+	if r.ingredients == nil {
+		return bad.WithField("recipe", "no ingredients are configured")
+	}
+	for i, l := range recipe {
+		ingredient, err := r.ingredients.GetIngredient(ctx, l.IngredientID)
+		if models.KindOf(err) == models.KindNotFound {
+			bad = bad.WithField(fmt.Sprintf("recipe[%d].ingredientId", i), "unknown ingredient")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if l.Quantity.Unit.Dimension() != ingredient.Unit.Dimension() {
+			bad = bad.WithField(fmt.Sprintf("recipe[%d].quantity.unit", i),
+				fmt.Sprintf("%v is measured in %v", ingredient.Name, ingredient.Unit))
+		}
+	}
+	if len(bad.Fields) > 0 {
+		return bad
+	}
+	return nil
+}
+
+// annotate sets the availability of a dish, and the costing and nutrition
+// facts of one with a recipe, see checkAvailability, cost and nourish.
+// Dishes whose recipe refers to deleted ingredients have neither costing nor
+// nutrition facts.
+func (r *resource) annotate(ctx context.Context, d *models.Dish) error {
+	d.Costing, d.Nutrition = nil, nil
+	if err := r.checkAvailability(ctx, d); err != nil {
+		return err
+	}
+	if len(d.Recipe) == 0 || r.ingredients == nil {
+		return nil
+	}
+	ingredients := make([]*models.Ingredient, 0, len(d.Recipe))
+	for _, l := range d.Recipe {
+		ingredient, err := r.ingredients.GetIngredient(ctx, l.IngredientID)
+		if models.KindOf(err) == models.KindNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	if err := r.cost(d, ingredients); err != nil {
+		return err
+	}
+	nourish(d, ingredients)
+	return nil
+}
+
+// annotateAll annotates every dish, see annotate
+func (r *resource) annotateAll(ctx context.Context, dishes []models.Dish) error {
+	for i := range dishes {
+		if err := r.annotate(ctx, &dishes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cost sets the costing of a dish from the ingredients of its recipe, in the
+// currency of its base price. Dishes using costs that cannot be converted
+// into that currency are left without a costing.
+func (r *resource) cost(d *models.Dish, ingredients []*models.Ingredient) error {
+	lines := make([]models.CostLine, 0, len(d.Recipe))
+	for i, l := range d.Recipe {
+		ingredient := ingredients[i]
+		cost, err := ingredient.CostOf(l.Quantity)
+		if models.KindOf(err) == models.KindInvalidArgument {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if cost.Currency != d.Price.Currency {
+			if r.rates == nil {
+				return nil
+			}
+			currency := d.Price.Currency
+			cost, err = exchange.Convert(r.rates, cost, currency, r.rounding[currency])
+			if models.KindOf(err) == models.KindNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		lines = append(lines, models.CostLine{
+			IngredientID: ingredient.ID,
+			Name:         ingredient.Name,
+			Quantity:     l.Quantity,
+			Cost:         cost,
+		})
+	}
+	costing, err := models.NewDishCosting(*d, lines)
+	if err != nil {
+		return err
+	}
+	d.Costing = costing
+	return nil
+}
+
+// nourish sets the nutrition facts of a dish from the ingredients of its
+// recipe, a serving being one portion. Dishes using an ingredient without
+// nutrition, or one whose quantity cannot be weighed, are left without.
+func nourish(d *models.Dish, ingredients []*models.Ingredient) {
+	facts := models.NutritionFacts{}
+	for i, l := range d.Recipe {
+		ingredient := ingredients[i]
+		if ingredient.Nutrition == nil {
+			return
+		}
+		grams, err := ingredient.Grams(l.Quantity)
+		if err != nil {
+			return
+		}
+		facts.ServingSize += grams
+		facts.PerServing = facts.PerServing.Add(ingredient.Nutrition.Scale(grams / 100))
+	}
+	d.Nutrition = &facts
+}
+
+func (r *resource) NutritionLabel(ctx context.Context, id string) (*models.NutritionLabel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	dish, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.annotate(ctx, dish); err != nil {
+		return nil, err
+	}
+	if dish.Nutrition == nil {
+		return nil, models.NotFound("dish %v has no nutrition facts", id)
+	}
+	label := dish.Nutrition.Label()
+	return &label, nil
+}
@@ -0,0 +1,74 @@
+// Search implements full-text search over dishes.
+package dishes
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jeffizhungry/polygon/lib/search"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// SearchResult is a dish matching a search, along with its relevance and the
+// matching parts of each field wrapped in <em></em>.
+type SearchResult struct {
+	Dish       models.Dish        `json:"dish"`
+	Score      float64            `json:"score"`
+	Highlights []search.Highlight `json:"highlights,omitempty"`
+}
+
+// searchFields returns the text indexed for a dish
+func searchFields(d *models.Dish) []search.Field {
+	return []search.Field{
+		{Name: "name", Text: d.Name, Weight: 1},
+		{Name: "tags", Text: strings.Join(d.Tags, " "), Weight: 0.5},
+	}
+}
+
+func (r *resource) SearchDishes(ctx context.Context, query string, limit int, filter DietaryFilter) ([]SearchResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if strings.TrimSpace(query) == "" {
+		return nil, models.InvalidArgument("invalid search").WithField("q", "cannot be empty")
+	}
+	if limit < 0 {
+		return nil, models.InvalidArgument("invalid search").WithField("limit", "cannot be negative")
+	}
+	limit = c.pageSize(limit)
+	if err := filter.validate(models.InvalidArgument("invalid search")); len(err.Fields) > 0 {
+		return nil, err
+	}
+
+	// Search
+	hits := c.search.SearchFunc(query, limit, func(id string) bool {
+		dish, err := r.repo.Get(c.id, id)
+		return err == nil && filter.match(dish)
+	})
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		dish, err := r.repo.Get(c.id, hit.ID)
+		if err != nil {
+			continue
+		}
+		results = append(results, SearchResult{
+			Dish:       *dish,
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+
+	// Annotate like ListDishes does
+	for i := range results {
+		if err := r.annotate(ctx, &results[i].Dish); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
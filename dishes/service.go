@@ -3,150 +3,553 @@ package dishes
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/jeffizhungry/polygon/dishes/storage"
+	"github.com/jeffizhungry/polygon/lib/exchange"
+	"github.com/jeffizhungry/polygon/lib/idempotency"
+	"github.com/jeffizhungry/polygon/lib/random"
+	"github.com/jeffizhungry/polygon/lib/tenant"
 	"github.com/jeffizhungry/polygon/models"
-	"github.com/pkg/errors"
 )
 
 const (
-	maxPageSize = 5
+	defaultPageSize       = 10
+	defaultMaxPageSize    = 100
+	defaultIdempotencyTTL = 24 * time.Hour
 )
 
 // NOTE(Jeff): The goal of this interface is to provide a standard interface
 // for implementing the service and building a client library for communicating
 // with this service.
+//
+// Every operation acts for the tenant carried by the context, see
+// tenant.NewContext. Dishes and categories of other tenants are never seen,
+// looking one up fails with a not found error.
 type Service interface {
+
+	// CreateDish is idempotent when the context carries an idempotency key,
+	// see WithIdempotencyKey.
 	CreateDish(ctx context.Context, d models.DishParams) (*models.Dish, error)
-	GetDish(ctx context.Context, id string) (*models.Dish, error)
+
+	// GetDish quotes the dish's price in currency when one is given, see
+	// models.Dish.Quote.
+	GetDish(ctx context.Context, id string, currency string) (*models.Dish, error)
+
+	// UpdateDish and DeleteDish fail with a conflict error if the dish is no
+	// longer at the expected version. Set DishParams.Version, or pass a
+	// version of 0 to DeleteDish, to opt out of the check.
 	UpdateDish(ctx context.Context, id string, d models.DishParams) (*models.Dish, error)
-	DeleteDish(ctx context.Context, id string) error
-	ListDishes(ctx context.Context, offset string, limit int) ([]models.Dish, error)
+	DeleteDish(ctx context.Context, id string, version int64) error
+
+	// Categories group dishes into menu sections. ListCategories orders
+	// them by Position, then Name.
+	CreateCategory(ctx context.Context, c models.CategoryParams) (*models.Category, error)
+	GetCategory(ctx context.Context, id string) (*models.Category, error)
+	UpdateCategory(ctx context.Context, id string, c models.CategoryParams) (*models.Category, error)
+	ListCategories(ctx context.Context) ([]models.Category, error)
+
+	// DeleteCategory fails with a conflict error while dishes are in the
+	// category, unless reassignTo names another category to move them to.
+	DeleteCategory(ctx context.Context, id string, version int64, reassignTo string) error
+
+	// PriceDish prices the dish as configured with modifiers, in the
+	// currency of its base price
+	PriceDish(ctx context.Context, id string, c models.Configuration) (*models.PriceBreakdown, error)
+
+	// NutritionLabel renders the nutrition facts of a dish as a label. It
+	// fails with a not found error if the dish has none, see
+	// models.Dish.Nutrition.
+	NutritionLabel(ctx context.Context, id string) (*models.NutritionLabel, error)
+
+	// SearchDishes returns up to limit dishes matching the query text and
+	// the dietary filter, most relevant first. Matching is case and accent
+	// insensitive, tolerates typos and treats the last word as a prefix.
+	// Dishes are annotated just like ListDishes returns them.
+	SearchDishes(ctx context.Context, query string, limit int, filter DietaryFilter) ([]SearchResult, error)
+
+	// ListDishes returns a page of dishes matching the query. Pass the
+	// returned page token along with the same query to fetch the following
+	// page, an empty token means there are no more dishes. A PageSize of 0
+	// selects the default page size.
+	ListDishes(ctx context.Context, q ListDishesQuery) ([]models.Dish, string, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithMaxPageSize caps the number of dishes returned by a single ListDishes call
+func WithMaxPageSize(n int) Option {
+	return func(r *resource) { r.maxPageSize = n }
+}
+
+// WithDefaultPageSize sets the page size used when ListDishes is called with
+// a PageSize of 0
+func WithDefaultPageSize(n int) Option {
+	return func(r *resource) { r.defaultPageSize = n }
+}
+
+// WithCursorSecret sets the key used to sign page tokens. Tokens only remain
+// valid across restarts, or across replicas, if they share the same secret.
+func WithCursorSecret(secret []byte) Option {
+	return func(r *resource) { r.cursors = cursorCodec{secret: secret} }
+}
+
+// WithIdempotencyTTL sets how long idempotency keys are remembered
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(r *resource) { r.idempotencyKeys = idempotency.NewStore(ttl) }
 }
 
-func NewService() Service {
-	return &resource{
-		local: make(map[string]*models.Dish),
-		mu:    &sync.RWMutex{},
+// WithRepository sets where dishes are stored, by default they are kept in
+// memory only
+func WithRepository(repo storage.Repository) Option {
+	return func(r *resource) { r.repo = repo }
+}
+
+func NewService(opts ...Option) Service {
+	r := &resource{
+		repo:            storage.NewMemory(),
+		catalogs:        make(map[string]*catalog),
+		catalogsMu:      &sync.Mutex{},
+		mu:              &sync.RWMutex{},
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     defaultMaxPageSize,
+		cursors:         cursorCodec{secret: []byte(random.SecureString(32))},
+		idempotencyKeys: idempotency.NewStore(defaultIdempotencyTTL),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	if r.defaultPageSize > r.maxPageSize {
+		r.defaultPageSize = r.maxPageSize
+	}
+
+	// Build indexes over the dishes already stored
+	for _, dish := range r.repo.All() {
+		dish := dish
+		c := r.catalog(dish.TenantID)
+		c.secondaryIndex.Put(&dish)
+		c.categorize(dish.ID, "", dish.CategoryID)
+		c.search.Add(dish.ID, searchFields(&dish)...)
+	}
+	return r
 }
 
 type resource struct {
-	local          map[string]*models.Dish
-	secondaryIndex []models.Dish
-	mu             *sync.RWMutex
+	repo storage.Repository
+	mu   *sync.RWMutex
+
+	// catalogs maps tenant IDs to the indexes over their dishes. Catalogs
+	// are created on first use, even by readers, so the map has its own
+	// lock.
+	catalogs   map[string]*catalog
+	catalogsMu *sync.Mutex
+
+	// tenants lists the tenants served, see WithTenants
+	tenants tenant.Registry
+
+	// idempotencyKeys maps keys, scoped to their tenant, to the dish created
+	// under them
+	idempotencyKeys *idempotency.Store
+
+	// rates and rounding convert prices into requested currencies
+	rates    exchange.Provider
+	rounding map[string]models.Rounding
+
+	// ingredients prices recipes, see WithIngredients
+	ingredients Ingredients
+
+	// inventory decides which dishes can be ordered, see WithInventory
+	inventory Inventory
+
+	defaultPageSize int
+	maxPageSize     int
+	cursors         cursorCodec
 }
 
 func (r *resource) CreateDish(ctx context.Context, d models.DishParams) (*models.Dish, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay earlier calls with the same idempotency key
+	key, idempotent := IdempotencyKeyFrom(ctx)
+	fingerprint := ""
+	if idempotent {
+		if err := validateIdempotencyKey(key); err != nil {
+			return nil, err
+		}
+		fingerprint = paramsFingerprint(d)
+		if rec, found := r.idempotencyKeys.Get(c.idempotencyKey(key)); found {
+			if rec.Fingerprint != fingerprint {
+				return nil, models.Conflict("idempotency key was already used with different params")
+			}
+			dish := rec.Value.(models.Dish)
+			_, err := r.repo.Get(c.id, dish.ID)
+			if models.KindOf(err) == models.KindNotFound {
+				return nil, models.Conflict("dish %v created with the idempotency key was deleted", dish.ID)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := r.annotate(ctx, &dish); err != nil {
+				return nil, err
+			}
+			return &dish, nil
+		}
+	}
+
 	// Create model
 	dish := models.NewDish(d)
+	dish.TenantID = c.id
 
 	// Validate
 	if err := dish.Validate(); err != nil {
 		return nil, err
 	}
+	if err := c.checkCurrency(dish); err != nil {
+		return nil, err
+	}
+	if err := r.checkCategory(c.id, dish.CategoryID); err != nil {
+		return nil, err
+	}
+	if err := r.checkRecipe(ctx, dish.Recipe); err != nil {
+		return nil, err
+	}
 
 	// Save model
-	r.local[dish.ID] = dish
-	r.secondaryIndex = append(r.secondaryIndex, *dish)
+	if err := r.repo.Put(*dish); err != nil {
+		return nil, err
+	}
+	c.categorize(dish.ID, "", dish.CategoryID)
+
+	// Insert into secondary, the index keeps its own copy since the caller
+	// may modify the one returned
+	indexed := *dish
+	c.secondaryIndex.Put(&indexed)
+
+	// Index for search
+	c.search.Add(dish.ID, searchFields(dish)...)
+
+	// Remember the dish as created for retries
+	if idempotent {
+		r.idempotencyKeys.Put(c.idempotencyKey(key), fingerprint, *dish)
+	}
+	if err := r.annotate(ctx, dish); err != nil {
+		return nil, err
+	}
 	return dish, nil
 }
 
-func (r *resource) GetDish(ctx context.Context, id string) (*models.Dish, error) {
+func (r *resource) GetDish(ctx context.Context, id string, currency string) (*models.Dish, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if currency != "" {
+		if err := validateCurrency(currency); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get model
-	dish, found := r.local[id]
-	if !found {
-		return nil, models.ErrNotFound
+	dish, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return nil, err
 	}
-	return dish, nil
+
+	// Quote and annotate
+	quoted, err := r.quote(dish, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.annotate(ctx, &quoted); err != nil {
+		return nil, err
+	}
+	return &quoted, nil
 }
 
 func (r *resource) UpdateDish(ctx context.Context, id string, params models.DishParams) (*models.Dish, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get model
-	dish, found := r.local[id]
-	if !found {
-		return nil, models.ErrNotFound
+	current, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return nil, err
+	}
+	if params.Version != nil {
+		if err := current.CheckVersion(*params.Version); err != nil {
+			return nil, err
+		}
 	}
 
-	// Update model
+	// Update a copy, so a failed validation leaves the stored model alone
+	dish := *current
 	if params.Name != nil {
 		dish.Name = *params.Name
 	}
 	if params.Price != nil {
 		dish.Price = *params.Price
 	}
+	if params.Prices != nil {
+		dish.Prices = *params.Prices
+	}
+	if params.CategoryID != nil {
+		dish.CategoryID = *params.CategoryID
+	}
+	if params.Tags != nil {
+		dish.Tags = models.NormalizeTags(*params.Tags)
+	}
+	if params.ModifierGroups != nil {
+		dish.ModifierGroups = *params.ModifierGroups
+	}
+	if params.Allergens != nil {
+		dish.Allergens = *params.Allergens
+	}
+	if params.Diets != nil {
+		dish.Diets = *params.Diets
+	}
+	if params.Recipe != nil {
+		dish.Recipe = *params.Recipe
+	}
+	if params.TaxCategory != nil {
+		dish.TaxCategory = *params.TaxCategory
+	}
+	dish.Version++
+	dish.Updated = time.Now()
 
 	// Validate
 	if err := dish.Validate(); err != nil {
 		return nil, err
 	}
+	if err := c.checkCurrency(&dish); err != nil {
+		return nil, err
+	}
+	if err := r.checkCategory(c.id, dish.CategoryID); err != nil {
+		return nil, err
+	}
 
-	// Update local
-	r.local[id] = dish
+	// Ingredients deleted since are tolerated, unless the recipe is replaced
+	if params.Recipe != nil {
+		if err := r.checkRecipe(ctx, dish.Recipe); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save model
+	if err := r.repo.Put(dish); err != nil {
+		return nil, err
+	}
+	c.categorize(dish.ID, current.CategoryID, dish.CategoryID)
 
 	// Update secondary
-	for i := range r.secondaryIndex {
-		if r.secondaryIndex[i].ID == id {
-			r.secondaryIndex[i] = *dish
-		}
+	indexed := dish
+	c.secondaryIndex.Put(&indexed)
+
+	// Reindex for search
+	c.search.Add(dish.ID, searchFields(&dish)...)
+	if err := r.annotate(ctx, &dish); err != nil {
+		return nil, err
 	}
-	return dish, nil
+	return &dish, nil
 }
 
-func (r *resource) DeleteDish(ctx context.Context, id string) error {
+func (r *resource) DeleteDish(ctx context.Context, id string, version int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Check if it exists
-	_, found := r.local[id]
-	if !found {
-		return models.ErrNotFound
+	dish, err := r.repo.Get(c.id, id)
+	if err != nil {
+		return err
+	}
+	if err := dish.CheckVersion(version); err != nil {
+		return err
 	}
 
-	// Delete from local
-	delete(r.local, id)
+	// Delete model
+	if err := r.repo.Delete(c.id, id); err != nil {
+		return err
+	}
 
 	// Delete from secondary
-	for i := range r.secondaryIndex {
-		if r.secondaryIndex[i].ID == id {
-			r.secondaryIndex = append(r.secondaryIndex[:i], r.secondaryIndex[i+1:]...)
-		}
-	}
+	c.secondaryIndex.Delete(dish)
+	c.categorize(dish.ID, dish.CategoryID, "")
+
+	// Delete from search
+	c.search.Remove(id)
 	return nil
 }
 
-func (r *resource) ListDishes(ctx context.Context, offset string, limit int) ([]models.Dish, error) {
+func (r *resource) ListDishes(ctx context.Context, q ListDishesQuery) ([]models.Dish, string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if limit > maxPageSize {
-		return nil, errors.Errorf("max page size is ", maxPageSize)
+	c, err := r.tenant(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Validate
+	if err := q.Validate(); err != nil {
+		return nil, "", err
 	}
 
+	// Determine page size
+	pageSize := c.pageSize(q.PageSize)
+
+	// Decode the cursor
+	var anchor *models.Dish
+	if q.PageToken != "" {
+		cur, err := r.cursors.Decode(q.PageToken, c.id)
+		if err != nil {
+			return nil, "", err
+		}
+		if cur.Query != q.fingerprint() {
+			return nil, "", ErrInvalidPageToken
+		}
+		anchor = cur.anchor()
+	}
+
+	// Collect one dish more than requested to know if another page follows
 	var set []models.Dish
-	if offset == "" {
-		set = r.secondaryIndex
+	if q.sortField() == SortByCreated {
+		set, err = r.scanCreated(ctx, c, q, anchor, pageSize+1)
 	} else {
-		for i := range r.secondaryIndex {
-			if r.secondaryIndex[i].ID == offset {
-				set = r.secondaryIndex[i+1:]
+		set, err = r.sortAll(ctx, c, q, anchor)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Limit set to page size
+	token := ""
+	if len(set) > pageSize {
+		set = set[:pageSize]
+		token = r.cursors.Encode(newCursor(c.id, q, set[len(set)-1]))
+	}
+	if err := r.annotateAll(ctx, set); err != nil {
+		return nil, "", err
+	}
+	return set, token, nil
+}
+
+// keep quotes the dish in the query's currency and reports if it passes the
+// filters of the query, including AvailableOnly which needs the inventory.
+// Dishes that cannot be quoted in the currency are left out rather than
+// failing the whole listing.
+func (r *resource) keep(ctx context.Context, q ListDishesQuery, d *models.Dish) (models.Dish, bool, error) {
+	dish, err := r.quote(d, q.Currency)
+	if models.KindOf(err) == models.KindInvalidArgument {
+		return dish, false, nil
+	}
+	if err != nil {
+		return dish, false, err
+	}
+	if !q.match(&dish) {
+		return dish, false, nil
+	}
+	if !q.AvailableOnly {
+		return dish, true, nil
+	}
+	keep, err := r.available(ctx, &dish)
+	return dish, keep, err
+}
+
+// scanCreated walks the tenant's secondary index in creation order, starting
+// after the anchor or at the edge of the created range, until limit dishes
+// matched. Listing is O(log n + scanned) this way, rather than O(n).
+func (r *resource) scanCreated(ctx context.Context, c *catalog, q ListDishesQuery, anchor *models.Dish, limit int) ([]models.Dish, error) {
+	ix := c.secondaryIndex
+
+	// Seek
+	var n *indexNode
+	switch {
+	case anchor != nil && q.Descending:
+		n = ix.Before(anchor)
+	case anchor != nil:
+		n = ix.After(anchor)
+	case q.Descending && !q.CreatedBefore.IsZero():
+		n = ix.Before(&models.Dish{Created: q.CreatedBefore})
+	case q.Descending:
+		n = ix.Last()
+	case !q.CreatedAfter.IsZero():
+		n = ix.Ceiling(&models.Dish{Created: q.CreatedAfter})
+	default:
+		n = ix.First()
+	}
+
+	// Scan, stopping early at the far end of the created range
+	set := []models.Dish{}
+	for n != nil && len(set) < limit {
+		if q.Descending {
+			if !q.CreatedAfter.IsZero() && n.dish.Created.Before(q.CreatedAfter) {
+				break
+			}
+		} else {
+			if !q.CreatedBefore.IsZero() && !n.dish.Created.Before(q.CreatedBefore) {
 				break
 			}
 		}
+		dish, keep, err := r.keep(ctx, q, n.dish)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			set = append(set, dish)
+		}
+		if q.Descending {
+			n = n.Prev()
+		} else {
+			n = n.Next()
+		}
 	}
+	return set, nil
+}
 
-	// Limit set to page size
-	if len(set) > limit {
-		return set[:limit], nil
+// sortAll filters and sorts every dish of the tenant for orders the secondary
+// index does not cover, then drops the dishes up to and including the anchor
+func (r *resource) sortAll(ctx context.Context, c *catalog, q ListDishesQuery, anchor *models.Dish) ([]models.Dish, error) {
+	set := []models.Dish{}
+	for n := c.secondaryIndex.First(); n != nil; n = n.Next() {
+		dish, keep, err := r.keep(ctx, q, n.dish)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			set = append(set, dish)
+		}
+	}
+	sort.Slice(set, func(i, j int) bool {
+		return q.less(&set[i], &set[j])
+	})
+	if anchor != nil {
+		i := sort.Search(len(set), func(i int) bool {
+			return q.less(anchor, &set[i])
+		})
+		set = set[i:]
 	}
 	return set, nil
 }
@@ -1,13 +1,21 @@
+//go:build integration
 // +build integration
+
 package dishes
 
 import (
 	"context"
 	"fmt"
-	"polymail-api/lib/utils"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 
+	"github.com/jeffizhungry/polygon/dishes/storage"
+	"github.com/jeffizhungry/polygon/ingredients"
+	"github.com/jeffizhungry/polygon/inventory"
+	"github.com/jeffizhungry/polygon/lib/exchange"
+	"github.com/jeffizhungry/polygon/lib/tenant"
 	"github.com/jeffizhungry/polygon/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,7 +25,16 @@ func makeString(s string) *string {
 	return &s
 }
 
-func makeFloat64(v float64) *float64 {
+func makePrice(amount string) *models.Money {
+	m := models.MustParseMoney(amount, "USD")
+	return &m
+}
+
+func makeInt64(v int64) *int64 {
+	return &v
+}
+
+func makeInt(v int) *int {
 	return &v
 }
 
@@ -30,15 +47,15 @@ func TestIntegrationDishesCRUD(t *testing.T) {
 		"the raised the prices!! 😨": {
 			params: models.DishParams{
 				Name:  makeString("Pasta"),
-				Price: makeFloat64(10.0),
+				Price: makePrice("10"),
 			},
 			updateParams: models.DishParams{
-				Price: makeFloat64(20.0),
+				Price: makePrice("20"),
 			},
 		},
 		"the unknown dish": {
 			params: models.DishParams{
-				Price: makeFloat64(10.0),
+				Price: makePrice("10"),
 			},
 			errorExpected: true,
 		},
@@ -58,7 +75,7 @@ func TestIntegrationDishesCRUD(t *testing.T) {
 		require.NotNil(t, dish, msg)
 
 		// Get
-		actual, err := s.GetDish(context.TODO(), dish.ID)
+		actual, err := s.GetDish(context.TODO(), dish.ID, "")
 		require.NoError(t, err, msg)
 		if !assert.True(t, reflect.DeepEqual(dish, actual), msg) {
 			fmt.Println("== EXPECTED ==")
@@ -72,7 +89,7 @@ func TestIntegrationDishesCRUD(t *testing.T) {
 		require.NoError(t, err, msg)
 
 		// Get
-		actual, err = s.GetDish(context.TODO(), dish.ID)
+		actual, err = s.GetDish(context.TODO(), dish.ID, "")
 		require.NoError(t, err, msg)
 		if !assert.True(t, reflect.DeepEqual(updatedDish, actual), msg) {
 			fmt.Println("== EXPECTED ==")
@@ -82,26 +99,28 @@ func TestIntegrationDishesCRUD(t *testing.T) {
 		}
 
 		// Delete
-		err = s.DeleteDish(context.TODO(), dish.ID)
+		err = s.DeleteDish(context.TODO(), dish.ID, 0)
 		require.NoError(t, err, msg)
 
 		// Get
-		_, err = s.GetDish(context.TODO(), dish.ID)
+		_, err = s.GetDish(context.TODO(), dish.ID, "")
 		require.Equal(t, models.ErrNotFound, err, msg)
 
 		// Delete
-		err = s.DeleteDish(context.TODO(), dish.ID)
+		err = s.DeleteDish(context.TODO(), dish.ID, 0)
 		require.Equal(t, models.ErrNotFound, err, msg)
 	}
 }
 
 func TestIntegrationDishesList(t *testing.T) {
-	t.Skip()
+	const maxPageSize = 5
 
-	// TODO(Jeff): Fix this later 😛
 	testcases := map[string]struct {
 		count int
 	}{
+		"empty": {
+			count: 0,
+		},
 		"max page size - 1": {
 			count: maxPageSize - 1,
 		},
@@ -113,35 +132,36 @@ func TestIntegrationDishesList(t *testing.T) {
 		},
 	}
 
-	s := NewService()
 	for msg, tc := range testcases {
+		s := NewService(WithMaxPageSize(maxPageSize), WithDefaultPageSize(2))
 
 		// Store set
 		var expected []models.Dish
 		for i := 0; i < tc.count; i++ {
 			dish, err := s.CreateDish(context.TODO(), models.DishParams{
 				Name:  makeString("Pasta"),
-				Price: makeFloat64(10.0),
+				Price: makePrice("10"),
 			})
 			require.NoError(t, err, msg)
 			expected = append(expected, *dish)
 		}
 
-		// Test different page sizes
-		for pagesize := 0; pagesize < maxPageSize; pagesize++ {
+		// Test different page sizes, including the default and a page size
+		// larger than the max
+		for pagesize := 0; pagesize <= maxPageSize+1; pagesize++ {
 
 			// Paginate
 			var actual []models.Dish
-			var page []models.Dish
-			var offset string
-
-			for once := true; once || len(page) > 0; once = false {
-				page, err := s.ListDishes(context.TODO(), offset, pagesize)
+			var token string
+			for {
+				page, next, err := s.ListDishes(context.TODO(), ListDishesQuery{PageToken: token, PageSize: pagesize})
 				require.NoError(t, err, msg)
-				if len(page) > 0 {
-					actual = append(actual, page...)
-					offset = page[len(page)-1].ID
+				require.True(t, len(page) <= maxPageSize, msg)
+				actual = append(actual, page...)
+				if next == "" {
+					break
 				}
+				token = next
 			}
 
 			// Compare
@@ -156,11 +176,879 @@ func TestIntegrationDishesList(t *testing.T) {
 					fmt.Println("== ACTUAL ==")
 					fmt.Printf("%+v\n", actual[i])
 					break
-
-					utils.PPrintln("expected: ", expected)
-					utils.PPrintln("actual: ", actual)
 				}
 			}
 		}
 	}
 }
+
+func TestIntegrationDishesListAfterAnchorDeleted(t *testing.T) {
+	s := NewService()
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		dish, err := s.CreateDish(context.TODO(), models.DishParams{
+			Name:  makeString("Pasta"),
+			Price: makePrice("10"),
+		})
+		require.NoError(t, err)
+		ids = append(ids, dish.ID)
+	}
+
+	// First page ends with the second dish
+	page, token, err := s.ListDishes(context.TODO(), ListDishesQuery{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.NotEmpty(t, token)
+
+	// Deleting the anchor must not lose our place
+	require.NoError(t, s.DeleteDish(context.TODO(), ids[1], 0))
+	page, token, err = s.ListDishes(context.TODO(), ListDishesQuery{PageToken: token, PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, ids[2], page[0].ID)
+	assert.Equal(t, ids[3], page[1].ID)
+	assert.Empty(t, token)
+}
+
+func TestIntegrationDishesListInvalidToken(t *testing.T) {
+	s := NewService()
+	other := NewService()
+
+	for _, svc := range []Service{s, other} {
+		for i := 0; i < 3; i++ {
+			_, err := svc.CreateDish(context.TODO(), models.DishParams{
+				Name:  makeString("Pasta"),
+				Price: makePrice("10"),
+			})
+			require.NoError(t, err)
+		}
+	}
+	_, token, err := other.ListDishes(context.TODO(), ListDishesQuery{PageSize: 1})
+	require.NoError(t, err)
+
+	for _, bad := range []string{"garbage", token, token + "x"} {
+		_, _, err := s.ListDishes(context.TODO(), ListDishesQuery{PageToken: bad, PageSize: 1})
+		require.Equal(t, ErrInvalidPageToken, err, bad)
+	}
+}
+
+func TestIntegrationDishesListFilterAndSort(t *testing.T) {
+	s := NewService()
+
+	for _, d := range []struct {
+		name  string
+		price string
+	}{
+		{"Spaghetti", "12"},
+		{"Pasta Carbonara", "14.50"},
+		{"Pasta Pomodoro", "9"},
+		{"Tiramisu", "6.25"},
+		{"Pasta Pesto", "11.99"},
+	} {
+		_, err := s.CreateDish(context.TODO(), models.DishParams{
+			Name:  makeString(d.name),
+			Price: makePrice(d.price),
+		})
+		require.NoError(t, err)
+	}
+
+	testcases := map[string]struct {
+		query    ListDishesQuery
+		expected []string
+	}{
+		"name prefix by price": {
+			query: ListDishesQuery{
+				NamePrefix: "pasta",
+				Sort:       SortByPrice,
+			},
+			expected: []string{"Pasta Pomodoro", "Pasta Pesto", "Pasta Carbonara"},
+		},
+		"price range by name descending": {
+			query: ListDishesQuery{
+				MinPrice:   makePrice("9"),
+				MaxPrice:   makePrice("12"),
+				Sort:       SortByName,
+				Descending: true,
+			},
+			expected: []string{"Spaghetti", "Pasta Pomodoro", "Pasta Pesto"},
+		},
+		"name contains": {
+			query: ListDishesQuery{
+				NameContains: "ti",
+				Sort:         SortByName,
+			},
+			expected: []string{"Spaghetti", "Tiramisu"},
+		},
+	}
+
+	for msg, tc := range testcases {
+
+		// Paginate one dish at a time to exercise cursors
+		var actual []string
+		q := tc.query
+		q.PageSize = 1
+		for {
+			page, next, err := s.ListDishes(context.TODO(), q)
+			require.NoError(t, err, msg)
+			for _, d := range page {
+				actual = append(actual, d.Name)
+			}
+			if next == "" {
+				break
+			}
+			q.PageToken = next
+		}
+		assert.Equal(t, tc.expected, actual, msg)
+	}
+
+	// Tokens cannot be replayed against a different query
+	_, token, err := s.ListDishes(context.TODO(), ListDishesQuery{PageSize: 1, Sort: SortByName})
+	require.NoError(t, err)
+	_, _, err = s.ListDishes(context.TODO(), ListDishesQuery{PageSize: 1, Sort: SortByPrice, PageToken: token})
+	require.Equal(t, ErrInvalidPageToken, err)
+}
+
+func TestIntegrationDishesSearch(t *testing.T) {
+	s := NewService()
+
+	create := func(name string) *models.Dish {
+		dish, err := s.CreateDish(context.TODO(), models.DishParams{
+			Name:  makeString(name),
+			Price: makePrice("10"),
+		})
+		require.NoError(t, err)
+		return dish
+	}
+	spaghetti := create("Spaghetti Bolognese")
+	brulee := create("Crème Brûlée")
+	create("Tiramisu")
+
+	// Typo
+	results, err := s.SearchDishes(context.TODO(), "spagheti", 10, DietaryFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, spaghetti.ID, results[0].Dish.ID)
+	assert.Equal(t, "<em>Spaghetti</em> Bolognese", results[0].Highlights[0].Value)
+
+	// Accents and autocomplete
+	results, err = s.SearchDishes(context.TODO(), "creme bru", 10, DietaryFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, brulee.ID, results[0].Dish.ID)
+
+	// Updates are reflected
+	_, err = s.UpdateDish(context.TODO(), spaghetti.ID, models.DishParams{Name: makeString("Penne Arrabbiata")})
+	require.NoError(t, err)
+	results, err = s.SearchDishes(context.TODO(), "spaghetti", 10, DietaryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = s.SearchDishes(context.TODO(), "penne", 10, DietaryFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Penne Arrabbiata", results[0].Dish.Name)
+
+	// Deletes are reflected
+	require.NoError(t, s.DeleteDish(context.TODO(), spaghetti.ID, 0))
+	results, err = s.SearchDishes(context.TODO(), "penne", 10, DietaryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Empty queries are rejected
+	_, err = s.SearchDishes(context.TODO(), " ", 10, DietaryFilter{})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
+
+func TestIntegrationDishesVersions(t *testing.T) {
+	s := NewService()
+
+	dish, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), dish.Version)
+	require.Equal(t, dish.Created, dish.Updated)
+
+	// Two managers read version 1, the first update wins
+	updated, err := s.UpdateDish(context.TODO(), dish.ID, models.DishParams{
+		Price:   makePrice("12"),
+		Version: makeInt64(1),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.True(t, updated.Updated.After(dish.Updated))
+
+	_, err = s.UpdateDish(context.TODO(), dish.ID, models.DishParams{
+		Price:   makePrice("14"),
+		Version: makeInt64(1),
+	})
+	require.Equal(t, models.KindConflict, models.KindOf(err))
+
+	// A failed update changes nothing
+	_, err = s.UpdateDish(context.TODO(), dish.ID, models.DishParams{
+		Name: makeString(""),
+	})
+	require.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	actual, err := s.GetDish(context.TODO(), dish.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, updated, actual)
+
+	// Stale deletes are rejected too
+	err = s.DeleteDish(context.TODO(), dish.ID, 1)
+	require.Equal(t, models.KindConflict, models.KindOf(err))
+	require.NoError(t, s.DeleteDish(context.TODO(), dish.ID, 2))
+}
+
+func TestIntegrationDishesIdempotency(t *testing.T) {
+	s := NewService()
+	params := models.DishParams{
+		Name:  makeString("Pasta"),
+		Price: makePrice("10"),
+	}
+
+	// Retries return the original dish
+	ctx := WithIdempotencyKey(context.TODO(), "retry-me")
+	dish, err := s.CreateDish(ctx, params)
+	require.NoError(t, err)
+	retried, err := s.CreateDish(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, dish, retried)
+
+	page, _, err := s.ListDishes(context.TODO(), ListDishesQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+
+	// Reusing the key for something else is a conflict
+	_, err = s.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pizza"),
+		Price: makePrice("10"),
+	})
+	require.Equal(t, models.KindConflict, models.KindOf(err))
+
+	// Without a key every call creates a dish
+	other, err := s.CreateDish(context.TODO(), params)
+	require.NoError(t, err)
+	assert.NotEqual(t, dish.ID, other.ID)
+
+	// Retries after the dish was deleted do not bring it back
+	require.NoError(t, s.DeleteDish(context.TODO(), dish.ID, 0))
+	_, err = s.CreateDish(ctx, params)
+	assert.Equal(t, models.Conflict("dish %v created with the idempotency key was deleted", dish.ID), err)
+	_, err = s.GetDish(context.TODO(), dish.ID, "")
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+}
+
+func TestIntegrationDishesFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dishes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repo, err := storage.OpenFile(dir, storage.FileOptions{})
+	require.NoError(t, err)
+	s := NewService(WithRepository(repo))
+
+	var expected []models.Dish
+	for _, name := range []string{"Pasta", "Pizza", "Salad"} {
+		dish, err := s.CreateDish(context.TODO(), models.DishParams{
+			Name:  makeString(name),
+			Price: makePrice("10"),
+		})
+		require.NoError(t, err)
+		expected = append(expected, *dish)
+	}
+	require.NoError(t, s.DeleteDish(context.TODO(), expected[1].ID, 0))
+	expected = append(expected[:1], expected[2:]...)
+	require.NoError(t, repo.Close())
+
+	// Restart
+	repo, err = storage.OpenFile(dir, storage.FileOptions{})
+	require.NoError(t, err)
+	defer repo.Close()
+	s = NewService(WithRepository(repo))
+
+	page, _, err := s.ListDishes(context.TODO(), ListDishesQuery{})
+	require.NoError(t, err)
+	require.Len(t, page, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].ID, page[i].ID)
+		assert.True(t, expected[i].Created.Equal(page[i].Created))
+	}
+	results, err := s.SearchDishes(context.TODO(), "salad", 10, DietaryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestIntegrationDishesCurrencies(t *testing.T) {
+	rates, err := exchange.NewTable("USD", map[string]string{"EUR": "0.9", "JPY": "150"})
+	require.NoError(t, err)
+	s := NewService(
+		WithExchangeRates(rates),
+		WithRounding(map[string]models.Rounding{"JPY": {Mode: models.RoundUp, Increment: 100}}),
+	)
+
+	// Pasta has a local euro price, pizza is converted
+	pasta, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:   makeString("Pasta"),
+		Price:  makePrice("10"),
+		Prices: &[]models.Money{models.MustParseMoney("9.50", "EUR")},
+	})
+	require.NoError(t, err)
+	pizza, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:  makeString("Pizza"),
+		Price: makePrice("12"),
+	})
+	require.NoError(t, err)
+
+	actual, err := s.GetDish(context.TODO(), pasta.ID, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, &models.PriceQuote{Price: models.MustParseMoney("9.50", "EUR")}, actual.Quote)
+	actual, err = s.GetDish(context.TODO(), pizza.ID, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, &models.PriceQuote{Price: models.MustParseMoney("10.80", "EUR"), Converted: true}, actual.Quote)
+	actual, err = s.GetDish(context.TODO(), pizza.ID, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("1800", "JPY"), actual.Quote.Price)
+	actual, err = s.GetDish(context.TODO(), pizza.ID, "")
+	require.NoError(t, err)
+	assert.Nil(t, actual.Quote)
+
+	// Unsupported currencies
+	_, err = s.GetDish(context.TODO(), pizza.ID, "GBP")
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	_, err = s.GetDish(context.TODO(), pizza.ID, "XXX")
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+
+	// Filter and sort on quoted prices
+	page, _, err := s.ListDishes(context.TODO(), ListDishesQuery{
+		Currency:   "EUR",
+		MinPrice:   &models.Money{Amount: 1000, Currency: "EUR"},
+		Sort:       SortByPrice,
+		Descending: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, pizza.ID, page[0].ID)
+
+	page, token, err := s.ListDishes(context.TODO(), ListDishesQuery{Currency: "EUR", Sort: SortByPrice, PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, pasta.ID, page[0].ID)
+	page, _, err = s.ListDishes(context.TODO(), ListDishesQuery{Currency: "EUR", Sort: SortByPrice, PageSize: 1, PageToken: token})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, pizza.ID, page[0].ID)
+
+	_, _, err = s.ListDishes(context.TODO(), ListDishesQuery{Currency: "EUR", MinPrice: makePrice("1")})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+
+	// Dishes that cannot be quoted are left out of listings
+	page, _, err = s.ListDishes(context.TODO(), ListDishesQuery{Currency: "GBP"})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	unconverted := NewService()
+	_, err = unconverted.CreateDish(context.TODO(), models.DishParams{Name: makeString("Pizza"), Price: makePrice("12")})
+	require.NoError(t, err)
+	local, err := unconverted.CreateDish(context.TODO(), models.DishParams{
+		Name:   makeString("Pasta"),
+		Price:  makePrice("10"),
+		Prices: &[]models.Money{models.MustParseMoney("9.50", "EUR")},
+	})
+	require.NoError(t, err)
+	page, _, err = unconverted.ListDishes(context.TODO(), ListDishesQuery{Currency: "EUR"})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, local.ID, page[0].ID)
+
+	// Price lists are validated
+	_, err = s.UpdateDish(context.TODO(), pasta.ID, models.DishParams{
+		Prices: &[]models.Money{
+			models.MustParseMoney("9.50", "EUR"),
+			models.MustParseMoney("9", "EUR"),
+			models.MustParseMoney("11", "USD"),
+		},
+	})
+	require.IsType(t, &models.Error{}, err)
+	assert.Equal(t, []models.FieldError{
+		{Field: "prices[1]", Message: "duplicate EUR price"},
+		{Field: "prices[2]", Message: "duplicate USD price"},
+	}, err.(*models.Error).Fields)
+}
+
+func TestIntegrationDishesCategories(t *testing.T) {
+	s := NewService()
+
+	mains, err := s.CreateCategory(context.TODO(), models.CategoryParams{Name: makeString("Mains"), Position: makeInt(2)})
+	require.NoError(t, err)
+	starters, err := s.CreateCategory(context.TODO(), models.CategoryParams{Name: makeString("Starters"), Position: makeInt(1)})
+	require.NoError(t, err)
+	desserts, err := s.CreateCategory(context.TODO(), models.CategoryParams{Name: makeString("Desserts"), Position: makeInt(3)})
+	require.NoError(t, err)
+
+	categories, err := s.ListCategories(context.TODO())
+	require.NoError(t, err)
+	var names []string
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"Starters", "Mains", "Desserts"}, names)
+
+	// Dishes
+	soup, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:       makeString("Soup"),
+		Price:      makePrice("6"),
+		CategoryID: &starters.ID,
+		Tags:       &[]string{"Vegan", " hot ", "vegan"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vegan", "hot"}, soup.Tags)
+	steak, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:       makeString("Steak"),
+		Price:      makePrice("25"),
+		CategoryID: &mains.ID,
+		Tags:       &[]string{"hot"},
+	})
+	require.NoError(t, err)
+	_, err = s.CreateDish(context.TODO(), models.DishParams{
+		Name:  makeString("Salad"),
+		Price: makePrice("8"),
+		Tags:  &[]string{"vegan"},
+	})
+	require.NoError(t, err)
+
+	unknown := "missing"
+	_, err = s.CreateDish(context.TODO(), models.DishParams{
+		Name:       makeString("Fish"),
+		Price:      makePrice("20"),
+		CategoryID: &unknown,
+	})
+	require.IsType(t, &models.Error{}, err)
+	assert.Equal(t, []models.FieldError{{Field: "categoryId", Message: "unknown category"}}, err.(*models.Error).Fields)
+
+	// Filter by category and tags
+	list := func(q ListDishesQuery) []string {
+		page, _, err := s.ListDishes(context.TODO(), q)
+		require.NoError(t, err)
+		var names []string
+		for _, d := range page {
+			names = append(names, d.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Soup"}, list(ListDishesQuery{CategoryID: starters.ID}))
+	assert.Equal(t, []string{"Soup", "Salad"}, list(ListDishesQuery{Tags: []string{"Vegan"}}))
+	assert.Equal(t, []string{"Soup"}, list(ListDishesQuery{Tags: []string{"vegan", "hot"}}))
+	assert.Equal(t, []string{"Soup", "Steak"}, list(ListDishesQuery{Tags: []string{"hot"}}))
+
+	// Categories with dishes cannot simply be deleted
+	err = s.DeleteCategory(context.TODO(), starters.ID, 0, "")
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	err = s.DeleteCategory(context.TODO(), starters.ID, 0, starters.ID)
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	err = s.DeleteCategory(context.TODO(), starters.ID, 0, unknown)
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	require.NoError(t, s.DeleteCategory(context.TODO(), desserts.ID, desserts.Version, ""))
+
+	// Reassign on delete
+	require.NoError(t, s.DeleteCategory(context.TODO(), starters.ID, 0, mains.ID))
+	_, err = s.GetCategory(context.TODO(), starters.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+	moved, err := s.GetDish(context.TODO(), soup.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, mains.ID, moved.CategoryID)
+	assert.Equal(t, soup.Version+1, moved.Version)
+	assert.Equal(t, []string{"Soup", "Steak"}, list(ListDishesQuery{CategoryID: mains.ID}))
+
+	// Moving dishes out of a category frees it for deletion
+	none := ""
+	for _, id := range []string{soup.ID, steak.ID} {
+		_, err = s.UpdateDish(context.TODO(), id, models.DishParams{CategoryID: &none})
+		require.NoError(t, err)
+	}
+	updated, err := s.UpdateCategory(context.TODO(), mains.ID, models.CategoryParams{Name: makeString("Main courses")})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, 2, updated.Position)
+	require.NoError(t, s.DeleteCategory(context.TODO(), mains.ID, updated.Version, ""))
+}
+
+func TestIntegrationDishesDietary(t *testing.T) {
+	s := NewService()
+	create := func(name string, allergens []models.Allergen, diets []models.Diet) *models.Dish {
+		dish, err := s.CreateDish(context.TODO(), models.DishParams{
+			Name:      makeString(name),
+			Price:     makePrice("10"),
+			Allergens: &allergens,
+			Diets:     &diets,
+		})
+		require.NoError(t, err)
+		return dish
+	}
+	pesto := create("Pasta Pesto", []models.Allergen{models.AllergenGluten, models.AllergenMilk, models.AllergenTreeNuts}, []models.Diet{models.DietVegetarian})
+	arrabbiata := create("Pasta Arrabbiata", []models.Allergen{models.AllergenGluten}, []models.Diet{models.DietVegan})
+	salad := create("Salad", nil, []models.Diet{models.DietVegan, models.DietGlutenFree})
+	vongole := create("Pasta Vongole", []models.Allergen{models.AllergenGluten, models.AllergenMolluscs}, nil)
+
+	// Labels must agree with allergens
+	_, err := s.CreateDish(context.TODO(), models.DishParams{
+		Name:      makeString("Cheese Pizza"),
+		Price:     makePrice("10"),
+		Allergens: &[]models.Allergen{models.AllergenMilk, "nuts"},
+		Diets:     &[]models.Diet{models.DietVegan},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{
+		{Field: "allergens[1]", Message: `unknown allergen "nuts"`},
+		{Field: "diets[0]", Message: "vegan dishes cannot contain milk"},
+	}, err.(*models.Error).Fields)
+
+	testcases := map[string]struct {
+		filter   DietaryFilter
+		expected []string
+	}{
+		"no filter":      {DietaryFilter{}, []string{pesto.ID, arrabbiata.ID, salad.ID, vongole.ID}},
+		"no tree nuts":   {DietaryFilter{ExcludeAllergens: []models.Allergen{models.AllergenTreeNuts}}, []string{arrabbiata.ID, salad.ID, vongole.ID}},
+		"vegetarian":     {DietaryFilter{Diets: []models.Diet{models.DietVegetarian}}, []string{pesto.ID, arrabbiata.ID, salad.ID}},
+		"vegan, no milk": {DietaryFilter{ExcludeAllergens: []models.Allergen{models.AllergenMilk}, Diets: []models.Diet{models.DietVegan}}, []string{arrabbiata.ID, salad.ID}},
+		"gluten free":    {DietaryFilter{Diets: []models.Diet{models.DietGlutenFree}}, []string{salad.ID}},
+	}
+	for name, tc := range testcases {
+		page, _, err := s.ListDishes(context.TODO(), ListDishesQuery{DietaryFilter: tc.filter})
+		require.NoError(t, err, name)
+		var ids []string
+		for _, d := range page {
+			ids = append(ids, d.ID)
+		}
+		assert.Equal(t, tc.expected, ids, name)
+	}
+
+	// Search filters before limiting
+	results, err := s.SearchDishes(context.TODO(), "pasta", 1, DietaryFilter{ExcludeAllergens: []models.Allergen{models.AllergenMilk, models.AllergenMolluscs}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, arrabbiata.ID, results[0].Dish.ID)
+
+	_, _, err = s.ListDishes(context.TODO(), ListDishesQuery{DietaryFilter: DietaryFilter{Diets: []models.Diet{"paleo"}}})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	_, err = s.SearchDishes(context.TODO(), "pasta", 1, DietaryFilter{ExcludeAllergens: []models.Allergen{"nuts"}})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
+
+func TestIntegrationDishesCosting(t *testing.T) {
+	ctx := context.TODO()
+	pantry := ingredients.NewService()
+	rates, err := exchange.NewTable("USD", map[string]string{"EUR": "0.8"})
+	require.NoError(t, err)
+	s := NewService(WithIngredients(pantry), WithExchangeRates(rates))
+
+	kg, lb := models.UnitKilogram, models.UnitPound
+	euros := models.MustParseMoney("8.00", "EUR")
+	flour, err := pantry.CreateIngredient(ctx, models.IngredientParams{
+		Name: makeString("Flour"),
+		Cost: makePrice("1.20"),
+		Unit: &kg,
+	})
+	require.NoError(t, err)
+	mozzarella, err := pantry.CreateIngredient(ctx, models.IngredientParams{
+		Name: makeString("Mozzarella"),
+		Cost: &euros,
+		Unit: &lb,
+	})
+	require.NoError(t, err)
+
+	// Recipes are checked against the ingredients
+	_, err = s.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pizza"),
+		Price: makePrice("12"),
+		Recipe: &[]models.RecipeLine{
+			{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 1, Unit: models.UnitCup}},
+			{IngredientID: "missing", Quantity: models.Quantity{Amount: 1, Unit: models.UnitGram}},
+		},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{
+		{Field: "recipe[0].quantity.unit", Message: "Flour is measured in kg"},
+		{Field: "recipe[1].ingredientId", Message: "unknown ingredient"},
+	}, err.(*models.Error).Fields)
+
+	// Costs convert units and currencies
+	pizza, err := s.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pizza"),
+		Price: makePrice("12"),
+		Recipe: &[]models.RecipeLine{
+			{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 250, Unit: models.UnitGram}},
+			{IngredientID: mozzarella.ID, Quantity: models.Quantity{Amount: 4, Unit: models.UnitOunce}},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, pizza.Costing)
+	require.Len(t, pizza.Costing.Lines, 2)
+	assert.Equal(t, "0.30 USD", pizza.Costing.Lines[0].Cost.String())
+	assert.Equal(t, "2.50 USD", pizza.Costing.Lines[1].Cost.String())
+	assert.Equal(t, "2.80 USD", pizza.Costing.Cost.String())
+	assert.Equal(t, "9.20 USD", pizza.Costing.Margin.String())
+	assert.Equal(t, 76.7, pizza.Costing.MarginPercent)
+
+	// Margins follow ingredient costs and prices
+	_, err = pantry.UpdateIngredient(ctx, flour.ID, models.IngredientParams{Cost: makePrice("2.40")})
+	require.NoError(t, err)
+	_, err = s.UpdateDish(ctx, pizza.ID, models.DishParams{Price: makePrice("14")})
+	require.NoError(t, err)
+	got, err := s.GetDish(ctx, pizza.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, "3.10 USD", got.Costing.Cost.String())
+	assert.Equal(t, "10.90 USD", got.Costing.Margin.String())
+	page, _, err := s.ListDishes(ctx, ListDishesQuery{})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, got.Costing, page[0].Costing)
+	results, err := s.SearchDishes(ctx, "pizza", 0, DietaryFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, got.Costing, results[0].Dish.Costing)
+
+	// Deleted ingredients leave the dish without a costing
+	require.NoError(t, pantry.DeleteIngredient(ctx, mozzarella.ID, 0))
+	got, err = s.GetDish(ctx, pizza.ID, "")
+	require.NoError(t, err)
+	assert.Nil(t, got.Costing)
+
+	// Recipes only use the tenant's own ingredients
+	_, err = s.CreateDish(tenant.NewContext(ctx, "diner"), models.DishParams{
+		Name:   makeString("Bread"),
+		Price:  makePrice("4"),
+		Recipe: &[]models.RecipeLine{{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 1, Unit: models.UnitKilogram}}},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "recipe[0].ingredientId", Message: "unknown ingredient"}}, err.(*models.Error).Fields)
+
+	// Recipes need ingredients
+	_, err = NewService().CreateDish(ctx, models.DishParams{
+		Name:   makeString("Bread"),
+		Price:  makePrice("4"),
+		Recipe: &[]models.RecipeLine{{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 1, Unit: models.UnitKilogram}}},
+	})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
+
+func TestIntegrationDishesNutrition(t *testing.T) {
+	ctx := context.TODO()
+	pantry := ingredients.NewService()
+	s := NewService(WithIngredients(pantry))
+
+	kg, l := models.UnitKilogram, models.UnitLiter
+	density := 1.03
+	flour, err := pantry.CreateIngredient(ctx, models.IngredientParams{
+		Name:      makeString("Flour"),
+		Cost:      makePrice("1.20"),
+		Unit:      &kg,
+		Nutrition: &models.Nutrition{Calories: 364, Fat: 1, SaturatedFat: 0.2, Carbohydrates: 76, Sugar: 0.3, Protein: 10, Sodium: 2},
+	})
+	require.NoError(t, err)
+	milk, err := pantry.CreateIngredient(ctx, models.IngredientParams{
+		Name:      makeString("Milk"),
+		Cost:      makePrice("1.10"),
+		Unit:      &l,
+		Nutrition: &models.Nutrition{Calories: 61, Fat: 3.3, SaturatedFat: 1.9, Carbohydrates: 4.8, Sugar: 4.8, Protein: 3.2, Sodium: 43},
+	})
+	require.NoError(t, err)
+
+	// Nutrition is summed per serving, weighing volumes by density
+	pancakes, err := s.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Pancakes"),
+		Price: makePrice("8"),
+		Recipe: &[]models.RecipeLine{
+			{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 150, Unit: models.UnitGram}},
+			{IngredientID: milk.ID, Quantity: models.Quantity{Amount: 200, Unit: models.UnitMilliliter}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, pancakes.Nutrition, "milk has no density")
+
+	_, err = pantry.UpdateIngredient(ctx, milk.ID, models.IngredientParams{Density: &density})
+	require.NoError(t, err)
+	got, err := s.GetDish(ctx, pancakes.ID, "")
+	require.NoError(t, err)
+	require.NotNil(t, got.Nutrition)
+	assert.InDelta(t, 356, got.Nutrition.ServingSize, 1e-9)
+	assert.InDelta(t, 671.66, got.Nutrition.PerServing.Calories, 1e-9)
+	assert.InDelta(t, 8.298, got.Nutrition.PerServing.Fat, 1e-9)
+	assert.InDelta(t, 91.58, got.Nutrition.PerServing.Sodium, 1e-9)
+
+	// Labels round the facts
+	label, err := s.NutritionLabel(ctx, pancakes.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "356 g", label.ServingSize)
+	assert.Equal(t, 670.0, label.Calories)
+	assert.Equal(t, 8.0, label.Nutrients[0].Amount)
+	assert.Equal(t, 90.0, label.Nutrients[2].Amount)
+
+	// Dishes without a recipe have no label
+	water, err := s.CreateDish(ctx, models.DishParams{Name: makeString("Water"), Price: makePrice("1")})
+	require.NoError(t, err)
+	assert.Nil(t, water.Nutrition)
+	_, err = s.NutritionLabel(ctx, water.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = s.NutritionLabel(ctx, "missing")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationDishesAvailability(t *testing.T) {
+	ctx := context.TODO()
+	pantry := ingredients.NewService()
+	stock := inventory.NewService()
+	s := NewService(WithIngredients(pantry), WithInventory(stock))
+
+	kg := models.UnitKilogram
+	flour, err := pantry.CreateIngredient(ctx, models.IngredientParams{
+		Name: makeString("Flour"),
+		Cost: makePrice("1.20"),
+		Unit: &kg,
+	})
+	require.NoError(t, err)
+	bread, err := s.CreateDish(ctx, models.DishParams{
+		Name:   makeString("Bread"),
+		Price:  makePrice("4"),
+		Recipe: &[]models.RecipeLine{{IngredientID: flour.ID, Quantity: models.Quantity{Amount: 400, Unit: models.UnitGram}}},
+	})
+	require.NoError(t, err)
+	tiramisu, err := s.CreateDish(ctx, models.DishParams{Name: makeString("Tiramisu"), Price: makePrice("6")})
+	require.NoError(t, err)
+
+	// Untracked dishes are available
+	assert.Equal(t, &models.DishAvailability{Available: true}, bread.Availability)
+
+	// Orders deplete stock until the dishes run out
+	_, err = stock.SetStock(ctx, models.StockIngredient, flour.ID, models.Quantity{Amount: 1, Unit: models.UnitKilogram})
+	require.NoError(t, err)
+	_, err = stock.SetStock(ctx, models.StockDish, tiramisu.ID, models.Quantity{Amount: 1, Unit: models.UnitEach})
+	require.NoError(t, err)
+	require.NoError(t, stock.Deplete(ctx, append(bread.StockUsage(2), tiramisu.StockUsage(1)...)))
+	got, err := s.GetDish(ctx, bread.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, &models.DishAvailability{
+		Reason:     models.UnavailableOutOfStock,
+		OutOfStock: []string{flour.ID},
+	}, got.Availability)
+	got, err = s.GetDish(ctx, tiramisu.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, &models.DishAvailability{Reason: models.UnavailableSoldOut}, got.Availability)
+
+	// Listing can hide them
+	page, _, err := s.ListDishes(ctx, ListDishesQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+	page, _, err = s.ListDishes(ctx, ListDishesQuery{AvailableOnly: true, Sort: SortByName})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	// Restocking brings them back
+	_, err = stock.Restock(ctx, models.StockIngredient, flour.ID, models.Quantity{Amount: 500, Unit: models.UnitGram})
+	require.NoError(t, err)
+	page, _, err = s.ListDishes(ctx, ListDishesQuery{AvailableOnly: true})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, bread.ID, page[0].ID)
+	assert.True(t, page[0].Availability.Available)
+
+	// 86ing by hand takes precedence
+	e, err := stock.EightySix(ctx, bread.ID, "oven broken")
+	require.NoError(t, err)
+	got, err = s.GetDish(ctx, bread.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.UnavailableEightySixed, got.Availability.Reason)
+	assert.True(t, e.Until.Equal(*got.Availability.Until))
+	require.NoError(t, stock.LiftEightySix(ctx, bread.ID))
+	got, err = s.GetDish(ctx, bread.ID, "")
+	require.NoError(t, err)
+	assert.True(t, got.Availability.Available)
+}
+
+func TestIntegrationDishesTenants(t *testing.T) {
+	s := NewService(
+		WithMaxPageSize(10),
+		WithTenants(tenant.Registry{
+			"bistro": {Currency: "EUR", MaxPageSize: 2},
+			"diner":  {},
+		}),
+	)
+	bistro := tenant.NewContext(context.TODO(), "bistro")
+	diner := tenant.NewContext(context.TODO(), "diner")
+
+	// Only configured tenants are served
+	_, err := s.ListCategories(context.TODO())
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	_, err = s.ListCategories(tenant.NewContext(context.TODO(), "cafe"))
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = s.ListCategories(tenant.NewContext(context.TODO(), "../cafe"))
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+
+	// The bistro prices in euros
+	_, err = s.CreateDish(bistro, models.DishParams{Name: makeString("Pasta"), Price: makePrice("10")})
+	require.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	assert.Equal(t, []models.FieldError{{Field: "price", Message: "must be in EUR"}}, err.(*models.Error).Fields)
+	euros := models.MustParseMoney("9", "EUR")
+	var dishIDs []string
+	for _, name := range []string{"Pasta", "Pizza", "Salad"} {
+		dish, err := s.CreateDish(bistro, models.DishParams{Name: makeString(name), Price: &euros})
+		require.NoError(t, err)
+		assert.Equal(t, "bistro", dish.TenantID)
+		dishIDs = append(dishIDs, dish.ID)
+	}
+	category, err := s.CreateCategory(bistro, models.CategoryParams{Name: makeString("Mains")})
+	require.NoError(t, err)
+
+	// The diner sees none of it
+	_, err = s.GetDish(diner, dishIDs[0], "")
+	assert.Equal(t, models.ErrNotFound, err)
+	_, err = s.UpdateDish(diner, dishIDs[0], models.DishParams{Name: makeString("Stolen")})
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Equal(t, models.ErrNotFound, s.DeleteDish(diner, dishIDs[0], 0))
+	_, err = s.PriceDish(diner, dishIDs[0], models.Configuration{})
+	assert.Equal(t, models.ErrNotFound, err)
+	_, err = s.GetCategory(diner, category.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+	_, err = s.CreateDish(diner, models.DishParams{
+		Name:       makeString("Burger"),
+		Price:      makePrice("10"),
+		CategoryID: &category.ID,
+	})
+	require.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	page, _, err := s.ListDishes(diner, ListDishesQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page, 0)
+	results, err := s.SearchDishes(diner, "pasta", 0, DietaryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, results, 0)
+	categories, err := s.ListCategories(diner)
+	require.NoError(t, err)
+	assert.Len(t, categories, 0)
+
+	// Idempotency keys do not leak either
+	key := "same-key"
+	first, err := s.CreateDish(WithIdempotencyKey(bistro, key), models.DishParams{Name: makeString("Soup"), Price: &euros})
+	require.NoError(t, err)
+	other, err := s.CreateDish(WithIdempotencyKey(diner, key), models.DishParams{Name: makeString("Soup"), Price: makePrice("5")})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, other.ID)
+	assert.Equal(t, "diner", other.TenantID)
+
+	// The bistro pages by 2 at most, the diner by the service wide 10
+	page, token, err := s.ListDishes(bistro, ListDishesQuery{PageSize: 50})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+	assert.NotEmpty(t, token)
+	_, _, err = s.ListDishes(bistro, ListDishesQuery{PageSize: 50, PageToken: token})
+	require.NoError(t, err)
+
+	// Page tokens only resume listings for the tenant they were issued to
+	_, _, err = s.ListDishes(diner, ListDishesQuery{PageSize: 50, PageToken: token})
+	assert.Equal(t, ErrInvalidPageToken, err)
+	results, err = s.SearchDishes(bistro, "p", 50, DietaryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	page, token, err = s.ListDishes(diner, ListDishesQuery{PageSize: 50})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Empty(t, token)
+}
@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+
+	defaultSnapshotEvery = 1000
+)
+
+// FileOptions tunes the file backed repository
+type FileOptions struct {
+
+	// SnapshotEvery is how many log entries are written before the log is
+	// compacted into a new snapshot. Defaults to 1000.
+	SnapshotEvery int
+
+	// NoSync skips fsync after every write. Faster, but writes acknowledged
+	// shortly before a machine crash may be lost.
+	NoSync bool
+}
+
+// walEntry is one change recorded in the write-ahead log. Each entry is
+// stored on its own line as "<crc32 of json in hex> <json>", so that a torn
+// or corrupted tail can be detected and dropped during recovery.
+type walEntry struct {
+	Op       string           `json:"op"`
+	Dish     *models.Dish     `json:"dish,omitempty"`
+	Category *models.Category `json:"category,omitempty"`
+	ID       string           `json:"id,omitempty"`
+
+	// Tenant owns the deleted dish or category, puts carry it in the model
+	Tenant string `json:"tenant,omitempty"`
+}
+
+const (
+	opPut            = "put"
+	opDelete         = "delete"
+	opPutCategory    = "putCategory"
+	opDeleteCategory = "deleteCategory"
+)
+
+var errClosed = errors.New("repository is closed")
+
+type snapshot struct {
+	Dishes     []models.Dish     `json:"dishes"`
+	Categories []models.Category `json:"categories,omitempty"`
+}
+
+// OpenFile returns a durable repository storing its data in dir. Every
+// change is appended to a write-ahead log before it is acknowledged, and the
+// log is periodically compacted into a snapshot. On open the snapshot is
+// loaded and the log replayed, dropping any partially written entry left
+// behind by a crash.
+func OpenFile(dir string, opts FileOptions) (Repository, error) {
+	if opts.SnapshotEvery <= 0 {
+		opts.SnapshotEvery = defaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f := &file{
+		dir:        dir,
+		opts:       opts,
+		local:      make(map[key]models.Dish),
+		categories: make(map[key]models.Category),
+		mu:         &sync.RWMutex{},
+		log: logrus.WithFields(logrus.Fields{
+			"context": "storage.file",
+			"dir":     dir,
+		}),
+	}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := f.replay(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type file struct {
+	dir  string
+	opts FileOptions
+
+	local      map[key]models.Dish
+	categories map[key]models.Category
+	mu         *sync.RWMutex
+
+	// wal is open for appending, size and entries track what it holds
+	wal     *os.File
+	size    int64
+	entries int
+
+	log *logrus.Entry
+}
+
+func (f *file) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+/**************************************
+ * Recovery
+ *************************************/
+
+func (f *file) loadSnapshot() error {
+	b, err := ioutil.ReadFile(f.path(snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Snapshots are written atomically, so a bad one is not a crash artifact
+	var s snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("corrupt snapshot %v: %v", f.path(snapshotFile), err)
+	}
+	for _, d := range s.Dishes {
+		f.local[key{d.TenantID, d.ID}] = d
+	}
+	for _, c := range s.Categories {
+		f.categories[key{c.TenantID, c.ID}] = c
+	}
+	return nil
+}
+
+// replay applies the log on top of the snapshot, truncates anything after
+// the last intact entry and leaves the log open for appending.
+func (f *file) replay() error {
+	wal, err := os.OpenFile(f.path(walFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	var good int64
+	r := bufio.NewReader(wal)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				f.log.Warnf("Dropping torn log entry at offset %v", good)
+			}
+			break
+		}
+		if err != nil {
+			wal.Close()
+			return err
+		}
+		entry, err := decodeEntry(line)
+		if err != nil {
+			f.log.Warnf("Dropping log from offset %v: %v", good, err)
+			break
+		}
+		f.apply(entry)
+		f.entries++
+		good += int64(len(line))
+	}
+
+	// Drop the bad tail so new entries follow the last good one
+	if err := wal.Truncate(good); err != nil {
+		wal.Close()
+		return err
+	}
+	if _, err := wal.Seek(good, io.SeekStart); err != nil {
+		wal.Close()
+		return err
+	}
+	f.wal = wal
+	f.size = good
+	return nil
+}
+
+func (f *file) apply(e walEntry) {
+	switch e.Op {
+	case opPut:
+		f.local[key{e.Dish.TenantID, e.Dish.ID}] = *e.Dish
+	case opDelete:
+		delete(f.local, key{e.Tenant, e.ID})
+	case opPutCategory:
+		f.categories[key{e.Category.TenantID, e.Category.ID}] = *e.Category
+	case opDeleteCategory:
+		delete(f.categories, key{e.Tenant, e.ID})
+	}
+}
+
+func encodeEntry(e walEntry) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)), nil
+}
+
+func decodeEntry(line []byte) (walEntry, error) {
+	var e walEntry
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 10 || line[8] != ' ' {
+		return e, fmt.Errorf("malformed entry")
+	}
+	var sum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &sum); err != nil {
+		return e, fmt.Errorf("malformed checksum")
+	}
+	payload := line[9:]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return e, fmt.Errorf("checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return e, err
+	}
+	switch {
+	case e.Op == opPut && e.Dish != nil:
+	case e.Op == opDelete && e.ID != "":
+	case e.Op == opPutCategory && e.Category != nil:
+	case e.Op == opDeleteCategory && e.ID != "":
+	default:
+		return e, fmt.Errorf("unknown entry %q", e.Op)
+	}
+	return e, nil
+}
+
+/**************************************
+ * Writes
+ *************************************/
+
+// append durably logs the entry, then applies it in memory
+func (f *file) append(e walEntry) error {
+	if f.wal == nil {
+		return errClosed
+	}
+	line, err := encodeEntry(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.wal.Write(line); err != nil {
+		f.rollback()
+		return err
+	}
+	if !f.opts.NoSync {
+		if err := f.wal.Sync(); err != nil {
+			f.rollback()
+			return err
+		}
+	}
+	f.apply(e)
+	f.size += int64(len(line))
+	f.entries++
+
+	// Compact. The entry is already durable in the log, so a failed snapshot
+	// only means the log keeps growing until the next attempt.
+	if f.entries >= f.opts.SnapshotEvery {
+		if err := f.snapshot(); err != nil {
+			f.log.WithError(err).Error("Unable to snapshot")
+		}
+	}
+	return nil
+}
+
+// rollback drops a partially written entry, since recovery stops at the
+// first bad entry and would otherwise lose every entry appended after it
+func (f *file) rollback() {
+	if err := f.wal.Truncate(f.size); err != nil {
+		f.log.WithError(err).Error("Unable to roll back log")
+		return
+	}
+	if _, err := f.wal.Seek(f.size, io.SeekStart); err != nil {
+		f.log.WithError(err).Error("Unable to roll back log")
+	}
+}
+
+// snapshot writes the current state to a new snapshot and empties the log.
+// The snapshot is written to a temporary file and renamed into place, so a
+// crash leaves either the old or the new snapshot. A crash before the log is
+// emptied is harmless too, since replaying puts and deletes is idempotent.
+func (f *file) snapshot() error {
+	s := snapshot{
+		Dishes:     make([]models.Dish, 0, len(f.local)),
+		Categories: make([]models.Category, 0, len(f.categories)),
+	}
+	for _, d := range f.local {
+		s.Dishes = append(s.Dishes, d)
+	}
+	for _, c := range f.categories {
+		s.Categories = append(s.Categories, c)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path(snapshotFile + ".tmp")
+	if err := writeFileSync(tmp, b); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path(snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	// Empty the log
+	if err := f.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f.size = 0
+	f.entries = 0
+	return f.wal.Sync()
+}
+
+func writeFileSync(name string, b []byte) error {
+	tmp, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+// syncDir persists a rename within dir
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+/**************************************
+ * Repository
+ *************************************/
+
+func (f *file) Get(tenant, id string) (*models.Dish, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	dish, found := f.local[key{tenant, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &dish, nil
+}
+
+func (f *file) Put(d models.Dish) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.append(walEntry{Op: opPut, Dish: &d})
+}
+
+func (f *file) Delete(tenant, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, found := f.local[key{tenant, id}]; !found {
+		return models.ErrNotFound
+	}
+	return f.append(walEntry{Op: opDelete, Tenant: tenant, ID: id})
+}
+
+func (f *file) All() []models.Dish {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	dishes := make([]models.Dish, 0, len(f.local))
+	for _, d := range f.local {
+		dishes = append(dishes, d)
+	}
+	return dishes
+}
+
+func (f *file) GetCategory(tenant, id string) (*models.Category, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	category, found := f.categories[key{tenant, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &category, nil
+}
+
+func (f *file) PutCategory(c models.Category) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.append(walEntry{Op: opPutCategory, Category: &c})
+}
+
+func (f *file) DeleteCategory(tenant, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, found := f.categories[key{tenant, id}]; !found {
+		return models.ErrNotFound
+	}
+	return f.append(walEntry{Op: opDeleteCategory, Tenant: tenant, ID: id})
+}
+
+func (f *file) AllCategories(tenant string) []models.Category {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	categories := []models.Category{}
+	for k, c := range f.categories {
+		if k.tenant == tenant {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// Close snapshots so the next open does not need to replay the log
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.wal == nil {
+		return nil
+	}
+	err := f.snapshot()
+	if cerr := f.wal.Close(); err == nil {
+		err = cerr
+	}
+	f.wal = nil
+	return err
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dishes-storage")
+	require.NoError(t, err)
+	return dir
+}
+
+func makeDish(id, name string) models.Dish {
+	return models.Dish{
+		ID:      id,
+		Name:    name,
+		Price:   models.NewMoney(1000, "USD"),
+		Version: 1,
+		Created: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+		Updated: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFileRecovery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := OpenFile(dir, FileOptions{SnapshotEvery: 3})
+	require.NoError(t, err)
+
+	// Enough writes to cross a snapshot, leaving some in the log
+	require.NoError(t, repo.Put(makeDish("a", "Pasta")))
+	require.NoError(t, repo.Put(makeDish("b", "Pizza")))
+	require.NoError(t, repo.Put(makeDish("c", "Salad")))
+	require.NoError(t, repo.Delete("", "b"))
+	require.NoError(t, repo.Put(makeDish("a", "Penne")))
+	assert.Equal(t, models.ErrNotFound, repo.Delete("", "b"))
+	require.NoError(t, repo.PutCategory(models.Category{ID: "x", Name: "Mains"}))
+	require.NoError(t, repo.PutCategory(models.Category{ID: "y", Name: "Desserts"}))
+	require.NoError(t, repo.DeleteCategory("", "y"))
+
+	// Simulate a crash by reopening without closing
+	reopened, err := OpenFile(dir, FileOptions{SnapshotEvery: 3})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Len(t, reopened.All(), 2)
+	a, err := reopened.Get("", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "Penne", a.Name)
+	_, err = reopened.Get("", "b")
+	assert.Equal(t, models.ErrNotFound, err)
+	categories := reopened.AllCategories("")
+	require.Len(t, categories, 1)
+	assert.Equal(t, "Mains", categories[0].Name)
+}
+
+func TestFileTornLog(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Put(makeDish("a", "Pasta")))
+	require.NoError(t, repo.Put(makeDish("b", "Pizza")))
+
+	// A crash in the middle of a write leaves half an entry behind
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = wal.WriteString(`1234abcd {"op":"put","dish":{"id":"c"`)
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	reopened, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	assert.Len(t, reopened.All(), 2)
+
+	// New writes land after the last good entry and survive another crash
+	require.NoError(t, reopened.Put(makeDish("c", "Salad")))
+	again, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	defer again.Close()
+	assert.Len(t, again.All(), 3)
+}
+
+func TestFileCorruptEntry(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Put(makeDish("a", "Pasta")))
+	require.NoError(t, repo.Put(makeDish("b", "Pizza")))
+
+	// Flip a byte inside the second entry
+	name := filepath.Join(dir, walFile)
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	b[len(b)-5] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(name, b, 0644))
+
+	reopened, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+	dishes := reopened.All()
+	require.Len(t, dishes, 1)
+	assert.Equal(t, "a", dishes[0].ID)
+}
+
+func TestFileClose(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Put(makeDish("a", "Pasta")))
+	require.NoError(t, repo.Close())
+
+	// Closing compacts the log into the snapshot
+	info, err := os.Stat(filepath.Join(dir, walFile))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+	assert.Equal(t, errClosed, repo.Put(makeDish("b", "Pizza")))
+
+	reopened, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Len(t, reopened.All(), 1)
+}
+
+func TestFileTenants(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := OpenFile(dir, FileOptions{SnapshotEvery: 4})
+	require.NoError(t, err)
+
+	// The same IDs in different tenants are different dishes
+	bistro := makeDish("a", "Bistro Pasta")
+	bistro.TenantID = "bistro"
+	require.NoError(t, repo.Put(makeDish("a", "Pasta")))
+	require.NoError(t, repo.Put(bistro))
+	require.NoError(t, repo.PutCategory(models.Category{ID: "x", Name: "Mains"}))
+	require.NoError(t, repo.PutCategory(models.Category{ID: "x", Name: "Bistro Mains", TenantID: "bistro"}))
+	require.NoError(t, repo.Delete("", "a"))
+	assert.Equal(t, models.ErrNotFound, repo.Delete("diner", "a"))
+
+	reopened, err := OpenFile(dir, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	_, err = reopened.Get("", "a")
+	assert.Equal(t, models.ErrNotFound, err)
+	a, err := reopened.Get("bistro", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "Bistro Pasta", a.Name)
+	categories := reopened.AllCategories("bistro")
+	require.Len(t, categories, 1)
+	assert.Equal(t, "Bistro Mains", categories[0].Name)
+	assert.Len(t, reopened.AllCategories("diner"), 0)
+}
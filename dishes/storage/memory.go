@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// NewMemory returns a repository keeping dishes in a map. Everything is lost
+// when the process exits.
+func NewMemory() Repository {
+	return &memory{
+		local:      make(map[key]models.Dish),
+		categories: make(map[key]models.Category),
+		mu:         &sync.RWMutex{},
+	}
+}
+
+type memory struct {
+	local      map[key]models.Dish
+	categories map[key]models.Category
+	mu         *sync.RWMutex
+}
+
+func (m *memory) Get(tenant, id string) (*models.Dish, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dish, found := m.local[key{tenant, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &dish, nil
+}
+
+func (m *memory) Put(d models.Dish) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.local[key{d.TenantID, d.ID}] = d
+	return nil
+}
+
+func (m *memory) Delete(tenant, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.local[key{tenant, id}]; !found {
+		return models.ErrNotFound
+	}
+	delete(m.local, key{tenant, id})
+	return nil
+}
+
+func (m *memory) All() []models.Dish {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dishes := make([]models.Dish, 0, len(m.local))
+	for _, d := range m.local {
+		dishes = append(dishes, d)
+	}
+	return dishes
+}
+
+func (m *memory) GetCategory(tenant, id string) (*models.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	category, found := m.categories[key{tenant, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &category, nil
+}
+
+func (m *memory) PutCategory(c models.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.categories[key{c.TenantID, c.ID}] = c
+	return nil
+}
+
+func (m *memory) DeleteCategory(tenant, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.categories[key{tenant, id}]; !found {
+		return models.ErrNotFound
+	}
+	delete(m.categories, key{tenant, id})
+	return nil
+}
+
+func (m *memory) AllCategories(tenant string) []models.Category {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories := []models.Category{}
+	for k, c := range m.categories {
+		if k.tenant == tenant {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+func (m *memory) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+// Storage defines how dishes are persisted, along with the implementations
+// the dishes service can be configured with.
+package storage
+
+import (
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Repository persists dishes and their categories. The dishes service keeps
+// its own indexes for listing and search, which it builds from All on
+// startup, so repositories only need to support lookups by ID.
+//
+// Dishes and categories belong to a tenant, see models.Dish.TenantID, and are
+// stored under the tenant and their ID. Lookups never cross tenants.
+//
+// Implementations must be safe for concurrent use.
+type Repository interface {
+
+	// Get returns a copy of the tenant's dish, or models.ErrNotFound
+	Get(tenant, id string) (*models.Dish, error)
+
+	// Put inserts the dish or replaces the one with the same tenant and ID.
+	// Once Put returns the write is as durable as the implementation allows.
+	Put(d models.Dish) error
+
+	// Delete removes the tenant's dish, or returns models.ErrNotFound
+	Delete(tenant, id string) error
+
+	// All returns every dish of every tenant in no particular order.
+	// Implementations load their data when opened, so this cannot fail.
+	All() []models.Dish
+
+	// GetCategory returns a copy of the tenant's category, or
+	// models.ErrNotFound
+	GetCategory(tenant, id string) (*models.Category, error)
+
+	// PutCategory inserts the category or replaces the one with the same
+	// tenant and ID
+	PutCategory(c models.Category) error
+
+	// DeleteCategory removes the tenant's category, or returns
+	// models.ErrNotFound. Keeping dishes from referring to deleted
+	// categories is up to the caller.
+	DeleteCategory(tenant, id string) error
+
+	// AllCategories returns every category of the tenant in no particular
+	// order
+	AllCategories(tenant string) []models.Category
+
+	// Close releases any resources held by the repository
+	Close() error
+}
+
+// key identifies a dish or category, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
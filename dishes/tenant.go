@@ -0,0 +1,103 @@
+// Tenant scopes dishes and categories by the restaurant a request acts for.
+package dishes
+
+import (
+	"context"
+
+	"github.com/jeffizhungry/polygon/lib/search"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected. Without it every valid tenant
+// ID is served with the service wide settings, and requests without a tenant
+// act for the default tenant.
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+// catalog holds the indexes over the dishes of a single tenant, along with
+// its settings. Like the rest of the resource it is guarded by resource.mu.
+type catalog struct {
+	id string
+
+	defaultPageSize int
+	maxPageSize     int
+	currency        string
+
+	// secondaryIndex orders dishes by Created, then ID
+	secondaryIndex *dishIndex
+
+	// categoryDishes maps category IDs to the IDs of the dishes in them
+	categoryDishes map[string]map[string]bool
+
+	// search is the full-text index over dish names
+	search *search.Index
+}
+
+// tenant returns the catalog of the tenant the context acts for. It fails
+// with an invalid argument error for a malformed tenant ID, and with a not
+// found error for tenants the service is not configured to serve, see
+// WithTenants.
+func (r *resource) tenant(ctx context.Context) (*catalog, error) {
+	id, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.catalog(id), nil
+}
+
+// catalog returns the tenant's catalog, creating an empty one on first use
+func (r *resource) catalog(id string) *catalog {
+	r.catalogsMu.Lock()
+	defer r.catalogsMu.Unlock()
+
+	if c, found := r.catalogs[id]; found {
+		return c
+	}
+	config := r.tenants[id]
+	c := &catalog{
+		id:              id,
+		defaultPageSize: r.defaultPageSize,
+		maxPageSize:     r.maxPageSize,
+		currency:        config.Currency,
+		secondaryIndex:  newDishIndex(),
+		categoryDishes:  make(map[string]map[string]bool),
+		search:          search.NewIndex(),
+	}
+	if config.MaxPageSize > 0 && config.MaxPageSize < c.maxPageSize {
+		c.maxPageSize = config.MaxPageSize
+	}
+	if c.defaultPageSize > c.maxPageSize {
+		c.defaultPageSize = c.maxPageSize
+	}
+	r.catalogs[id] = c
+	return c
+}
+
+// pageSize resolves a requested page size, 0 selects the default
+func (c *catalog) pageSize(n int) int {
+	switch {
+	case n == 0:
+		return c.defaultPageSize
+	case n > c.maxPageSize:
+		return c.maxPageSize
+	}
+	return n
+}
+
+// idempotencyKey scopes an idempotency key to the tenant. Tenant IDs never
+// contain a slash, so keys of different tenants cannot collide.
+func (c *catalog) idempotencyKey(key string) string {
+	return c.id + "/" + key
+}
+
+// checkCurrency verifies the dish is priced in the tenant's currency, if it
+// has one
+func (c *catalog) checkCurrency(d *models.Dish) error {
+	if c.currency != "" && d.Price.Currency != c.currency {
+		return models.InvalidArgument("invalid dish").WithField("price", "must be in "+c.currency)
+	}
+	return nil
+}
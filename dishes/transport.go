@@ -0,0 +1,835 @@
+// Transport exposes the service endpoints over HTTP.
+package dishes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+// Mimicing this: https://github.com/go-kit/kit/blob/master/examples/profilesvc/transport.go
+//
+// POST    /dishes                 creates a dish
+// GET     /dishes                 lists dishes, see decodeListDishesRequest for parameters
+// GET     /dishes/search          searches dishes with q, returning up to limit results
+// GET     /dishes/{id}            retrieves a dish
+// POST    /dishes/{id}/price      prices the dish as configured with modifiers
+// GET     /dishes/{id}/nutrition  renders the dish's nutrition label
+// PUT     /dishes/{id}            replaces a dish, all fields are required
+// PATCH   /dishes/{id}            partially updates a dish
+// DELETE  /dishes/{id}            deletes a dish
+//
+// Only managers change prices, see Authorize. PUT always carries the price,
+// so editors update dishes with PATCH, leaving out the price fields and any
+// modifier price deltas.
+//
+// POST    /categories       creates a category
+// GET     /categories       lists every category in menu order
+// GET     /categories/{id}  retrieves a category
+// PUT     /categories/{id}  replaces a category, name is required
+// PATCH   /categories/{id}  partially updates a category
+// DELETE  /categories/{id}  deletes a category, see reassignTo below
+//
+// Listing and searching dishes take the repeatable excludeAllergen and diet
+// parameters, to leave out dishes containing any of the allergens and keep
+// only dishes suiting every diet. Listing takes available=true to hide
+// dishes that cannot be ordered right now.
+//
+// The nutrition label is JSON unless format=text asks for a plain text table.
+//
+// Deleting a category that still has dishes fails with 409 Conflict, unless
+// the reassignTo parameter names the category to move its dishes to.
+//
+// Responses carry the dish or category version in an ETag header. PUT, PATCH
+// and DELETE honour If-Match and fail with 409 Conflict when it changed.
+// POST honours Idempotency-Key, see WithIdempotencyKey.
+//
+// Every request acts for the tenant named by the X-Tenant-ID header, or for
+// the default tenant without one, see WithTenants.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("POST").Path("/dishes").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CreateDishEndpoint,
+		decodeCreateDishRequest,
+		encodeResponse,
+		append(options, httptransport.ServerBefore(idempotencyKeyToContext))...,
+	))
+	r.Methods("GET").Path("/dishes").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListDishesEndpoint,
+		decodeListDishesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/dishes/search").Handler(httptransport.NewServer(
+		context.Background(),
+		e.SearchDishesEndpoint,
+		decodeSearchDishesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/dishes/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetDishEndpoint,
+		decodeGetDishRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/dishes/{id}/price").Handler(httptransport.NewServer(
+		context.Background(),
+		e.PriceDishEndpoint,
+		decodePriceDishRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/dishes/{id}/nutrition").Handler(httptransport.NewServer(
+		context.Background(),
+		e.NutritionEndpoint,
+		decodeNutritionRequest,
+		encodeNutritionResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/dishes/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateDishEndpoint,
+		decodePutDishRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/dishes/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateDishEndpoint,
+		decodePatchDishRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/dishes/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteDishEndpoint,
+		decodeDeleteDishRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/categories").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CreateCategoryEndpoint,
+		decodeCreateCategoryRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/categories").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListCategoriesEndpoint,
+		decodeListCategoriesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/categories/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetCategoryEndpoint,
+		decodeGetCategoryRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/categories/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateCategoryEndpoint,
+		decodePutCategoryRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/categories/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateCategoryEndpoint,
+		decodePatchCategoryRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/categories/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteCategoryEndpoint,
+		decodeDeleteCategoryRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in a dishes client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		CreateDishEndpoint:   httptransport.NewClient("POST", tgt, encodeCreateDishRequest, decodeCreateDishResponse, options...).Endpoint(),
+		UpdateDishEndpoint:   httptransport.NewClient("PATCH", tgt, encodeUpdateDishRequest, decodeUpdateDishResponse, options...).Endpoint(),
+		DeleteDishEndpoint:   httptransport.NewClient("DELETE", tgt, encodeDeleteDishRequest, decodeDeleteDishResponse, options...).Endpoint(),
+		GetDishEndpoint:      httptransport.NewClient("GET", tgt, encodeGetDishRequest, decodeGetDishResponse, options...).Endpoint(),
+		ListDishesEndpoint:   httptransport.NewClient("GET", tgt, encodeListDishesRequest, decodeListDishesResponse, options...).Endpoint(),
+		SearchDishesEndpoint: httptransport.NewClient("GET", tgt, encodeSearchDishesRequest, decodeSearchDishesResponse, options...).Endpoint(),
+		PriceDishEndpoint:    httptransport.NewClient("POST", tgt, encodePriceDishRequest, decodePriceDishResponse, options...).Endpoint(),
+		NutritionEndpoint:    httptransport.NewClient("GET", tgt, encodeNutritionRequest, decodeNutritionResponse, options...).Endpoint(),
+
+		CreateCategoryEndpoint: httptransport.NewClient("POST", tgt, encodeCreateCategoryRequest, decodeCreateCategoryResponse, options...).Endpoint(),
+		UpdateCategoryEndpoint: httptransport.NewClient("PATCH", tgt, encodeUpdateCategoryRequest, decodeUpdateCategoryResponse, options...).Endpoint(),
+		DeleteCategoryEndpoint: httptransport.NewClient("DELETE", tgt, encodeDeleteCategoryRequest, decodeDeleteCategoryResponse, options...).Endpoint(),
+		GetCategoryEndpoint:    httptransport.NewClient("GET", tgt, encodeGetCategoryRequest, decodeGetCategoryResponse, options...).Endpoint(),
+		ListCategoriesEndpoint: httptransport.NewClient("GET", tgt, encodeListCategoriesRequest, decodeListCategoriesResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeCreateDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req createDishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.DishParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getDishRequest{ID: id, Currency: r.URL.Query().Get("currency")}, nil
+}
+
+func decodePutDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeUpdateDishRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// PUT replaces the whole resource, so every field must be present
+	if req.Name == nil || req.Price == nil {
+		return nil, models.InvalidArgument("name and price are required")
+	}
+	return req, nil
+}
+
+func decodePatchDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return decodeUpdateDishRequest(r)
+}
+
+// decodeUpdateDishRequest decodes the parts shared by PUT and PATCH
+func decodeUpdateDishRequest(r *http.Request) (updateDishRequest, error) {
+	var req updateDishRequest
+	id, err := pathID(r)
+	if err != nil {
+		return req, err
+	}
+	var params models.DishParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return req, models.InvalidArgument("malformed request body: %v", err)
+	}
+
+	params.Version, err = etag.ExpectedVersion(r, params.Version)
+	if err != nil {
+		return req, err
+	}
+	return updateDishRequest{ID: id, DishParams: params}, nil
+}
+
+func decodeDeleteDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	version, err := etag.ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	return deleteDishRequest{ID: id, Version: version}, nil
+}
+
+// decodeListDishesRequest reads a ListDishesQuery from the URL. The currency
+// parameter quotes every dish, while minPrice and maxPrice are amounts in
+// priceCurrency, which defaults to the quote currency and then to
+// models.DefaultCurrency.
+func decodeListDishesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	values := r.URL.Query()
+	q := ListDishesQuery{
+		PageToken:    values.Get("pageToken"),
+		NamePrefix:   values.Get("namePrefix"),
+		NameContains: values.Get("nameContains"),
+		CategoryID:   values.Get("categoryId"),
+		Tags:         values["tag"],
+		Sort:         SortField(values.Get("sort")),
+	}
+	q.DietaryFilter = decodeDietaryFilter(values)
+
+	bad := models.InvalidArgument("invalid query")
+	if v := values.Get("pageSize"); v != "" {
+		pageSize, err := strconv.Atoi(v)
+		if err != nil || pageSize < 0 {
+			bad = bad.WithField("pageSize", "must be a non-negative integer")
+		}
+		q.PageSize = pageSize
+	}
+	q.Currency = values.Get("currency")
+	if q.Currency != "" {
+		if _, ok := models.CurrencyExponent(q.Currency); !ok {
+			bad = bad.WithField("currency", "must be a supported ISO 4217 code")
+		}
+	}
+	currency := values.Get("priceCurrency")
+	switch {
+	case currency == "" && q.Currency != "":
+		currency = q.Currency
+	case currency == "":
+		currency = models.DefaultCurrency
+	default:
+		if _, ok := models.CurrencyExponent(currency); !ok {
+			bad = bad.WithField("priceCurrency", "must be a supported ISO 4217 code")
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **models.Money
+	}{
+		{"minPrice", &q.MinPrice},
+		{"maxPrice", &q.MaxPrice},
+	} {
+		if v := values.Get(p.name); v != "" {
+			price, err := models.ParseMoney(v, currency)
+			if err != nil {
+				bad = bad.WithField(p.name, "must be a decimal amount in "+currency)
+			}
+			*p.dst = &price
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"createdAfter", &q.CreatedAfter},
+		{"createdBefore", &q.CreatedBefore},
+	} {
+		if v := values.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				bad = bad.WithField(p.name, "must be an RFC 3339 time")
+			}
+			*p.dst = t
+		}
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		bad = bad.WithField("order", "must be asc or desc")
+	}
+	if v := values.Get("available"); v != "" {
+		available, err := strconv.ParseBool(v)
+		if err != nil {
+			bad = bad.WithField("available", "must be true or false")
+		}
+		q.AvailableOnly = available
+	}
+	if len(bad.Fields) > 0 {
+		return nil, bad
+	}
+	return listDishesRequest{ListDishesQuery: q}, nil
+}
+
+func decodeSearchDishesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	values := r.URL.Query()
+	req := searchDishesRequest{
+		Query:         values.Get("q"),
+		DietaryFilter: decodeDietaryFilter(values),
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return nil, models.InvalidArgument("invalid search").WithField("limit", "must be a non-negative integer")
+		}
+		req.Limit = limit
+	}
+	return req, nil
+}
+
+// decodeDietaryFilter reads the repeated excludeAllergen and diet parameters
+func decodeDietaryFilter(values url.Values) DietaryFilter {
+	var f DietaryFilter
+	for _, a := range values["excludeAllergen"] {
+		f.ExcludeAllergens = append(f.ExcludeAllergens, models.Allergen(a))
+	}
+	for _, d := range values["diet"] {
+		f.Diets = append(f.Diets, models.Diet(d))
+	}
+	return f
+}
+
+func decodePriceDishRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	req := priceDishRequest{ID: id}
+	if err := json.NewDecoder(r.Body).Decode(&req.Configuration); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeNutritionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	req := nutritionRequest{ID: id}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "text":
+		req.Text = true
+	default:
+		return nil, models.InvalidArgument("invalid format %q", format).
+			WithField("format", "must be json or text")
+	}
+	return req, nil
+}
+
+func decodeCreateCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req createCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.CategoryParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getCategoryRequest{ID: id}, nil
+}
+
+func decodePutCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeUpdateCategoryRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// PUT replaces the whole resource, position defaults to the top
+	if req.Name == nil {
+		return nil, models.InvalidArgument("name is required")
+	}
+	if req.Position == nil {
+		position := 0
+		req.Position = &position
+	}
+	return req, nil
+}
+
+func decodePatchCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return decodeUpdateCategoryRequest(r)
+}
+
+// decodeUpdateCategoryRequest decodes the parts shared by PUT and PATCH
+func decodeUpdateCategoryRequest(r *http.Request) (updateCategoryRequest, error) {
+	var req updateCategoryRequest
+	id, err := pathID(r)
+	if err != nil {
+		return req, err
+	}
+	var params models.CategoryParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return req, models.InvalidArgument("malformed request body: %v", err)
+	}
+	params.Version, err = etag.ExpectedVersion(r, params.Version)
+	if err != nil {
+		return req, err
+	}
+	return updateCategoryRequest{ID: id, CategoryParams: params}, nil
+}
+
+func decodeDeleteCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	version, err := etag.ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	return deleteCategoryRequest{
+		ID:         id,
+		Version:    version,
+		ReassignTo: r.URL.Query().Get("reassignTo"),
+	}, nil
+}
+
+func decodeListCategoriesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listCategoriesRequest{}, nil
+}
+
+// pathID extracts the {id} path variable
+func pathID(r *http.Request) (string, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return id, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors. It allows us to change the HTTP response code without needing to
+// trigger an endpoint (transport-level) error.
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+// encodeNutritionResponse writes the label as a plain text table when the
+// request asked for one, and as JSON otherwise
+func encodeNutritionResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp, ok := response.(nutritionResponse)
+	if !ok || resp.Err != nil || !resp.Text {
+		return encodeResponse(ctx, w, response)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := io.WriteString(w, resp.NutritionLabel.Text())
+	return err
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeCreateDishRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(createDishRequest)
+	urlpath.Append(r.URL, "dishes")
+	idempotencyKeyToHTTP(ctx, r)
+	return httptransport.EncodeJSONRequest(ctx, r, req.DishParams)
+}
+
+func encodeGetDishRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getDishRequest)
+	urlpath.Append(r.URL, "dishes", req.ID)
+	if req.Currency != "" {
+		q := r.URL.Query()
+		q.Set("currency", req.Currency)
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func encodeUpdateDishRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(updateDishRequest)
+	urlpath.Append(r.URL, "dishes", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.DishParams)
+}
+
+func encodeDeleteDishRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(deleteDishRequest)
+	urlpath.Append(r.URL, "dishes", req.ID)
+	etag.SetIfMatch(r, req.Version)
+	return nil
+}
+
+func encodeListDishesRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(listDishesRequest)
+	urlpath.Append(r.URL, "dishes")
+	q := r.URL.Query()
+	if req.PageToken != "" {
+		q.Set("pageToken", req.PageToken)
+	}
+	q.Set("pageSize", strconv.Itoa(req.PageSize))
+	if req.MinPrice != nil {
+		q.Set("minPrice", req.MinPrice.Decimal())
+		q.Set("priceCurrency", req.MinPrice.Currency)
+	}
+	if req.MaxPrice != nil {
+		q.Set("maxPrice", req.MaxPrice.Decimal())
+		q.Set("priceCurrency", req.MaxPrice.Currency)
+	}
+	if req.Currency != "" {
+		q.Set("currency", req.Currency)
+	}
+	if req.NamePrefix != "" {
+		q.Set("namePrefix", req.NamePrefix)
+	}
+	if req.NameContains != "" {
+		q.Set("nameContains", req.NameContains)
+	}
+	if req.CategoryID != "" {
+		q.Set("categoryId", req.CategoryID)
+	}
+	for _, t := range req.Tags {
+		q.Add("tag", t)
+	}
+	encodeDietaryFilter(q, req.DietaryFilter)
+	if !req.CreatedAfter.IsZero() {
+		q.Set("createdAfter", req.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !req.CreatedBefore.IsZero() {
+		q.Set("createdBefore", req.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if req.Sort != "" {
+		q.Set("sort", string(req.Sort))
+	}
+	if req.Descending {
+		q.Set("order", "desc")
+	}
+	if req.AvailableOnly {
+		q.Set("available", "true")
+	}
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func encodeSearchDishesRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(searchDishesRequest)
+	urlpath.Append(r.URL, "dishes", "search")
+	q := r.URL.Query()
+	q.Set("q", req.Query)
+	q.Set("limit", strconv.Itoa(req.Limit))
+	encodeDietaryFilter(q, req.DietaryFilter)
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func encodeDietaryFilter(q url.Values, f DietaryFilter) {
+	for _, a := range f.ExcludeAllergens {
+		q.Add("excludeAllergen", string(a))
+	}
+	for _, d := range f.Diets {
+		q.Add("diet", string(d))
+	}
+}
+
+func encodePriceDishRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(priceDishRequest)
+	urlpath.Append(r.URL, "dishes", req.ID, "price")
+	return httptransport.EncodeJSONRequest(ctx, r, req.Configuration)
+}
+
+func encodeNutritionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(nutritionRequest)
+	urlpath.Append(r.URL, "dishes", req.ID, "nutrition")
+	return nil
+}
+
+func encodeCreateCategoryRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(createCategoryRequest)
+	urlpath.Append(r.URL, "categories")
+	return httptransport.EncodeJSONRequest(ctx, r, req.CategoryParams)
+}
+
+func encodeGetCategoryRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getCategoryRequest)
+	urlpath.Append(r.URL, "categories", req.ID)
+	return nil
+}
+
+func encodeUpdateCategoryRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(updateCategoryRequest)
+	urlpath.Append(r.URL, "categories", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.CategoryParams)
+}
+
+func encodeDeleteCategoryRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(deleteCategoryRequest)
+	urlpath.Append(r.URL, "categories", req.ID)
+	etag.SetIfMatch(r, req.Version)
+	if req.ReassignTo != "" {
+		q := r.URL.Query()
+		q.Set("reassignTo", req.ReassignTo)
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func encodeListCategoriesRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "categories")
+	return nil
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeCreateDishResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createDishResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeGetDishResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getDishResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeUpdateDishResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp updateDishResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeleteDishResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteDishResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListDishesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listDishesResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeSearchDishesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp searchDishesResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodePriceDishResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp priceDishResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeNutritionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp nutritionResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeCreateCategoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createCategoryResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeGetCategoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getCategoryResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeUpdateCategoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp updateCategoryResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeleteCategoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteCategoryResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListCategoriesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listCategoriesResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
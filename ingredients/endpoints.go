@@ -0,0 +1,244 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package ingredients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the ingredient endpoints, see dishes.Endpoints
+type Endpoints struct {
+	CreateIngredientEndpoint endpoint.Endpoint
+	UpdateIngredientEndpoint endpoint.Endpoint
+	DeleteIngredientEndpoint endpoint.Endpoint
+	GetIngredientEndpoint    endpoint.Endpoint
+	ListIngredientsEndpoint  endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in an ingredients
+// server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		CreateIngredientEndpoint: MakeCreateIngredientEndpoint(s),
+		UpdateIngredientEndpoint: MakeUpdateIngredientEndpoint(s),
+		DeleteIngredientEndpoint: MakeDeleteIngredientEndpoint(s),
+		GetIngredientEndpoint:    MakeGetIngredientEndpoint(s),
+		ListIngredientsEndpoint:  MakeListIngredientsEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "DeleteIngredient".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		CreateIngredientEndpoint: mw("CreateIngredient")(e.CreateIngredientEndpoint),
+		UpdateIngredientEndpoint: mw("UpdateIngredient")(e.UpdateIngredientEndpoint),
+		DeleteIngredientEndpoint: mw("DeleteIngredient")(e.DeleteIngredientEndpoint),
+		GetIngredientEndpoint:    mw("GetIngredient")(e.GetIngredientEndpoint),
+		ListIngredientsEndpoint:  mw("ListIngredients")(e.ListIngredientsEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service
+var Policy = auth.Policy{
+	"GetIngredient":    auth.RoleViewer,
+	"ListIngredients":  auth.RoleViewer,
+	"CreateIngredient": auth.RoleEditor,
+	"UpdateIngredient": auth.RoleEditor,
+	"DeleteIngredient": auth.RoleAdmin,
+}
+
+// CreateIngredient implements Service. Primarily useful in a client.
+func (e Endpoints) CreateIngredient(ctx context.Context, p models.IngredientParams) (*models.Ingredient, error) {
+	response, err := e.CreateIngredientEndpoint(ctx, createIngredientRequest{IngredientParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(createIngredientResponse)
+	return resp.Ingredient, resp.Err
+}
+
+// GetIngredient implements Service. Primarily useful in a client.
+func (e Endpoints) GetIngredient(ctx context.Context, id string) (*models.Ingredient, error) {
+	response, err := e.GetIngredientEndpoint(ctx, getIngredientRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(getIngredientResponse)
+	return resp.Ingredient, resp.Err
+}
+
+// UpdateIngredient implements Service. Primarily useful in a client.
+func (e Endpoints) UpdateIngredient(ctx context.Context, id string, p models.IngredientParams) (*models.Ingredient, error) {
+	response, err := e.UpdateIngredientEndpoint(ctx, updateIngredientRequest{ID: id, IngredientParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(updateIngredientResponse)
+	return resp.Ingredient, resp.Err
+}
+
+// DeleteIngredient implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteIngredient(ctx context.Context, id string, version int64) error {
+	response, err := e.DeleteIngredientEndpoint(ctx, deleteIngredientRequest{ID: id, Version: version})
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteIngredientResponse)
+	return resp.Err
+}
+
+// ListIngredients implements Service. Primarily useful in a client.
+func (e Endpoints) ListIngredients(ctx context.Context) ([]models.Ingredient, error) {
+	response, err := e.ListIngredientsEndpoint(ctx, listIngredientsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listIngredientsResponse)
+	return resp.Ingredients, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+// etagHeader exposes the ingredient version as a strong entity tag
+func etagHeader(m *models.Ingredient) http.Header {
+	if m == nil {
+		return http.Header{}
+	}
+	return etag.Header(m.Version)
+}
+
+type createIngredientRequest struct {
+	models.IngredientParams
+}
+
+type createIngredientResponse struct {
+	*models.Ingredient
+	Err error `json:"-"`
+}
+
+func (r createIngredientResponse) error() error { return r.Err }
+
+func (r createIngredientResponse) Headers() http.Header { return etagHeader(r.Ingredient) }
+
+// StatusCode reports 201 since a new ingredient was created
+func (r createIngredientResponse) StatusCode() int { return http.StatusCreated }
+
+func MakeCreateIngredientEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(createIngredientRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		ingredient, err := s.CreateIngredient(ctx, req.IngredientParams)
+		resp := createIngredientResponse{Ingredient: ingredient, Err: err}
+		return resp, nil
+	}
+}
+
+type getIngredientRequest struct {
+	ID string `json:"id"`
+}
+
+type getIngredientResponse struct {
+	*models.Ingredient
+	Err error `json:"-"`
+}
+
+func (r getIngredientResponse) error() error { return r.Err }
+
+func (r getIngredientResponse) Headers() http.Header { return etagHeader(r.Ingredient) }
+
+func MakeGetIngredientEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(getIngredientRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		ingredient, err := s.GetIngredient(ctx, req.ID)
+		resp := getIngredientResponse{Ingredient: ingredient, Err: err}
+		return resp, nil
+	}
+}
+
+type updateIngredientRequest struct {
+	ID string `json:"id"`
+	models.IngredientParams
+}
+
+type updateIngredientResponse struct {
+	*models.Ingredient
+	Err error `json:"-"`
+}
+
+func (r updateIngredientResponse) error() error { return r.Err }
+
+func (r updateIngredientResponse) Headers() http.Header { return etagHeader(r.Ingredient) }
+
+func MakeUpdateIngredientEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(updateIngredientRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		ingredient, err := s.UpdateIngredient(ctx, req.ID, req.IngredientParams)
+		resp := updateIngredientResponse{Ingredient: ingredient, Err: err}
+		return resp, nil
+	}
+}
+
+type deleteIngredientRequest struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+type deleteIngredientResponse struct {
+	Err error `json:"-"`
+}
+
+func (r deleteIngredientResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing left to return
+func (r deleteIngredientResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeDeleteIngredientEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(deleteIngredientRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeleteIngredient(ctx, req.ID, req.Version)
+		resp := deleteIngredientResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listIngredientsRequest struct{}
+
+type listIngredientsResponse struct {
+	Ingredients []models.Ingredient `json:"values"`
+	Err         error               `json:"-"`
+}
+
+func (r listIngredientsResponse) error() error { return r.Err }
+
+func MakeListIngredientsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if _, ok := request.(listIngredientsRequest); !ok {
+			return nil, errors.New("programmer error")
+		}
+		ingredients, err := s.ListIngredients(ctx)
+		resp := listIngredientsResponse{Ingredients: ingredients, Err: err}
+		return resp, nil
+	}
+}
@@ -0,0 +1,183 @@
+// Service implements the business logic for ingredients
+package ingredients
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service manages the ingredients dish recipes are made of. Every operation
+// acts for the tenant carried by the context, see tenant.NewContext, and
+// never sees the ingredients of other tenants.
+type Service interface {
+	CreateIngredient(ctx context.Context, p models.IngredientParams) (*models.Ingredient, error)
+	GetIngredient(ctx context.Context, id string) (*models.Ingredient, error)
+
+	// UpdateIngredient and DeleteIngredient fail with a conflict error if the
+	// ingredient is no longer at the expected version, see the dishes
+	// service. Updated costs are reflected in dish costings right away.
+	UpdateIngredient(ctx context.Context, id string, p models.IngredientParams) (*models.Ingredient, error)
+	DeleteIngredient(ctx context.Context, id string, version int64) error
+
+	// ListIngredients returns every ingredient ordered by name
+	ListIngredients(ctx context.Context) ([]models.Ingredient, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(opts ...Option) Service {
+	r := &resource{
+		local: make(map[key]models.Ingredient),
+		mu:    &sync.RWMutex{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// key identifies an ingredient, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	local   map[key]models.Ingredient
+	mu      *sync.RWMutex
+	tenants tenant.Registry
+}
+
+func (r *resource) CreateIngredient(ctx context.Context, p models.IngredientParams) (*models.Ingredient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create model
+	ingredient := models.NewIngredient(p)
+
+	// Validate
+	if err := ingredient.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, ingredient.ID}] = *ingredient
+	return ingredient, nil
+}
+
+func (r *resource) GetIngredient(ctx context.Context, id string) (*models.Ingredient, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	ingredient, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &ingredient, nil
+}
+
+func (r *resource) UpdateIngredient(ctx context.Context, id string, p models.IngredientParams) (*models.Ingredient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	ingredient, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	if p.Version != nil {
+		if err := ingredient.CheckVersion(*p.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update the copy
+	ingredient.Apply(p)
+	ingredient.Version++
+	ingredient.Updated = time.Now()
+
+	// Validate
+	if err := ingredient.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, id}] = ingredient
+	return &ingredient, nil
+}
+
+func (r *resource) DeleteIngredient(ctx context.Context, id string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	ingredient, found := r.local[key{t, id}]
+	if !found {
+		return models.ErrNotFound
+	}
+	if err := ingredient.CheckVersion(version); err != nil {
+		return err
+	}
+
+	// Delete model
+	delete(r.local, key{t, id})
+	return nil
+}
+
+func (r *resource) ListIngredients(ctx context.Context) ([]models.Ingredient, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ingredients := []models.Ingredient{}
+	for k, i := range r.local {
+		if k.tenant == t {
+			ingredients = append(ingredients, i)
+		}
+	}
+	sort.Slice(ingredients, func(i, j int) bool {
+		a, b := &ingredients[i], &ingredients[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return ingredients, nil
+}
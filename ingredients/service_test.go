@@ -0,0 +1,93 @@
+//go:build integration
+// +build integration
+
+package ingredients
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeString(s string) *string {
+	return &s
+}
+
+func TestIntegrationIngredientsHTTP(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	// Create
+	cost := models.MustParseMoney("1.20", "USD")
+	kg := models.UnitKilogram
+	flour, err := client.CreateIngredient(ctx, models.IngredientParams{Name: makeString("Flour"), Cost: &cost, Unit: &kg})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), flour.Version)
+	pinch := models.Unit("pinch")
+	_, err = client.CreateIngredient(ctx, models.IngredientParams{Name: makeString("Salt"), Cost: &cost, Unit: &pinch})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "unit", Message: `unknown unit "pinch"`}}, err.(*models.Error).Fields)
+
+	// Update
+	cost = models.MustParseMoney("1.35", "USD")
+	updated, err := client.UpdateIngredient(ctx, flour.ID, models.IngredientParams{Cost: &cost, Version: &flour.Version})
+	require.NoError(t, err)
+	assert.Equal(t, "1.35 USD", updated.Cost.String())
+	_, err = client.UpdateIngredient(ctx, flour.ID, models.IngredientParams{Cost: &cost, Version: &flour.Version})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+
+	// List
+	list, err := client.ListIngredients(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, flour.ID, list[0].ID)
+
+	// Delete
+	require.NoError(t, client.DeleteIngredient(ctx, flour.ID, updated.Version))
+	_, err = client.GetIngredient(ctx, flour.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+}
+
+func TestIntegrationIngredientsTenants(t *testing.T) {
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	cost := models.MustParseMoney("1.20", "USD")
+	kg := models.UnitKilogram
+	flour, err := client.CreateIngredient(bistro, models.IngredientParams{Name: makeString("Flour"), Cost: &cost, Unit: &kg})
+	require.NoError(t, err)
+
+	// The diner sees none of the bistro's ingredients
+	_, err = client.GetIngredient(diner, flour.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.UpdateIngredient(diner, flour.ID, models.IngredientParams{Name: makeString("Stolen")})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	assert.Equal(t, models.KindNotFound, models.KindOf(client.DeleteIngredient(diner, flour.ID, 0)))
+	list, err := client.ListIngredients(diner)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	list, err = client.ListIngredients(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// While the bistro still has them
+	list, err = client.ListIngredients(bistro)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Flour", list[0].Name)
+
+	_, err = client.ListIngredients(tenant.NewContext(context.Background(), "../bistro"))
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
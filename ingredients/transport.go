@@ -0,0 +1,314 @@
+// Transport exposes the service endpoints over HTTP.
+package ingredients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// POST    /ingredients       creates an ingredient
+// GET     /ingredients       lists every ingredient by name
+// GET     /ingredients/{id}  retrieves an ingredient
+// PUT     /ingredients/{id}  replaces an ingredient, all fields are required
+// PATCH   /ingredients/{id}  partially updates an ingredient
+// DELETE  /ingredients/{id}  deletes an ingredient
+//
+// Responses carry the ingredient version in an ETag header. PUT, PATCH and
+// DELETE honour If-Match and fail with 409 Conflict when it changed.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("POST").Path("/ingredients").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CreateIngredientEndpoint,
+		decodeCreateIngredientRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/ingredients").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListIngredientsEndpoint,
+		decodeListIngredientsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/ingredients/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetIngredientEndpoint,
+		decodeGetIngredientRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/ingredients/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateIngredientEndpoint,
+		decodePutIngredientRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/ingredients/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateIngredientEndpoint,
+		decodePatchIngredientRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/ingredients/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteIngredientEndpoint,
+		decodeDeleteIngredientRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in an ingredients client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		CreateIngredientEndpoint: httptransport.NewClient("POST", tgt, encodeCreateIngredientRequest, decodeCreateIngredientResponse, options...).Endpoint(),
+		UpdateIngredientEndpoint: httptransport.NewClient("PATCH", tgt, encodeUpdateIngredientRequest, decodeUpdateIngredientResponse, options...).Endpoint(),
+		DeleteIngredientEndpoint: httptransport.NewClient("DELETE", tgt, encodeDeleteIngredientRequest, decodeDeleteIngredientResponse, options...).Endpoint(),
+		GetIngredientEndpoint:    httptransport.NewClient("GET", tgt, encodeGetIngredientRequest, decodeGetIngredientResponse, options...).Endpoint(),
+		ListIngredientsEndpoint:  httptransport.NewClient("GET", tgt, encodeListIngredientsRequest, decodeListIngredientsResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeCreateIngredientRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req createIngredientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.IngredientParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetIngredientRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getIngredientRequest{ID: id}, nil
+}
+
+func decodePutIngredientRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeUpdateIngredientRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// PUT replaces the whole resource, so every field must be present
+	if req.Name == nil || req.Cost == nil || req.Unit == nil {
+		return nil, models.InvalidArgument("name, cost and unit are required")
+	}
+	return req, nil
+}
+
+func decodePatchIngredientRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return decodeUpdateIngredientRequest(r)
+}
+
+// decodeUpdateIngredientRequest decodes the parts shared by PUT and PATCH
+func decodeUpdateIngredientRequest(r *http.Request) (updateIngredientRequest, error) {
+	var req updateIngredientRequest
+	id, err := pathID(r)
+	if err != nil {
+		return req, err
+	}
+	var params models.IngredientParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return req, models.InvalidArgument("malformed request body: %v", err)
+	}
+	params.Version, err = etag.ExpectedVersion(r, params.Version)
+	if err != nil {
+		return req, err
+	}
+	return updateIngredientRequest{ID: id, IngredientParams: params}, nil
+}
+
+func decodeDeleteIngredientRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	version, err := etag.ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	return deleteIngredientRequest{ID: id, Version: version}, nil
+}
+
+func decodeListIngredientsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listIngredientsRequest{}, nil
+}
+
+// pathID extracts the {id} path variable
+func pathID(r *http.Request) (string, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return id, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeCreateIngredientRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(createIngredientRequest)
+	urlpath.Append(r.URL, "ingredients")
+	return httptransport.EncodeJSONRequest(ctx, r, req.IngredientParams)
+}
+
+func encodeGetIngredientRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getIngredientRequest)
+	urlpath.Append(r.URL, "ingredients", req.ID)
+	return nil
+}
+
+func encodeUpdateIngredientRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(updateIngredientRequest)
+	urlpath.Append(r.URL, "ingredients", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.IngredientParams)
+}
+
+func encodeDeleteIngredientRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(deleteIngredientRequest)
+	urlpath.Append(r.URL, "ingredients", req.ID)
+	etag.SetIfMatch(r, req.Version)
+	return nil
+}
+
+func encodeListIngredientsRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "ingredients")
+	return nil
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeCreateIngredientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createIngredientResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeGetIngredientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getIngredientResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeUpdateIngredientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp updateIngredientResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeleteIngredientResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteIngredientResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListIngredientsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listIngredientsResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
@@ -0,0 +1,362 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the inventory endpoints, see dishes.Endpoints
+type Endpoints struct {
+	SetStockEndpoint    endpoint.Endpoint
+	RestockEndpoint     endpoint.Endpoint
+	GetStockEndpoint    endpoint.Endpoint
+	DeleteStockEndpoint endpoint.Endpoint
+	ListStockEndpoint   endpoint.Endpoint
+	DepleteEndpoint     endpoint.Endpoint
+
+	EightySixEndpoint       endpoint.Endpoint
+	GetEightySixEndpoint    endpoint.Endpoint
+	LiftEightySixEndpoint   endpoint.Endpoint
+	ListEightySixesEndpoint endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in an inventory
+// server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		SetStockEndpoint:    MakeSetStockEndpoint(s),
+		RestockEndpoint:     MakeRestockEndpoint(s),
+		GetStockEndpoint:    MakeGetStockEndpoint(s),
+		DeleteStockEndpoint: MakeDeleteStockEndpoint(s),
+		ListStockEndpoint:   MakeListStockEndpoint(s),
+		DepleteEndpoint:     MakeDepleteEndpoint(s),
+
+		EightySixEndpoint:       MakeEightySixEndpoint(s),
+		GetEightySixEndpoint:    MakeGetEightySixEndpoint(s),
+		LiftEightySixEndpoint:   MakeLiftEightySixEndpoint(s),
+		ListEightySixesEndpoint: MakeListEightySixesEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "SetStock".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		SetStockEndpoint:    mw("SetStock")(e.SetStockEndpoint),
+		RestockEndpoint:     mw("Restock")(e.RestockEndpoint),
+		GetStockEndpoint:    mw("GetStock")(e.GetStockEndpoint),
+		DeleteStockEndpoint: mw("DeleteStock")(e.DeleteStockEndpoint),
+		ListStockEndpoint:   mw("ListStock")(e.ListStockEndpoint),
+		DepleteEndpoint:     mw("Deplete")(e.DepleteEndpoint),
+
+		EightySixEndpoint:       mw("EightySix")(e.EightySixEndpoint),
+		GetEightySixEndpoint:    mw("GetEightySix")(e.GetEightySixEndpoint),
+		LiftEightySixEndpoint:   mw("LiftEightySix")(e.LiftEightySixEndpoint),
+		ListEightySixesEndpoint: mw("ListEightySixes")(e.ListEightySixesEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service. Staff
+// counting stock and 86ing dishes during service are editors.
+var Policy = auth.Policy{
+	"GetStock":        auth.RoleViewer,
+	"ListStock":       auth.RoleViewer,
+	"GetEightySix":    auth.RoleViewer,
+	"ListEightySixes": auth.RoleViewer,
+	"SetStock":        auth.RoleEditor,
+	"Restock":         auth.RoleEditor,
+	"Deplete":         auth.RoleEditor,
+	"EightySix":       auth.RoleEditor,
+	"LiftEightySix":   auth.RoleEditor,
+	"DeleteStock":     auth.RoleAdmin,
+}
+
+// SetStock implements Service. Primarily useful in a client.
+func (e Endpoints) SetStock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error) {
+	response, err := e.SetStockEndpoint(ctx, stockRequest{Kind: kind, ID: id, Quantity: q})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(stockResponse)
+	return resp.StockLevel, resp.Err
+}
+
+// Restock implements Service. Primarily useful in a client.
+func (e Endpoints) Restock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error) {
+	response, err := e.RestockEndpoint(ctx, stockRequest{Kind: kind, ID: id, Quantity: q})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(stockResponse)
+	return resp.StockLevel, resp.Err
+}
+
+// GetStock implements Service. Primarily useful in a client.
+func (e Endpoints) GetStock(ctx context.Context, kind models.StockKind, id string) (*models.StockLevel, error) {
+	response, err := e.GetStockEndpoint(ctx, stockRequest{Kind: kind, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(stockResponse)
+	return resp.StockLevel, resp.Err
+}
+
+// DeleteStock implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteStock(ctx context.Context, kind models.StockKind, id string) error {
+	response, err := e.DeleteStockEndpoint(ctx, stockRequest{Kind: kind, ID: id})
+	if err != nil {
+		return err
+	}
+	resp := response.(noContentResponse)
+	return resp.Err
+}
+
+// ListStock implements Service. Primarily useful in a client.
+func (e Endpoints) ListStock(ctx context.Context) ([]models.StockLevel, error) {
+	response, err := e.ListStockEndpoint(ctx, listStockRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listStockResponse)
+	return resp.Levels, resp.Err
+}
+
+// Deplete implements Service. Primarily useful in a client.
+func (e Endpoints) Deplete(ctx context.Context, usage []models.StockUsage) error {
+	response, err := e.DepleteEndpoint(ctx, depleteRequest{Usage: usage})
+	if err != nil {
+		return err
+	}
+	resp := response.(noContentResponse)
+	return resp.Err
+}
+
+// EightySix implements Service. Primarily useful in a client.
+func (e Endpoints) EightySix(ctx context.Context, dishID string, reason string) (*models.EightySix, error) {
+	response, err := e.EightySixEndpoint(ctx, eightySixRequest{DishID: dishID, Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(eightySixResponse)
+	return resp.EightySix, resp.Err
+}
+
+// GetEightySix implements Service. Primarily useful in a client.
+func (e Endpoints) GetEightySix(ctx context.Context, dishID string) (*models.EightySix, error) {
+	response, err := e.GetEightySixEndpoint(ctx, eightySixRequest{DishID: dishID})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(eightySixResponse)
+	return resp.EightySix, resp.Err
+}
+
+// LiftEightySix implements Service. Primarily useful in a client.
+func (e Endpoints) LiftEightySix(ctx context.Context, dishID string) error {
+	response, err := e.LiftEightySixEndpoint(ctx, eightySixRequest{DishID: dishID})
+	if err != nil {
+		return err
+	}
+	resp := response.(noContentResponse)
+	return resp.Err
+}
+
+// ListEightySixes implements Service. Primarily useful in a client.
+func (e Endpoints) ListEightySixes(ctx context.Context) ([]models.EightySix, error) {
+	response, err := e.ListEightySixesEndpoint(ctx, listEightySixesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listEightySixesResponse)
+	return resp.EightySixes, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+// stockRequest names a stock level, and carries the quantity to set or add
+type stockRequest struct {
+	Kind     models.StockKind `json:"kind"`
+	ID       string           `json:"id"`
+	Quantity models.Quantity  `json:"quantity"`
+}
+
+type stockResponse struct {
+	*models.StockLevel
+	Err error `json:"-"`
+}
+
+func (r stockResponse) error() error { return r.Err }
+
+// noContentResponse is returned by endpoints with nothing to return
+type noContentResponse struct {
+	Err error `json:"-"`
+}
+
+func (r noContentResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing to return
+func (r noContentResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeSetStockEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(stockRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		level, err := s.SetStock(ctx, req.Kind, req.ID, req.Quantity)
+		resp := stockResponse{StockLevel: level, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeRestockEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(stockRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		level, err := s.Restock(ctx, req.Kind, req.ID, req.Quantity)
+		resp := stockResponse{StockLevel: level, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeGetStockEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(stockRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		level, err := s.GetStock(ctx, req.Kind, req.ID)
+		resp := stockResponse{StockLevel: level, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeDeleteStockEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(stockRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeleteStock(ctx, req.Kind, req.ID)
+		resp := noContentResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listStockRequest struct{}
+
+type listStockResponse struct {
+	Levels []models.StockLevel `json:"levels"`
+	Err    error               `json:"-"`
+}
+
+func (r listStockResponse) error() error { return r.Err }
+
+func MakeListStockEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		_, ok := request.(listStockRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		levels, err := s.ListStock(ctx)
+		resp := listStockResponse{Levels: levels, Err: err}
+		return resp, nil
+	}
+}
+
+type depleteRequest struct {
+	Usage []models.StockUsage `json:"usage"`
+}
+
+func MakeDepleteEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(depleteRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.Deplete(ctx, req.Usage)
+		resp := noContentResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type eightySixRequest struct {
+	DishID string `json:"dishId"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type eightySixResponse struct {
+	*models.EightySix
+	Err error `json:"-"`
+}
+
+func (r eightySixResponse) error() error { return r.Err }
+
+func MakeEightySixEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(eightySixRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		e, err := s.EightySix(ctx, req.DishID, req.Reason)
+		resp := eightySixResponse{EightySix: e, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeGetEightySixEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(eightySixRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		e, err := s.GetEightySix(ctx, req.DishID)
+		resp := eightySixResponse{EightySix: e, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeLiftEightySixEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(eightySixRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.LiftEightySix(ctx, req.DishID)
+		resp := noContentResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listEightySixesRequest struct{}
+
+type listEightySixesResponse struct {
+	EightySixes []models.EightySix `json:"eightySixes"`
+	Err         error              `json:"-"`
+}
+
+func (r listEightySixesResponse) error() error { return r.Err }
+
+func MakeListEightySixesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		_, ok := request.(listEightySixesRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		eightySixes, err := s.ListEightySixes(ctx)
+		resp := listEightySixesResponse{EightySixes: eightySixes, Err: err}
+		return resp, nil
+	}
+}
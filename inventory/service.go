@@ -0,0 +1,409 @@
+// Service implements the business logic for inventory
+package inventory
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service tracks stock levels of ingredients and dishes, and dishes taken off
+// sale by hand. Dishes read both to work out their availability, see
+// dishes.WithInventory.
+//
+// Every operation acts for the tenant carried by the context, see
+// tenant.NewContext. Tenants count their own stock and 86 their own dishes,
+// they never see nor touch those of other tenants.
+type Service interface {
+
+	// SetStock sets the level of an ingredient or dish, e.g. after a count,
+	// and starts tracking it if it was not
+	SetStock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error)
+
+	// Restock adds a delivery to the level of an ingredient or dish,
+	// converting it into the unit of the level
+	Restock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error)
+
+	GetStock(ctx context.Context, kind models.StockKind, id string) (*models.StockLevel, error)
+
+	// DeleteStock stops tracking an ingredient or dish, it never runs out
+	// afterwards
+	DeleteStock(ctx context.Context, kind models.StockKind, id string) error
+
+	// ListStock returns every stock level ordered by kind and item ID
+	ListStock(ctx context.Context) ([]models.StockLevel, error)
+
+	// Deplete takes what orders use out of stock, see
+	// models.Dish.StockUsage. Either every tracked item has enough left and
+	// all are depleted, or it fails with a conflict error and none are.
+	// Items that are not tracked are ignored.
+	Deplete(ctx context.Context, usage []models.StockUsage) error
+
+	// EightySix takes a dish off sale until the end of the business day.
+	// 86ing a dish again keeps it off sale for the rest of the day with the
+	// new reason.
+	EightySix(ctx context.Context, dishID string, reason string) (*models.EightySix, error)
+
+	// GetEightySix fails with a not found error unless the dish is 86'd,
+	// 86s that expired are gone
+	GetEightySix(ctx context.Context, dishID string) (*models.EightySix, error)
+
+	// LiftEightySix puts a dish back on sale before its 86 expires
+	LiftEightySix(ctx context.Context, dishID string) error
+
+	// ListEightySixes returns every dish currently 86'd, soonest to expire
+	// first
+	ListEightySixes(ctx context.Context) ([]models.EightySix, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithLocation sets the restaurant's time zone, UTC by default. 86s of a
+// tenant with its own time zone, see tenant.Config, expire by that one.
+func WithLocation(loc *time.Location) Option {
+	return func(r *resource) { r.location = loc }
+}
+
+// WithBusinessDayEnd sets the time of day 86s expire at, in the restaurant's
+// time zone. It is midnight by default.
+func WithBusinessDayEnd(end models.TimeOfDay) Option {
+	return func(r *resource) { r.dayEnd = end }
+}
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(opts ...Option) Service {
+	r := &resource{
+		stock:       make(map[stockKey]models.StockLevel),
+		eightySixes: make(map[dishKey]models.EightySix),
+		mu:          &sync.RWMutex{},
+		location:    time.UTC,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// stockKey identifies a stock level, ingredients and dishes have separate ID
+// spaces, and IDs are only unique within a tenant
+type stockKey struct {
+	tenant string
+	kind   models.StockKind
+	id     string
+}
+
+// dishKey identifies the 86 of a dish of a tenant
+type dishKey struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	stock       map[stockKey]models.StockLevel
+	eightySixes map[dishKey]models.EightySix
+	mu          *sync.RWMutex
+	tenants     tenant.Registry
+
+	// location and dayEnd work out when 86s expire
+	location *time.Location
+	dayEnd   models.TimeOfDay
+}
+
+func validateStockKey(kind models.StockKind, id string) error {
+	err := models.InvalidArgument("invalid stock item")
+	if !kind.Valid() {
+		err = err.WithField("kind", "must be ingredient or dish")
+	}
+	if id == "" {
+		err = err.WithField("itemId", "cannot be empty string")
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+func (r *resource) SetStock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create model
+	level := models.StockLevel{Kind: kind, ItemID: id, Quantity: q, Updated: time.Now()}
+
+	// Validate
+	if err := level.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.stock[stockKey{t, kind, id}] = level
+	return &level, nil
+}
+
+func (r *resource) Restock(ctx context.Context, kind models.StockKind, id string, q models.Quantity) (*models.StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	delivery := models.StockLevel{Kind: kind, ItemID: id, Quantity: q}
+	if err := delivery.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Add to the current level, if any
+	level, found := r.stock[stockKey{t, kind, id}]
+	if !found {
+		level = delivery
+	} else {
+		added, err := q.Convert(level.Quantity.Unit)
+		if err != nil {
+			return nil, models.InvalidArgument("invalid delivery").
+				WithField("quantity.unit", "stock is counted in "+string(level.Quantity.Unit))
+		}
+		level.Quantity.Amount += added.Amount
+	}
+	level.Updated = time.Now()
+
+	// Save model
+	r.stock[stockKey{t, kind, id}] = level
+	return &level, nil
+}
+
+func (r *resource) GetStock(ctx context.Context, kind models.StockKind, id string) (*models.StockLevel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	level, found := r.stock[stockKey{t, kind, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &level, nil
+}
+
+func (r *resource) DeleteStock(ctx context.Context, kind models.StockKind, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	key := stockKey{t, kind, id}
+	if _, found := r.stock[key]; !found {
+		return models.ErrNotFound
+	}
+
+	// Delete model
+	delete(r.stock, key)
+	return nil
+}
+
+func (r *resource) ListStock(ctx context.Context) ([]models.StockLevel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := []models.StockLevel{}
+	for k, l := range r.stock {
+		if k.tenant == t {
+			levels = append(levels, l)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		a, b := &levels[i], &levels[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ItemID < b.ItemID
+	})
+	return levels, nil
+}
+
+func (r *resource) Deplete(ctx context.Context, usage []models.StockUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Sum what every tracked item gives up, exactly and in the unit of its
+	// level, since several dishes may share an ingredient
+	needed := make(map[stockKey]*big.Rat)
+	var keys []stockKey
+	for _, u := range usage {
+		if err := validateStockKey(u.Kind, u.ItemID); err != nil {
+			return err
+		}
+		key := stockKey{t, u.Kind, u.ItemID}
+		level, found := r.stock[key]
+		if !found {
+			continue
+		}
+		amount, err := u.Quantity.Ratio(level.Quantity.Unit)
+		if err != nil {
+			return models.InvalidArgument("cannot take %v of %v %v out of stock counted in %v",
+				u.Quantity, u.Kind, u.ItemID, level.Quantity.Unit)
+		}
+		if needed[key] == nil {
+			needed[key] = new(big.Rat)
+			keys = append(keys, key)
+		}
+		needed[key].Add(needed[key], amount)
+	}
+
+	// Check every level before touching any
+	for _, key := range keys {
+		level := r.stock[key]
+		have := new(big.Rat).SetFloat64(level.Quantity.Amount)
+		if have.Cmp(needed[key]) < 0 {
+			return models.Conflict("not enough %v %v in stock, %v left", key.kind, key.id, level.Quantity)
+		}
+	}
+
+	// Deplete
+	now := time.Now()
+	for _, key := range keys {
+		level := r.stock[key]
+		need, _ := needed[key].Float64()
+		level.Quantity.Amount -= need
+		if level.Quantity.Amount < 0 {
+			// Float rounding of an exactly sufficient level
+			level.Quantity.Amount = 0
+		}
+		level.Updated = now
+		r.stock[key] = level
+	}
+	return nil
+}
+
+func (r *resource) EightySix(ctx context.Context, dishID string, reason string) (*models.EightySix, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, config, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if err := validateStockKey(models.StockDish, dishID); err != nil {
+		return nil, err
+	}
+
+	// Create model
+	now := time.Now().In(config.LocationOr(r.location))
+	e := models.EightySix{
+		DishID:  dishID,
+		Reason:  reason,
+		Until:   models.EndOfBusinessDay(now, r.dayEnd),
+		Created: now,
+	}
+
+	// Save model
+	r.eightySixes[dishKey{t, dishID}] = e
+	return &e, nil
+}
+
+func (r *resource) GetEightySix(ctx context.Context, dishID string) (*models.EightySix, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	e, found := r.eightySixes[dishKey{t, dishID}]
+	if !found || !e.Active(time.Now()) {
+		return nil, models.ErrNotFound
+	}
+	return &e, nil
+}
+
+func (r *resource) LiftEightySix(ctx context.Context, dishID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	e, found := r.eightySixes[dishKey{t, dishID}]
+	if !found || !e.Active(time.Now()) {
+		return models.ErrNotFound
+	}
+
+	// Delete model
+	delete(r.eightySixes, dishKey{t, dishID})
+	return nil
+}
+
+func (r *resource) ListEightySixes(ctx context.Context) ([]models.EightySix, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the active ones, forgetting those that expired
+	now := time.Now()
+	active := []models.EightySix{}
+	for k, e := range r.eightySixes {
+		if k.tenant != t {
+			continue
+		}
+		if !e.Active(now) {
+			delete(r.eightySixes, k)
+			continue
+		}
+		active = append(active, e)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		a, b := &active[i], &active[j]
+		if !a.Until.Equal(b.Until) {
+			return a.Until.Before(b.Until)
+		}
+		return a.DishID < b.DishID
+	})
+	return active, nil
+}
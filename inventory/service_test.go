@@ -0,0 +1,154 @@
+//go:build integration
+// +build integration
+
+package inventory
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationInventoryHTTP(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	// Set and restock
+	flour, err := client.SetStock(ctx, models.StockIngredient, "flour", models.Quantity{Amount: 2, Unit: models.UnitKilogram})
+	require.NoError(t, err)
+	assert.Equal(t, "2 kg", flour.Quantity.String())
+	flour, err = client.Restock(ctx, models.StockIngredient, "flour", models.Quantity{Amount: 500, Unit: models.UnitGram})
+	require.NoError(t, err)
+	assert.Equal(t, "2.5 kg", flour.Quantity.String())
+	_, err = client.Restock(ctx, models.StockIngredient, "flour", models.Quantity{Amount: 1, Unit: models.UnitLiter})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	_, err = client.SetStock(ctx, models.StockDish, "tiramisu", models.Quantity{Amount: 3, Unit: models.UnitKilogram})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "quantity.unit", Message: "dishes are counted in each"}}, err.(*models.Error).Fields)
+	_, err = client.SetStock(ctx, models.StockDish, "tiramisu", models.Quantity{Amount: 3, Unit: models.UnitEach})
+	require.NoError(t, err)
+
+	// Deplete is all or nothing
+	err = client.Deplete(ctx, []models.StockUsage{
+		{Kind: models.StockIngredient, ItemID: "flour", Quantity: models.Quantity{Amount: 2, Unit: models.UnitKilogram}},
+		{Kind: models.StockDish, ItemID: "tiramisu", Quantity: models.Quantity{Amount: 4, Unit: models.UnitEach}},
+	})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	err = client.Deplete(ctx, []models.StockUsage{
+		{Kind: models.StockIngredient, ItemID: "flour", Quantity: models.Quantity{Amount: 2, Unit: models.UnitKilogram}},
+		{Kind: models.StockIngredient, ItemID: "flour", Quantity: models.Quantity{Amount: 500, Unit: models.UnitGram}},
+		{Kind: models.StockIngredient, ItemID: "salt", Quantity: models.Quantity{Amount: 5, Unit: models.UnitGram}},
+		{Kind: models.StockDish, ItemID: "tiramisu", Quantity: models.Quantity{Amount: 1, Unit: models.UnitEach}},
+	})
+	require.NoError(t, err)
+	levels, err := client.ListStock(ctx)
+	require.NoError(t, err)
+	require.Len(t, levels, 2)
+	assert.Equal(t, models.StockDish, levels[0].Kind)
+	assert.Equal(t, 2.0, levels[0].Quantity.Amount)
+	assert.Equal(t, 0.0, levels[1].Quantity.Amount)
+
+	// Stop tracking
+	require.NoError(t, client.DeleteStock(ctx, models.StockIngredient, "flour"))
+	_, err = client.GetStock(ctx, models.StockIngredient, "flour")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationInventoryEightySix(t *testing.T) {
+	ctx := context.Background()
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	s := NewService(WithLocation(nyc), WithBusinessDayEnd(models.NewTimeOfDay(4, 0)))
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(s)))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	// 86 until the end of the business day
+	e, err := client.EightySix(ctx, "salmon", "delivery missed")
+	require.NoError(t, err)
+	assert.Equal(t, "delivery missed", e.Reason)
+	assert.True(t, e.Until.After(time.Now()))
+	assert.Equal(t, 4, e.Until.In(nyc).Hour())
+	assert.True(t, e.Until.Sub(time.Now()) <= 24*time.Hour)
+	got, err := client.GetEightySix(ctx, "salmon")
+	require.NoError(t, err)
+	assert.True(t, e.Until.Equal(got.Until))
+	list, err := client.ListEightySixes(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// Lift
+	require.NoError(t, client.LiftEightySix(ctx, "salmon"))
+	_, err = client.GetEightySix(ctx, "salmon")
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Equal(t, models.ErrNotFound, client.LiftEightySix(ctx, "salmon"))
+}
+
+func TestIntegrationInventoryTenants(t *testing.T) {
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	_, err = client.SetStock(bistro, models.StockIngredient, "flour", models.Quantity{Amount: 2, Unit: models.UnitKilogram})
+	require.NoError(t, err)
+	_, err = client.EightySix(bistro, "salmon", "delivery missed")
+	require.NoError(t, err)
+
+	// The diner sees none of the bistro's stock
+	_, err = client.GetStock(diner, models.StockIngredient, "flour")
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Equal(t, models.ErrNotFound, client.DeleteStock(diner, models.StockIngredient, "flour"))
+	levels, err := client.ListStock(diner)
+	require.NoError(t, err)
+	assert.Empty(t, levels)
+	err = client.Deplete(diner, []models.StockUsage{
+		{Kind: models.StockIngredient, ItemID: "flour", Quantity: models.Quantity{Amount: 2, Unit: models.UnitKilogram}},
+	})
+	require.NoError(t, err)
+
+	// Nor its 86s, and cannot 86 its dishes
+	_, err = client.GetEightySix(diner, "salmon")
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Equal(t, models.ErrNotFound, client.LiftEightySix(diner, "salmon"))
+	_, err = client.EightySix(diner, "steak", "stolen")
+	require.NoError(t, err)
+	list, err := client.ListEightySixes(diner)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "steak", list[0].DishID)
+
+	// The bistro's are untouched
+	flour, err := client.GetStock(bistro, models.StockIngredient, "flour")
+	require.NoError(t, err)
+	assert.Equal(t, "2 kg", flour.Quantity.String())
+	list, err = client.ListEightySixes(bistro)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "salmon", list[0].DishID)
+	_, err = client.GetEightySix(bistro, "steak")
+	assert.Equal(t, models.ErrNotFound, err)
+
+	// 86s expire by the tenant's own time zone
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	s := NewService(WithBusinessDayEnd(models.NewTimeOfDay(4, 0)), WithTenants(tenant.Registry{"bistro": {Location: nyc}, "diner": {}}))
+	e, err := s.EightySix(bistro, "salmon", "delivery missed")
+	require.NoError(t, err)
+	assert.Equal(t, 4, e.Until.In(nyc).Hour())
+	e, err = s.EightySix(diner, "salmon", "delivery missed")
+	require.NoError(t, err)
+	assert.Equal(t, 4, e.Until.In(time.UTC).Hour())
+}
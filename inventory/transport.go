@@ -0,0 +1,360 @@
+// Transport exposes the service endpoints over HTTP.
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// GET     /stock                      lists every stock level
+// POST    /stock/deplete              takes {"usage": [...]} out of stock
+// GET     /stock/{kind}/{id}          retrieves a stock level
+// PUT     /stock/{kind}/{id}          sets a stock level to the quantity in the body
+// POST    /stock/{kind}/{id}/restock  adds the quantity in the body to a stock level
+// DELETE  /stock/{kind}/{id}          stops tracking stock of an item
+//
+// GET     /86                         lists the dishes 86'd today
+// GET     /86/{dishId}                retrieves the 86 of a dish
+// PUT     /86/{dishId}                86s a dish until the end of the business day
+// DELETE  /86/{dishId}                lifts the 86 of a dish
+//
+// Kind is ingredient or dish. Depleting more than is left of any item fails
+// with 409 Conflict and depletes nothing.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("GET").Path("/stock").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListStockEndpoint,
+		decodeListStockRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/stock/deplete").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DepleteEndpoint,
+		decodeDepleteRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/stock/{kind}/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetStockEndpoint,
+		decodeStockRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/stock/{kind}/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.SetStockEndpoint,
+		decodeStockQuantityRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/stock/{kind}/{id}/restock").Handler(httptransport.NewServer(
+		context.Background(),
+		e.RestockEndpoint,
+		decodeStockQuantityRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/stock/{kind}/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteStockEndpoint,
+		decodeStockRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/86").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListEightySixesEndpoint,
+		decodeListEightySixesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/86/{dishId}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetEightySixEndpoint,
+		decodeEightySixRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/86/{dishId}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.EightySixEndpoint,
+		decodePutEightySixRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/86/{dishId}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.LiftEightySixEndpoint,
+		decodeEightySixRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in an inventory client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		SetStockEndpoint:    httptransport.NewClient("PUT", tgt, encodeSetStockRequest, decodeStockResponse, options...).Endpoint(),
+		RestockEndpoint:     httptransport.NewClient("POST", tgt, encodeRestockRequest, decodeStockResponse, options...).Endpoint(),
+		GetStockEndpoint:    httptransport.NewClient("GET", tgt, encodeStockRequest, decodeStockResponse, options...).Endpoint(),
+		DeleteStockEndpoint: httptransport.NewClient("DELETE", tgt, encodeStockRequest, decodeNoContentResponse, options...).Endpoint(),
+		ListStockEndpoint:   httptransport.NewClient("GET", tgt, encodeListStockRequest, decodeListStockResponse, options...).Endpoint(),
+		DepleteEndpoint:     httptransport.NewClient("POST", tgt, encodeDepleteRequest, decodeNoContentResponse, options...).Endpoint(),
+
+		EightySixEndpoint:       httptransport.NewClient("PUT", tgt, encodePutEightySixRequest, decodeEightySixResponse, options...).Endpoint(),
+		GetEightySixEndpoint:    httptransport.NewClient("GET", tgt, encodeEightySixRequest, decodeEightySixResponse, options...).Endpoint(),
+		LiftEightySixEndpoint:   httptransport.NewClient("DELETE", tgt, encodeEightySixRequest, decodeNoContentResponse, options...).Endpoint(),
+		ListEightySixesEndpoint: httptransport.NewClient("GET", tgt, encodeListEightySixesRequest, decodeListEightySixesResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeListStockRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listStockRequest{}, nil
+}
+
+func decodeDepleteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req depleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeStockRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	kind, ok := urlpath.Var(r, "kind")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	return stockRequest{Kind: models.StockKind(kind), ID: id}, nil
+}
+
+func decodeStockQuantityRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request, err := decodeStockRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	req := request.(stockRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req.Quantity); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeListEightySixesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listEightySixesRequest{}, nil
+}
+
+func decodeEightySixRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := urlpath.Var(r, "dishId")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	return eightySixRequest{DishID: id}, nil
+}
+
+// decodePutEightySixRequest reads an optional {"reason": "..."} body
+func decodePutEightySixRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request, err := decodeEightySixRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	req := request.(eightySixRequest)
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	req.Reason = body.Reason
+	return req, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeStockRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(stockRequest)
+	urlpath.Append(r.URL, "stock", string(req.Kind), req.ID)
+	return nil
+}
+
+func encodeSetStockRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(stockRequest)
+	urlpath.Append(r.URL, "stock", string(req.Kind), req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.Quantity)
+}
+
+func encodeRestockRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(stockRequest)
+	urlpath.Append(r.URL, "stock", string(req.Kind), req.ID, "restock")
+	return httptransport.EncodeJSONRequest(ctx, r, req.Quantity)
+}
+
+func encodeListStockRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "stock")
+	return nil
+}
+
+func encodeDepleteRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "stock", "deplete")
+	return httptransport.EncodeJSONRequest(ctx, r, request)
+}
+
+func encodeEightySixRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(eightySixRequest)
+	urlpath.Append(r.URL, "86", req.DishID)
+	return nil
+}
+
+func encodePutEightySixRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(eightySixRequest)
+	urlpath.Append(r.URL, "86", req.DishID)
+	return httptransport.EncodeJSONRequest(ctx, r, struct {
+		Reason string `json:"reason,omitempty"`
+	}{req.Reason})
+}
+
+func encodeListEightySixesRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "86")
+	return nil
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeStockResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp stockResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListStockResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listStockResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeNoContentResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp noContentResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeEightySixResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp eightySixResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListEightySixesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listEightySixesResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
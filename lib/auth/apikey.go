@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var errInvalidAPIKey = models.Unauthenticated("invalid API key")
+
+// APIKey is an API key as stored server side. Keys are handed out as
+// "<id>.<secret>", while only a hash of the secret is stored, so a leaked
+// key file cannot be used to call the API.
+type APIKey struct {
+	ID string `json:"id"`
+
+	// Hash is the hex encoded SHA-256 of the secret, e.g. the output of
+	// printf %s "$secret" | sha256sum
+	Hash string `json:"hash"`
+
+	// Subject names who the key was issued to
+	Subject string `json:"subject"`
+
+	// TenantID restricts the key to a tenant, see Principal.TenantID
+	TenantID string `json:"tenantId,omitempty"`
+
+	// Role is what the key is allowed to do, DefaultRole when empty
+	Role Role `json:"role,omitempty"`
+}
+
+// NewAPIKey generates an API key, returning the key to hand out along with
+// the record to store
+func NewAPIKey(subject, tenantID string, role Role) (string, APIKey) {
+	id := random.SecureString(12)
+	secret := random.SecureString(32)
+	return id + "." + secret, APIKey{
+		ID:       id,
+		Hash:     hashSecret(secret),
+		Subject:  subject,
+		TenantID: tenantID,
+		Role:     role,
+	}
+}
+
+// LoadAPIKeys reads API keys from a JSON file holding an array of APIKey
+func LoadAPIKeys(name string) ([]APIKey, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("malformed API key file %v: %v", name, err)
+	}
+	for i, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ".") {
+			return nil, fmt.Errorf("API key %d in %v has an invalid ID %q", i, name, k.ID)
+		}
+		if k.Subject == "" {
+			return nil, fmt.Errorf("API key %v in %v has no subject", k.ID, name)
+		}
+		if k.Role != "" && !k.Role.Valid() {
+			return nil, fmt.Errorf("API key %v in %v has an unknown role %q", k.ID, name, k.Role)
+		}
+		if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("API key %v in %v has an invalid hash", k.ID, name)
+		}
+	}
+	return keys, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyAPIKey looks up the key by its ID and compares the hash of its secret
+// in constant time. Every failure reports the same error, so callers cannot
+// probe for valid IDs.
+func (a *Authenticator) verifyAPIKey(key string) (*Principal, error) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return nil, errInvalidAPIKey
+	}
+	stored, found := a.keys[parts[0]]
+	if !found {
+		return nil, errInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(strings.ToLower(stored.Hash))) != 1 {
+		return nil, errInvalidAPIKey
+	}
+	role := stored.Role
+	if role == "" {
+		role = DefaultRole
+	}
+	return &Principal{
+		Subject:  stored.Subject,
+		TenantID: stored.TenantID,
+		Role:     role,
+		Method:   MethodAPIKey,
+	}, nil
+}
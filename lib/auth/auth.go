@@ -0,0 +1,215 @@
+// Auth authenticates requests with API keys or signed JWTs, carries the
+// authenticated principal in the context and authorizes it by role.
+//
+// Servers install Authenticator.ToContext as a ServerBefore hook to verify
+// the credentials of each request, and wrap their endpoints in Middleware to
+// reject requests without valid ones, then in a Policy's middleware to reject
+// principals lacking the role an endpoint requires. Clients install ToHTTP as a
+// ClientBefore hook to send the credentials set with WithAPIKey or WithToken.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const (
+	// APIKeyHeader carries an API key over HTTP
+	APIKeyHeader = "X-API-Key"
+
+	// AuthorizationHeader carries a JWT over HTTP, as "Bearer <token>"
+	AuthorizationHeader = "Authorization"
+
+	bearerPrefix = "Bearer "
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrMissingCredentials is returned for requests with neither an API key nor
+// a bearer token
+var ErrMissingCredentials = models.Unauthenticated("missing credentials")
+
+// Principal is who a request acts as
+type Principal struct {
+
+	// Subject identifies the caller, who its API key was issued to or the
+	// subject of its token
+	Subject string `json:"subject"`
+
+	// TenantID restricts the principal to a single tenant. Principals
+	// without one may act for any tenant.
+	TenantID string `json:"tenantId,omitempty"`
+
+	// Role is what the principal is allowed to do, see Policy
+	Role Role `json:"role"`
+
+	// Method is how the principal authenticated, MethodAPIKey or MethodJWT
+	Method string `json:"method"`
+}
+
+type contextKey int
+
+const (
+	principalContextKey contextKey = iota
+	errorContextKey
+	apiKeyContextKey
+	tokenContextKey
+)
+
+// NewContext returns a context acting as the principal
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// FromContext returns the principal the context acts as, if it was
+// authenticated
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(Principal)
+	return p, ok
+}
+
+/**************************************
+ * Server
+ *************************************/
+
+// Option configures the authenticator returned by NewAuthenticator
+type Option func(*Authenticator)
+
+// WithAPIKeys accepts the API keys, see LoadAPIKeys
+func WithAPIKeys(keys []APIKey) Option {
+	return func(a *Authenticator) {
+		for _, k := range keys {
+			a.keys[k.ID] = k
+		}
+	}
+}
+
+// WithJWT accepts bearer tokens verified as configured
+func WithJWT(c JWTConfig) Option {
+	return func(a *Authenticator) { a.jwt = &c }
+}
+
+// Authenticator verifies the credentials of requests. It accepts nothing
+// unless configured with API keys or JWT verification.
+type Authenticator struct {
+	keys map[string]APIKey
+	jwt  *JWTConfig
+	now  func() time.Time
+}
+
+func NewAuthenticator(opts ...Option) *Authenticator {
+	a := &Authenticator{
+		keys: make(map[string]APIKey),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate verifies the API key or the bearer token of the request. It
+// returns nil without an error when the request carries neither, and fails
+// with an unauthenticated error when the credentials are not valid.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	authorization := r.Header.Get(AuthorizationHeader)
+	switch {
+	case key != "" && authorization != "":
+		return nil, models.Unauthenticated("send either an API key or a bearer token, not both")
+	case key != "":
+		return a.verifyAPIKey(key)
+	case authorization != "":
+		if !strings.HasPrefix(authorization, bearerPrefix) {
+			return nil, models.Unauthenticated("unsupported authorization scheme, use Bearer")
+		}
+		if a.jwt == nil {
+			return nil, models.Unauthenticated("bearer tokens are not accepted")
+		}
+		return a.jwt.verify(strings.TrimPrefix(authorization, bearerPrefix), a.now())
+	}
+	return nil, nil
+}
+
+// ToContext is a server RequestFunc authenticating the request and putting
+// the principal into the context, see FromContext. Principals restricted to
+// a tenant act for it, requests naming another tenant are denied.
+// Failures are left in the context for Middleware to report, since request
+// funcs cannot fail.
+func (a *Authenticator) ToContext(ctx context.Context, r *http.Request) context.Context {
+	p, err := a.Authenticate(r)
+	if err != nil {
+		return context.WithValue(ctx, errorContextKey, err)
+	}
+	if p == nil {
+		return ctx
+	}
+	if p.TenantID != "" {
+		if id, ok := tenant.FromContext(ctx); ok && id != p.TenantID {
+			return context.WithValue(ctx, errorContextKey,
+				models.PermissionDenied("%v cannot act for tenant %v", p.Subject, id))
+		}
+		ctx = tenant.NewContext(ctx, p.TenantID)
+	}
+	return NewContext(ctx, *p)
+}
+
+// Middleware rejects requests that were not authenticated by
+// Authenticator.ToContext with the error it ran into, usually an
+// unauthenticated error which transports report as 401 Unauthorized.
+// Rejections are logged along with the name of the method called.
+func Middleware(method string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if _, ok := FromContext(ctx); ok {
+				return next(ctx, request)
+			}
+			err, _ := ctx.Value(errorContextKey).(error)
+			if err == nil {
+				err = ErrMissingCredentials
+			}
+			logrus.WithFields(logrus.Fields{
+				"context": "auth",
+				"method":  method,
+			}).WithError(err).Warn("Rejected unauthenticated request")
+			return nil, err
+		}
+	}
+}
+
+/**************************************
+ * Client
+ *************************************/
+
+// WithAPIKey returns a context sending the API key with every request
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// WithToken returns a context sending the JWT as a bearer token with every
+// request
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenContextKey, token)
+}
+
+// ToHTTP is a client RequestFunc moving the context's API key or token into
+// the request headers
+func ToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if key, ok := ctx.Value(apiKeyContextKey).(string); ok && key != "" {
+		r.Header.Set(APIKeyHeader, key)
+	}
+	if token, ok := ctx.Value(tokenContextKey).(string); ok && token != "" {
+		r.Header.Set(AuthorizationHeader, bearerPrefix+token)
+	}
+	return ctx
+}
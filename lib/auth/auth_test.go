@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// sign serializes a token with the claims, signed with key as alg requires
+func sign(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "typ": "JWT"}) + "." + encode(claims)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(algorithms[alg].hash.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		h := algorithms[alg].hash.New()
+		h.Write([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, algorithms[alg].hash, h.Sum(nil))
+		require.NoError(t, err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func request(headers map[string]string) *http.Request {
+	r := httptest.NewRequest("GET", "/dishes", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestAPIKeys(t *testing.T) {
+	key, stored := NewAPIKey("kitchen", "bistro", "")
+	otherKey, other := NewAPIKey("office", "", RoleAdmin)
+	a := NewAuthenticator(WithAPIKeys([]APIKey{stored, other}))
+	a.now = func() time.Time { return now }
+
+	p, err := a.Authenticate(request(map[string]string{APIKeyHeader: key}))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "kitchen", TenantID: "bistro", Role: RoleViewer, Method: MethodAPIKey}, p)
+	p, err = a.Authenticate(request(map[string]string{APIKeyHeader: otherKey}))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "office", Role: RoleAdmin, Method: MethodAPIKey}, p)
+
+	// The secret has to match the ID
+	for _, bad := range []string{
+		stored.ID + ".wrong",
+		other.ID + key[len(stored.ID):],
+		"unknown" + key[len(stored.ID):],
+		"no-separator",
+	} {
+		_, err := a.Authenticate(request(map[string]string{APIKeyHeader: bad}))
+		assert.Equal(t, errInvalidAPIKey, err, bad)
+	}
+
+	// Only hashes are stored
+	assert.NotContains(t, stored.Hash, key[len(stored.ID)+1:])
+
+	// Anonymous requests are left to the middleware
+	p, err = a.Authenticate(request(nil))
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	_, err = a.Authenticate(request(map[string]string{AuthorizationHeader: "Bearer x.y.z"}))
+	assert.Equal(t, models.KindUnauthenticated, models.KindOf(err))
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("s3cret")
+	private, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	a := NewAuthenticator(WithJWT(JWTConfig{
+		Secret:    secret,
+		PublicKey: &private.PublicKey,
+		Issuer:    "https://login.example.com",
+		Audience:  "polygon",
+		Leeway:    time.Minute,
+	}))
+	a.now = func() time.Time { return now }
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "jeff",
+			"iss": "https://login.example.com",
+			"aud": []string{"polygon", "other"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	testcases := map[string]struct {
+		token    string
+		expected *Principal
+		message  string
+	}{
+		"hmac": {
+			token:    sign(t, "HS256", secret, claims(map[string]interface{}{"tenant": "bistro"})),
+			expected: &Principal{Subject: "jeff", TenantID: "bistro", Role: RoleViewer, Method: MethodJWT},
+		},
+		"rsa": {
+			token:    sign(t, "RS512", private, claims(map[string]interface{}{"aud": "polygon", "role": "manager"})),
+			expected: &Principal{Subject: "jeff", Role: RoleManager, Method: MethodJWT},
+		},
+		"within leeway": {
+			token:    sign(t, "HS384", secret, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})),
+			expected: &Principal{Subject: "jeff", Role: RoleViewer, Method: MethodJWT},
+		},
+		"unknown role": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"role": "owner"})),
+			message: `token has an unknown role "owner"`,
+		},
+		"wrong secret": {
+			token:   sign(t, "HS256", []byte("guess"), claims(nil)),
+			message: "invalid token signature",
+		},
+		"wrong rsa key": {
+			token:   sign(t, "RS256", other, claims(nil)),
+			message: "invalid token signature",
+		},
+		"unsigned": {
+			token:   sign(t, "none", nil, claims(nil)),
+			message: `unsupported token algorithm "none"`,
+		},
+		"expired": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+			message: "token has expired",
+		},
+		"never expires": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"exp": 0})),
+			message: "token has no expiry",
+		},
+		"not yet valid": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+			message: "token is not valid yet",
+		},
+		"wrong issuer": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			message: "token has the wrong issuer",
+		},
+		"wrong audience": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"aud": "other"})),
+			message: "token has the wrong audience",
+		},
+		"no subject": {
+			token:   sign(t, "HS256", secret, claims(map[string]interface{}{"sub": ""})),
+			message: "token has no subject",
+		},
+		"malformed": {
+			token:   "not-a-token",
+			message: "malformed token",
+		},
+	}
+	for msg, tc := range testcases {
+		p, err := a.Authenticate(request(map[string]string{AuthorizationHeader: "Bearer " + tc.token}))
+		if tc.expected != nil {
+			require.NoError(t, err, msg)
+			assert.Equal(t, tc.expected, p, msg)
+			continue
+		}
+		require.Error(t, err, msg)
+		assert.Equal(t, models.KindUnauthenticated, models.KindOf(err), msg)
+		assert.Equal(t, tc.message, err.Error(), msg)
+	}
+
+	// Without a secret HMAC tokens are refused, so an RSA public key can
+	// never be abused as one
+	rsaOnly := NewAuthenticator(WithJWT(JWTConfig{PublicKey: &private.PublicKey}))
+	_, err = rsaOnly.Authenticate(request(map[string]string{
+		AuthorizationHeader: "Bearer " + sign(t, "HS256", []byte{}, claims(nil)),
+	}))
+	assert.Equal(t, models.Unauthenticated(`unsupported token algorithm "HS256"`), err)
+}
+
+func TestMiddleware(t *testing.T) {
+	key, stored := NewAPIKey("kitchen", "bistro", "")
+	a := NewAuthenticator(WithAPIKeys([]APIKey{stored}))
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		p, _ := FromContext(ctx)
+		id, _ := tenant.FromContext(ctx)
+		return p.Subject + "@" + id, nil
+	}
+	call := func(headers map[string]string) (interface{}, error) {
+		r := request(headers)
+		ctx := tenant.ToContext(context.TODO(), r)
+		ctx = a.ToContext(ctx, r)
+		return Middleware("GetDish")(next)(ctx, nil)
+	}
+
+	// The key acts for its tenant
+	resp, err := call(map[string]string{APIKeyHeader: key})
+	require.NoError(t, err)
+	assert.Equal(t, "kitchen@bistro", resp)
+	resp, err = call(map[string]string{APIKeyHeader: key, tenant.Header: "bistro"})
+	require.NoError(t, err)
+	assert.Equal(t, "kitchen@bistro", resp)
+
+	// And no other
+	_, err = call(map[string]string{APIKeyHeader: key, tenant.Header: "diner"})
+	assert.Equal(t, models.PermissionDenied("kitchen cannot act for tenant diner"), err)
+	_, err = call(map[string]string{APIKeyHeader: key + "x"})
+	assert.Equal(t, errInvalidAPIKey, err)
+	_, err = call(nil)
+	assert.Equal(t, ErrMissingCredentials, err)
+}
+
+func TestToHTTP(t *testing.T) {
+	r := request(nil)
+	ToHTTP(WithToken(context.TODO(), "x.y.z"), r)
+	assert.Equal(t, "Bearer x.y.z", r.Header.Get(AuthorizationHeader))
+	assert.Empty(t, r.Header.Get(APIKeyHeader))
+
+	r = request(nil)
+	ToHTTP(WithAPIKey(context.TODO(), "id.secret"), r)
+	assert.Equal(t, "id.secret", r.Header.Get(APIKeyHeader))
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{"GetDish": RoleViewer, "UpdateDish": RoleEditor}
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(role Role, method string) error {
+		ctx := NewContext(context.TODO(), Principal{Subject: "jeff", Role: role})
+		_, err := policy.Middleware(method)(next)(ctx, nil)
+		return err
+	}
+
+	assert.NoError(t, call(RoleViewer, "GetDish"))
+	assert.NoError(t, call(RoleEditor, "UpdateDish"))
+	assert.NoError(t, call(RoleManager, "UpdateDish"))
+	assert.Equal(t, models.PermissionDenied("requires the editor role, jeff is a viewer"), call(RoleViewer, "UpdateDish"))
+
+	// Methods the policy does not name are left to admins
+	assert.NoError(t, call(RoleAdmin, "DeleteDish"))
+	assert.Equal(t, models.KindPermissionDenied, models.KindOf(call(RoleManager, "DeleteDish")))
+
+	// Anonymous requests never get through
+	_, err := policy.Middleware("GetDish")(next)(context.TODO(), nil)
+	assert.Equal(t, ErrMissingCredentials, err)
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// JWTConfig sets how bearer tokens are verified. Tokens signed with HS256,
+// HS384 or HS512 are checked against Secret, tokens signed with RS256, RS384
+// or RS512 against PublicKey. Tokens signed with an algorithm that has no key
+// configured are rejected, as are unsigned tokens.
+//
+// Tokens must carry a subject and an expiry. A "tenant" claim restricts them
+// to a tenant, see Principal.TenantID, and a "role" claim sets what they are
+// allowed to do, DefaultRole without one.
+type JWTConfig struct {
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+}
+
+// algorithms maps the supported JWS algorithms onto their hash, and whether
+// they are RSA signatures rather than HMACs
+var algorithms = map[string]struct {
+	hash crypto.Hash
+	rsa  bool
+}{
+	"HS256": {crypto.SHA256, false},
+	"HS384": {crypto.SHA384, false},
+	"HS512": {crypto.SHA512, false},
+	"RS256": {crypto.SHA256, true},
+	"RS384": {crypto.SHA384, true},
+	"RS512": {crypto.SHA512, true},
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	TenantID  string   `json:"tenant"`
+	Role      Role     `json:"role"`
+}
+
+// audience is the aud claim, which is either a string or an array of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRSAPublicKey parses a PEM encoded PKIX RSA public key, as found in
+// a "PUBLIC KEY" block
+func ParseRSAPublicKey(b []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return pub, nil
+}
+
+// verify checks the signature and the claims of a compact serialized token
+func (c *JWTConfig) verify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, models.Unauthenticated("malformed token")
+	}
+
+	// Check the signature before trusting anything in the token
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, models.Unauthenticated("malformed token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, models.Unauthenticated("malformed token signature")
+	}
+	if err := c.checkSignature(header.Algorithm, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	// Check the claims
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, models.Unauthenticated("malformed token claims")
+	}
+	switch {
+	case claims.Subject == "":
+		return nil, models.Unauthenticated("token has no subject")
+	case claims.ExpiresAt == 0:
+		return nil, models.Unauthenticated("token has no expiry")
+	case !now.Before(time.Unix(claims.ExpiresAt, 0).Add(c.Leeway)):
+		return nil, models.Unauthenticated("token has expired")
+	case claims.NotBefore != 0 && now.Add(c.Leeway).Before(time.Unix(claims.NotBefore, 0)):
+		return nil, models.Unauthenticated("token is not valid yet")
+	case c.Issuer != "" && claims.Issuer != c.Issuer:
+		return nil, models.Unauthenticated("token has the wrong issuer")
+	case c.Audience != "" && !claims.Audience.contains(c.Audience):
+		return nil, models.Unauthenticated("token has the wrong audience")
+	case claims.Role != "" && !claims.Role.Valid():
+		return nil, models.Unauthenticated("token has an unknown role %q", claims.Role)
+	}
+	if claims.Role == "" {
+		claims.Role = DefaultRole
+	}
+	return &Principal{
+		Subject:  claims.Subject,
+		TenantID: claims.TenantID,
+		Role:     claims.Role,
+		Method:   MethodJWT,
+	}, nil
+}
+
+// checkSignature verifies the signature with the key configured for the
+// algorithm. Keys are never picked from the token itself, so a token cannot
+// get an RSA public key used as an HMAC secret.
+func (c *JWTConfig) checkSignature(algorithm, signed string, signature []byte) error {
+	alg, ok := algorithms[algorithm]
+	if !ok {
+		return models.Unauthenticated("unsupported token algorithm %q", algorithm)
+	}
+	if alg.rsa {
+		if c.PublicKey == nil {
+			return models.Unauthenticated("unsupported token algorithm %q", algorithm)
+		}
+		h := alg.hash.New()
+		h.Write([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(c.PublicKey, alg.hash, h.Sum(nil), signature); err != nil {
+			return models.Unauthenticated("invalid token signature")
+		}
+		return nil
+	}
+	if len(c.Secret) == 0 {
+		return models.Unauthenticated("unsupported token algorithm %q", algorithm)
+	}
+	mac := hmac.New(alg.hash.New, c.Secret)
+	mac.Write([]byte(signed))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return models.Unauthenticated("invalid token signature")
+	}
+	return nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Role is what a principal is allowed to do. Roles build on each other, each
+// grants everything the roles below it grant:
+//
+//	viewer   reads everything
+//	editor   also creates and edits, e.g. dish names and stock levels
+//	manager  also sets prices, promotions and taxes
+//	admin    also deletes
+type Role string
+
+const (
+	RoleViewer  Role = "viewer"
+	RoleEditor  Role = "editor"
+	RoleManager Role = "manager"
+	RoleAdmin   Role = "admin"
+)
+
+// DefaultRole is the role of principals whose key or token names none
+const DefaultRole = RoleViewer
+
+var roleRanks = map[Role]int{
+	RoleViewer:  1,
+	RoleEditor:  2,
+	RoleManager: 3,
+	RoleAdmin:   4,
+}
+
+// Valid reports if the role is known
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports if the role grants everything other grants
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// Require returns a permission denied error unless the context's principal
+// has at least the role, or an unauthenticated error without a principal
+func Require(ctx context.Context, role Role) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrMissingCredentials
+	}
+	if !p.Role.Includes(role) {
+		return models.PermissionDenied("requires the %v role, %v is a %v", role, p.Subject, p.Role)
+	}
+	return nil
+}
+
+// Policy maps the methods of a service onto the least role allowed to call
+// them, e.g. {"GetDish": RoleViewer, "DeleteDish": RoleAdmin}
+type Policy map[string]Role
+
+// Middleware rejects callers lacking the role the policy requires for the
+// method with a permission denied error, which transports report as 403
+// Forbidden. Methods missing from the policy are only open to admins. Use
+// it after Middleware, which puts the principal into the context.
+func (p Policy) Middleware(method string) endpoint.Middleware {
+	role, found := p[method]
+	if !found {
+		role = RoleAdmin
+	}
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if err := Require(ctx, role); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"context": "auth",
+					"method":  method,
+				}).WithError(err).Warn("Rejected unauthorized request")
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
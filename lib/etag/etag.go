@@ -0,0 +1,66 @@
+// Etag exposes resource versions as HTTP entity tags, for optimistic
+// concurrency control with If-Match.
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Format returns the strong entity tag of a resource version
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Header returns a header carrying the entity tag of a resource version
+func Header(version int64) http.Header {
+	h := http.Header{}
+	h.Set("ETag", Format(version))
+	return h
+}
+
+// ParseIfMatch returns the version required by the If-Match header, or 0 if
+// any version will do. Only a single strong entity tag is supported.
+func ParseIfMatch(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	bad := models.InvalidArgument("invalid precondition").WithField("If-Match", "must be a single strong entity tag")
+	unquoted, err := strconv.Unquote(v)
+	if err != nil {
+		return 0, bad
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, bad
+	}
+	return version, nil
+}
+
+// ExpectedVersion merges the expected version from If-Match with the one
+// from the request body, which must agree if both are given
+func ExpectedVersion(r *http.Request, body *int64) (*int64, error) {
+	version, err := ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return body, nil
+	}
+	if body != nil && *body != version {
+		return nil, models.InvalidArgument("If-Match does not match the version in the body")
+	}
+	return &version, nil
+}
+
+// SetIfMatch makes a client request conditional on the version, unless it
+// is 0
+func SetIfMatch(r *http.Request, version int64) {
+	if version != 0 {
+		r.Header.Set("If-Match", Format(version))
+	}
+}
@@ -0,0 +1,111 @@
+// Exchange converts money between currencies using exchange rates from a
+// pluggable provider.
+package exchange
+
+import (
+	"math/big"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Provider supplies exchange rates
+type Provider interface {
+
+	// Rate returns how many units of currency to one unit of currency from
+	// buys. It fails with a not found error for unknown currency pairs.
+	Rate(from, to string) (*big.Rat, error)
+}
+
+// ErrNoRate is returned for currency pairs a provider has no rate for
+func ErrNoRate(from, to string) error {
+	return models.NotFound("no exchange rate from %v to %v", from, to)
+}
+
+// Convert converts m into currency to, rounding the result by r
+func Convert(p Provider, m models.Money, to string, r models.Rounding) (models.Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	fromExp, ok := models.CurrencyExponent(m.Currency)
+	if !ok {
+		return models.Money{}, models.InvalidArgument("unknown currency %q", m.Currency)
+	}
+	toExp, ok := models.CurrencyExponent(to)
+	if !ok {
+		return models.Money{}, models.InvalidArgument("unknown currency %q", to)
+	}
+	rate, err := p.Rate(m.Currency, to)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	// Rates apply to whole units, so rescale between the minor units of both
+	// currencies, e.g. cents to yen
+	factor := new(big.Rat).Set(rate)
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toExp-fromExp))), nil)
+	if toExp > fromExp {
+		factor.Mul(factor, new(big.Rat).SetInt(scale))
+	} else {
+		factor.Quo(factor, new(big.Rat).SetInt(scale))
+	}
+	converted, err := m.MulRat(factor, r)
+	if err != nil {
+		return models.Money{}, err
+	}
+	converted.Currency = to
+	return converted, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Table is a fixed set of rates against a base currency. Rates between two
+// other currencies are crossed through the base.
+type Table struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewTable builds a table from decimal rates, e.g. base "USD" and
+// {"EUR": "0.92"} when one dollar buys 0.92 euros.
+func NewTable(base string, rates map[string]string) (*Table, error) {
+	if _, ok := models.CurrencyExponent(base); !ok {
+		return nil, models.InvalidArgument("unknown base currency %q", base)
+	}
+	t := &Table{
+		base:  base,
+		rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+	for currency, s := range rates {
+		if _, ok := models.CurrencyExponent(currency); !ok {
+			return nil, models.InvalidArgument("unknown currency %q", currency)
+		}
+		rate, ok := new(big.Rat).SetString(s)
+		if !ok || rate.Sign() <= 0 {
+			return nil, models.InvalidArgument("invalid %v rate %q", currency, s)
+		}
+		t.rates[currency] = rate
+	}
+	return t, nil
+}
+
+// Base returns the currency every rate is quoted against
+func (t *Table) Base() string {
+	return t.base
+}
+
+func (t *Table) Rate(from, to string) (*big.Rat, error) {
+	fromRate, ok := t.rates[from]
+	if !ok {
+		return nil, ErrNoRate(from, to)
+	}
+	toRate, ok := t.rates[to]
+	if !ok {
+		return nil, ErrNoRate(from, to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
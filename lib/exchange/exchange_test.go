@@ -0,0 +1,96 @@
+package exchange
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	table, err := NewTable("USD", map[string]string{
+		"EUR": "0.92",
+		"JPY": "151.2",
+		"CHF": "0.9",
+		"KWD": "0.3075",
+	})
+	require.NoError(t, err)
+	price := models.MustParseMoney("12.50", "USD")
+
+	testcases := []struct {
+		to       string
+		rounding models.Rounding
+		expected string
+	}{
+		{"USD", models.Rounding{}, "12.50"},
+		{"EUR", models.Rounding{}, "11.50"},
+		{"JPY", models.Rounding{}, "1890"},
+		{"JPY", models.Rounding{Mode: models.RoundUp, Increment: 100}, "1900"},
+		{"JPY", models.Rounding{Mode: models.RoundDown, Increment: 100}, "1800"},
+		{"CHF", models.Rounding{}, "11.25"},
+		{"CHF", models.Rounding{Increment: 10}, "11.30"},
+		{"CHF", models.Rounding{Mode: models.RoundHalfEven, Increment: 10}, "11.20"},
+		{"KWD", models.Rounding{}, "3.844"},
+	}
+	for _, tc := range testcases {
+		m, err := Convert(table, price, tc.to, tc.rounding)
+		require.NoError(t, err, tc.to)
+		assert.Equal(t, models.MustParseMoney(tc.expected, tc.to), m, "%v %v", tc.to, tc.rounding)
+	}
+
+	// Crossed through the base
+	m, err := Convert(table, models.MustParseMoney("1890", "JPY"), "EUR", models.Rounding{})
+	require.NoError(t, err)
+	assert.Equal(t, "11.50", m.Decimal())
+
+	_, err = Convert(table, price, "GBP", models.Rounding{})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+}
+
+func TestNewTableInvalid(t *testing.T) {
+	_, err := NewTable("XXX", nil)
+	assert.Error(t, err)
+	_, err = NewTable("USD", map[string]string{"EUR": "-1"})
+	assert.Error(t, err)
+	_, err = NewTable("USD", map[string]string{"EUR": "abc"})
+	assert.Error(t, err)
+}
+
+func TestFileProviderReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exchange")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rates.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":"0.5"}}`), 0644))
+
+	p, err := NewFileProvider(path, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	rate, err := p.Rate("USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "1/2", rate.String())
+
+	// Changes are picked up once the check interval passed
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":"0.25"}}`), 0644))
+	require.NoError(t, os.Chtimes(path, now.Add(time.Second), now.Add(time.Second)))
+	rate, _ = p.Rate("USD", "EUR")
+	assert.Equal(t, "1/2", rate.String())
+	now = now.Add(2 * time.Minute)
+	rate, _ = p.Rate("USD", "EUR")
+	assert.Equal(t, "1/4", rate.String())
+
+	// A broken file keeps the last good rates
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"base":`), 0644))
+	require.NoError(t, os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute)))
+	now = now.Add(2 * time.Minute)
+	rate, err = p.Rate("USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "1/4", rate.String())
+}
@@ -0,0 +1,110 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// tableFile is the format of an exchange rate file, e.g.
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "JPY": "151.20"}}
+//
+// Rates are strings so they are read exactly.
+type tableFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+// LoadFile reads a table from an exchange rate file
+func LoadFile(path string) (*Table, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f tableFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("malformed exchange rate file %v: %v", path, err)
+	}
+	return NewTable(f.Base, f.Rates)
+}
+
+// FileProvider serves rates from an exchange rate file, picking up changes
+// to the file without a restart. If a changed file cannot be read, the last
+// good rates stay in use.
+type FileProvider struct {
+	path        string
+	checkEvery  time.Duration
+	mu          *sync.Mutex
+	table       *Table
+	modTime     time.Time
+	lastChecked time.Time
+	log         *logrus.Entry
+	now         func() time.Time
+}
+
+// NewFileProvider loads the rate file at path, and checks it for changes at
+// most once per checkEvery. A checkEvery of 0 never reloads.
+func NewFileProvider(path string, checkEvery time.Duration) (*FileProvider, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &FileProvider{
+		path:       path,
+		checkEvery: checkEvery,
+		mu:         &sync.Mutex{},
+		table:      table,
+		modTime:    info.ModTime(),
+		log: logrus.WithFields(logrus.Fields{
+			"context": "exchange.file",
+			"path":    path,
+		}),
+		now: time.Now,
+	}
+	p.lastChecked = p.now()
+	return p, nil
+}
+
+func (p *FileProvider) Rate(from, to string) (*big.Rat, error) {
+	return p.current().Rate(from, to)
+}
+
+// current returns the table, reloading it first if the file changed
+func (p *FileProvider) current() *Table {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.checkEvery == 0 || p.now().Sub(p.lastChecked) < p.checkEvery {
+		return p.table
+	}
+	p.lastChecked = p.now()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		p.log.WithError(err).Warn("Unable to check exchange rates, keeping the last ones")
+		return p.table
+	}
+	if info.ModTime().Equal(p.modTime) {
+		return p.table
+	}
+	table, err := LoadFile(p.path)
+	if err != nil {
+		p.log.WithError(err).Warn("Unable to reload exchange rates, keeping the last ones")
+		return p.table
+	}
+	p.table = table
+	p.modTime = info.ModTime()
+	p.log.Info("Reloaded exchange rates")
+	return p.table
+}
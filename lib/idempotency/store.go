@@ -0,0 +1,88 @@
+// Idempotency remembers the outcome of requests by client supplied key, so
+// that retried requests can be answered without being applied twice.
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// Record is what a store remembers about a key
+type Record struct {
+	// Fingerprint identifies the request parameters, so the same key cannot
+	// be reused for a different request
+	Fingerprint string
+
+	// Value is the outcome of the original request
+	Value interface{}
+
+	Expires time.Time
+}
+
+// Store is an in-memory key store whose records expire after a TTL
+type Store struct {
+	ttl     time.Duration
+	records map[string]Record
+	mu      *sync.Mutex
+
+	// now is swappable for tests
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		records: make(map[string]Record),
+		mu:      &sync.Mutex{},
+		now:     time.Now,
+	}
+}
+
+// Get returns the unexpired record for key
+func (s *Store) Get(key string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, found := s.records[key]
+	if !found || !s.now().Before(rec.Expires) {
+		return Record{}, false
+	}
+	return rec, true
+}
+
+// Put remembers the outcome of the request identified by key and fingerprint
+// until the TTL elapses
+func (s *Store) Put(key, fingerprint string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	s.records[key] = Record{
+		Fingerprint: fingerprint,
+		Value:       value,
+		Expires:     now.Add(s.ttl),
+	}
+}
+
+// sweep drops expired records, at most once per TTL so that puts stay cheap
+func (s *Store) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for key, rec := range s.records {
+		if !now.Before(rec.Expires) {
+			delete(s.records, key)
+		}
+	}
+	s.nextSweep = now.Add(s.ttl)
+}
+
+// Len returns the number of records held, including expired ones not yet
+// swept
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
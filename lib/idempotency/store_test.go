@@ -0,0 +1,35 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreExpiry(t *testing.T) {
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	s.Put("a", "fp", 1)
+	rec, found := s.Get("a")
+	require.True(t, found)
+	assert.Equal(t, "fp", rec.Fingerprint)
+	assert.Equal(t, 1, rec.Value)
+
+	// Still there just before the TTL
+	now = now.Add(time.Hour - time.Second)
+	_, found = s.Get("a")
+	assert.True(t, found)
+
+	// Expired
+	now = now.Add(time.Second)
+	_, found = s.Get("a")
+	assert.False(t, found)
+
+	// Expired records are swept by later puts
+	s.Put("b", "fp", 2)
+	assert.Equal(t, 1, s.Len())
+}
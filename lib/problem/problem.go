@@ -0,0 +1,107 @@
+// Problem encodes and decodes models.Error values as RFC 7807 problem
+// details, so that every HTTP service reports failures the same way.
+//
+// https://tools.ietf.org/html/rfc7807
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is the RFC 7807 body. Kind and Fields are extension members
+// carrying the rest of the models.Error.
+type Problem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Detail string              `json:"detail,omitempty"`
+	Kind   models.ErrorKind    `json:"kind"`
+	Fields []models.FieldError `json:"fields,omitempty"`
+}
+
+var statusCodes = map[models.ErrorKind]int{
+	models.KindNotFound:         http.StatusNotFound,
+	models.KindInvalidArgument:  http.StatusBadRequest,
+	models.KindConflict:         http.StatusConflict,
+	models.KindUnauthenticated:  http.StatusUnauthorized,
+	models.KindPermissionDenied: http.StatusForbidden,
+	models.KindInternal:         http.StatusInternalServerError,
+}
+
+// StatusCode maps an error kind onto an HTTP status code
+func StatusCode(kind models.ErrorKind) int {
+	if code, ok := statusCodes[kind]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// KindFromStatus is the inverse of StatusCode, used when a response carries
+// no problem body at all.
+func KindFromStatus(code int) models.ErrorKind {
+	for kind, c := range statusCodes {
+		if c == code {
+			return kind
+		}
+	}
+	return models.KindInternal
+}
+
+// FromError builds the problem describing err. Messages of errors that are not
+// a *models.Error are hidden since they may leak implementation details.
+func FromError(err error) Problem {
+	e, ok := err.(*models.Error)
+	if !ok {
+		e = models.Internal("internal error")
+	}
+	status := StatusCode(e.Kind)
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: e.Message,
+		Kind:   e.Kind,
+		Fields: e.Fields,
+	}
+}
+
+// ServerErrorEncoder is a go-kit httptransport.ErrorEncoder writing err as
+// problem+json with the status code matching its kind. 401 responses carry
+// the WWW-Authenticate challenge HTTP requires.
+func ServerErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	if err == nil {
+		panic("ServerErrorEncoder with nil error")
+	}
+	p := FromError(err)
+	w.Header().Set("Content-Type", ContentType)
+	if p.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="polygon"`)
+	}
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// DecodeError reads an error response written by ServerErrorEncoder back into
+// the *models.Error the server returned.
+func DecodeError(r *http.Response) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil || p.Kind == "" {
+		return models.NewError(KindFromStatus(r.StatusCode), "unexpected response: %v", r.Status)
+	}
+	return &models.Error{
+		Kind:    p.Kind,
+		Message: p.Detail,
+		Fields:  p.Fields,
+	}
+}
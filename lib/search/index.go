@@ -0,0 +1,268 @@
+// Search implements a small in-process full-text index with prefix matching
+// for autocomplete and typo tolerance.
+package search
+
+import (
+	"bytes"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Relative weight of a query term matching an index term in various ways
+const (
+	exactWeight  = 1.0
+	prefixWeight = 0.8
+	typoWeight   = 0.3 // subtracted per edit
+)
+
+// Field is a piece of text indexed for a document. Matches in fields with a
+// higher weight rank higher.
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// Hit is a document matching a search
+type Hit struct {
+	ID         string
+	Score      float64
+	Highlights []Highlight
+}
+
+// Highlight is a field value where every matching term is wrapped in
+// <em></em>. The rest of the value is HTML escaped.
+type Highlight struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type document struct {
+	fields []Field
+	tokens [][]token
+}
+
+// Index is an inverted index from normalized terms to documents. It is not
+// safe for concurrent use, though concurrent calls to Search are fine.
+type Index struct {
+	docs map[string]*document
+
+	// postings maps term -> document ID -> weighted term frequency
+	postings map[string]map[string]float64
+
+	// terms is the sorted vocabulary, for prefix and typo lookups
+	terms []string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]float64),
+	}
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Add indexes a document, replacing any previous version with the same ID
+func (ix *Index) Add(id string, fields ...Field) {
+	ix.Remove(id)
+
+	doc := &document{fields: fields}
+	for _, f := range fields {
+		tokens := tokenize(f.Text)
+		doc.tokens = append(doc.tokens, tokens)
+		for _, t := range tokens {
+			posting, found := ix.postings[t.term]
+			if !found {
+				posting = make(map[string]float64)
+				ix.postings[t.term] = posting
+				ix.insertTerm(t.term)
+			}
+			posting[id] += f.Weight
+		}
+	}
+	ix.docs[id] = doc
+}
+
+// Remove drops a document from the index, if present
+func (ix *Index) Remove(id string) {
+	doc, found := ix.docs[id]
+	if !found {
+		return
+	}
+	for _, tokens := range doc.tokens {
+		for _, t := range tokens {
+			posting, found := ix.postings[t.term]
+			if !found {
+				continue
+			}
+			delete(posting, id)
+			if len(posting) == 0 {
+				delete(ix.postings, t.term)
+				ix.removeTerm(t.term)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+func (ix *Index) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) removeTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// expand returns the index terms a query term matches, with the weight of
+// each match. The last term of a query may also match as a prefix, so results
+// show up while the user is still typing.
+func (ix *Index) expand(term string, prefix bool) map[string]float64 {
+	matches := make(map[string]float64)
+	add := func(t string, w float64) {
+		if w > matches[t] {
+			matches[t] = w
+		}
+	}
+
+	if _, found := ix.postings[term]; found {
+		add(term, exactWeight)
+	}
+	if prefix {
+		for i := sort.SearchStrings(ix.terms, term); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], term); i++ {
+			add(ix.terms[i], prefixWeight)
+		}
+	}
+	if max := maxEdits(term); max > 0 {
+		for _, t := range ix.terms {
+			if d := distance(term, t, max); d > 0 && d <= max {
+				add(t, exactWeight-typoWeight*float64(d))
+			}
+		}
+	}
+	return matches
+}
+
+// Search returns up to limit documents matching every term of the query,
+// most relevant first. Relevance sums, per query term, the best matching
+// index term weighted by match quality and field weight, times the rarity
+// (idf) of the query term.
+func (ix *Index) Search(query string, limit int) []Hit {
+	return ix.SearchFunc(query, limit, nil)
+}
+
+// SearchFunc is Search restricted to the documents keep returns true for. A
+// nil keep keeps every document. Documents are filtered before the limit
+// applies, so up to limit kept documents are returned.
+func (ix *Index) SearchFunc(query string, limit int, keep func(id string) bool) []Hit {
+	tokens := tokenize(query)
+	if len(tokens) == 0 || len(ix.docs) == 0 {
+		return nil
+	}
+	last, _ := utf8.DecodeLastRuneInString(query)
+	prefixLast := unicode.IsLetter(last) || unicode.IsDigit(last)
+
+	var scores map[string]float64
+	matched := make(map[string]map[string]bool)
+	for i, t := range tokens {
+		prefix := prefixLast && i == len(tokens)-1
+
+		// Best match of this query term per document
+		best := make(map[string]float64)
+		for term, w := range ix.expand(t.term, prefix) {
+			for id, tf := range ix.postings[term] {
+				if s := w * tf; s > best[id] {
+					best[id] = s
+				}
+				if matched[id] == nil {
+					matched[id] = make(map[string]bool)
+				}
+				matched[id][term] = true
+			}
+		}
+
+		// Rare query terms count for more. The idf is shared by all of the
+		// term's matches, so that a typo never outranks an exact match just
+		// because the misspelling is rarer.
+		idf := 0.0
+		if len(best) > 0 {
+			idf = math.Log(1 + float64(len(ix.docs))/float64(len(best)))
+		}
+		for id := range best {
+			best[id] *= idf
+		}
+
+		// Every query term must match
+		if i == 0 {
+			scores = best
+			continue
+		}
+		for id := range scores {
+			if s, found := best[id]; found {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		if keep != nil && !keep(id) {
+			continue
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Highlights = ix.docs[hits[i].ID].highlight(matched[hits[i].ID])
+	}
+	return hits
+}
+
+// highlight marks the tokens whose terms matched, skipping fields without
+// any match.
+func (d *document) highlight(terms map[string]bool) []Highlight {
+	var highlights []Highlight
+	for i, f := range d.fields {
+		var buf bytes.Buffer
+		pos, found := 0, false
+		for _, t := range d.tokens[i] {
+			if !terms[t.term] {
+				continue
+			}
+			found = true
+			buf.WriteString(html.EscapeString(f.Text[pos:t.start]))
+			buf.WriteString("<em>")
+			buf.WriteString(html.EscapeString(f.Text[t.start:t.end]))
+			buf.WriteString("</em>")
+			pos = t.end
+		}
+		if found {
+			buf.WriteString(html.EscapeString(f.Text[pos:]))
+			highlights = append(highlights, Highlight{Field: f.Name, Value: buf.String()})
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(hits []Hit) []string {
+	var out []string
+	for _, h := range hits {
+		out = append(out, h.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", Field{Name: "name", Text: "Spaghetti Carbonara", Weight: 1})
+	ix.Add("2", Field{Name: "name", Text: "Crème Brûlée", Weight: 1})
+	ix.Add("3", Field{Name: "name", Text: "Pasta Pesto", Weight: 1})
+	ix.Add("4", Field{Name: "name", Text: "Pancakes", Weight: 1})
+
+	testcases := map[string]struct {
+		query    string
+		expected []string
+	}{
+		"exact":                {query: "carbonara", expected: []string{"1"}},
+		"case folding":         {query: "SPAGHETTI", expected: []string{"1"}},
+		"diacritics stripped":  {query: "creme brulee", expected: []string{"2"}},
+		"typo":                 {query: "spagheti", expected: []string{"1"}},
+		"transposition":        {query: "psata", expected: []string{"3"}},
+		"prefix":               {query: "pa", expected: []string{"3", "4"}},
+		"prefix only last":     {query: "pa pesto", expected: nil},
+		"all terms must match": {query: "pasta carbonara", expected: nil},
+		"short terms exact":    {query: "pe ", expected: nil},
+		"no terms":             {query: "  !! ", expected: nil},
+	}
+	for msg, tc := range testcases {
+		assert.Equal(t, tc.expected, ids(ix.Search(tc.query, 10)), msg)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("typo", Field{Name: "name", Text: "Pizza Margarita", Weight: 1})
+	ix.Add("exact", Field{Name: "name", Text: "Margherita", Weight: 1})
+	ix.Add("desc", Field{Name: "name", Text: "Flatbread", Weight: 2}, Field{Name: "description", Text: "like a margherita", Weight: 0.5})
+
+	hits := ix.Search("margherita", 10)
+	assert.Equal(t, []string{"exact", "desc", "typo"}, ids(hits))
+	assert.Equal(t, []string{"exact"}, ids(ix.Search("margherita", 1)))
+}
+
+func TestSearchFunc(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("exact", Field{Name: "name", Text: "Margherita", Weight: 1})
+	ix.Add("typo", Field{Name: "name", Text: "Pizza Margarita", Weight: 1})
+
+	// Filtered before the limit applies
+	notExact := func(id string) bool { return id != "exact" }
+	assert.Equal(t, []string{"typo"}, ids(ix.SearchFunc("margherita", 1, notExact)))
+	assert.Equal(t, []string{"exact"}, ids(ix.SearchFunc("margherita", 1, nil)))
+}
+
+func TestSearchHighlights(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", Field{Name: "name", Text: "Fish & Chips", Weight: 1}, Field{Name: "notes", Text: "no match", Weight: 1})
+
+	hits := ix.Search("chip", 10)
+	require.Len(t, hits, 1)
+	assert.Equal(t, []Highlight{{Field: "name", Value: "Fish &amp; <em>Chips</em>"}}, hits[0].Highlights)
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", Field{Name: "name", Text: "Lasagna", Weight: 1})
+	assert.Equal(t, []string{"1"}, ids(ix.Search("lasagna", 10)))
+
+	// Replace
+	ix.Add("1", Field{Name: "name", Text: "Risotto", Weight: 1})
+	assert.Empty(t, ix.Search("lasagna", 10))
+	assert.Equal(t, []string{"1"}, ids(ix.Search("risotto", 10)))
+
+	// Remove
+	ix.Remove("1")
+	assert.Empty(t, ix.Search("risotto", 10))
+	assert.Equal(t, 0, ix.Len())
+	assert.Empty(t, ix.terms)
+}
+
+func TestDistance(t *testing.T) {
+	testcases := []struct {
+		a, b     string
+		max      int
+		expected int
+	}{
+		{"spaghetti", "spaghetti", 2, 0},
+		{"spagheti", "spaghetti", 2, 1},
+		{"psata", "pasta", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 2, 3},
+		{"a", "abcd", 2, 3},
+	}
+	for _, tc := range testcases {
+		assert.Equal(t, tc.expected, distance(tc.a, tc.b, tc.max), tc.a+" "+tc.b)
+	}
+}
@@ -0,0 +1,87 @@
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a normalized term along with where it was found in the original
+// text, so matches can be highlighted.
+type token struct {
+	term       string
+	start, end int
+}
+
+// foldings maps letters with diacritics, and a few ligatures, onto their
+// plain ASCII spelling. Runes are lower cased before the lookup.
+var foldings = map[rune]string{}
+
+func init() {
+	for plain, runes := range map[string]string{
+		"a":  "àáâãäåāăą",
+		"ae": "æ",
+		"c":  "çćĉċč",
+		"d":  "ďđð",
+		"e":  "èéêëēĕėęě",
+		"g":  "ĝğġģ",
+		"h":  "ĥħ",
+		"i":  "ìíîïĩīĭįı",
+		"j":  "ĵ",
+		"k":  "ķ",
+		"l":  "ĺļľŀł",
+		"n":  "ñńņň",
+		"o":  "òóôõöøōŏő",
+		"oe": "œ",
+		"r":  "ŕŗř",
+		"s":  "śŝşš",
+		"ss": "ß",
+		"t":  "ţťŧ",
+		"th": "þ",
+		"u":  "ùúûüũūŭůűų",
+		"w":  "ŵ",
+		"y":  "ýÿŷ",
+		"z":  "źżž",
+	} {
+		for _, r := range runes {
+			foldings[r] = plain
+		}
+	}
+}
+
+// tokenize splits text into runs of letters and digits, folding case and
+// stripping diacritics so "Crème Brûlée" and "creme brulee" share terms.
+func tokenize(text string) []token {
+	var tokens []token
+	var term []byte
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && len(term) > 0 {
+			tokens = append(tokens, token{term: string(term), start: start, end: end})
+		}
+		term = term[:0]
+		start = -1
+	}
+	for i, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks, as in decomposed "é", are dropped
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			r = unicode.ToLower(r)
+			if plain, ok := foldings[r]; ok {
+				term = append(term, plain...)
+			} else {
+				var buf [utf8.UTFMax]byte
+				n := utf8.EncodeRune(buf[:], r)
+				term = append(term, buf[:n]...)
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
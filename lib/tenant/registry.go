@@ -0,0 +1,111 @@
+package tenant
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Config overrides service settings for a single tenant
+type Config struct {
+
+	// MaxPageSize caps listings below the service wide limit, 0 keeps the
+	// service wide limit
+	MaxPageSize int
+
+	// Currency is the currency the tenant prices its dishes in. Dishes with
+	// a base price in any other currency are rejected, an empty currency
+	// accepts every currency.
+	Currency string
+
+	// Location is the tenant's time zone, which menu windows, 86 expiry and
+	// happy hours follow. Nil keeps the service wide time zone.
+	Location *time.Location
+}
+
+// LocationOr returns the tenant's time zone, or loc if it has none of its
+// own
+func (c Config) LocationOr(loc *time.Location) *time.Location {
+	if c.Location != nil {
+		return c.Location
+	}
+	return loc
+}
+
+// Registry lists the tenants a deployment serves along with their settings,
+// see Parse. Every service of the deployment is handed the same registry and
+// rejects requests for any other tenant, or for none. A nil Registry serves
+// every valid tenant ID with the service wide settings, and requests
+// without a tenant act for the default tenant.
+type Registry map[string]Config
+
+// Parse parses tenant settings such as "bistro=EUR/50@Europe/Paris,diner=USD",
+// each tenant ID followed by its currency, optionally its max page size and
+// optionally the IANA name of its time zone after an @. Either of the first
+// two may be left empty, e.g. "bistro=/50".
+func Parse(s string) (Registry, error) {
+	tenants := make(Registry)
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return nil, models.InvalidArgument("malformed tenant %q", rule)
+		}
+		id := strings.TrimSpace(parts[0])
+		if err := Validate(id); err != nil {
+			return nil, models.InvalidArgument("invalid tenant ID %q", id)
+		}
+
+		var config Config
+		spec := strings.TrimSpace(parts[1])
+		if at := strings.Index(spec, "@"); at >= 0 {
+			loc, err := time.LoadLocation(spec[at+1:])
+			if err != nil || spec[at+1:] == "" {
+				return nil, models.InvalidArgument("unknown time zone %q", spec[at+1:])
+			}
+			config.Location = loc
+			spec = spec[:at]
+		}
+		fields := strings.SplitN(spec, "/", 2)
+		config.Currency = fields[0]
+		if config.Currency != "" {
+			if _, ok := models.CurrencyExponent(config.Currency); !ok {
+				return nil, models.InvalidArgument("unknown currency %q", config.Currency)
+			}
+		}
+		if len(fields) == 2 && fields[1] != "" {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return nil, models.InvalidArgument("invalid max page size %q", fields[1])
+			}
+			config.MaxPageSize = n
+		}
+		tenants[id] = config
+	}
+	return tenants, nil
+}
+
+// Lookup returns the tenant the context acts for along with its settings.
+// It fails like Resolve, and unless r is nil with an invalid argument error
+// for requests without a tenant and a not found error for tenants r does
+// not list.
+func (r Registry) Lookup(ctx context.Context) (string, Config, error) {
+	id, err := Resolve(ctx)
+	if err != nil || r == nil {
+		return id, Config{}, err
+	}
+	if id == "" {
+		return "", Config{}, models.InvalidArgument("invalid tenant").WithField(Header, "is required")
+	}
+	config, found := r[id]
+	if !found {
+		return "", Config{}, models.NotFound("unknown tenant %v", id)
+	}
+	return id, config, nil
+}
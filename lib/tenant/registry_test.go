@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	tenants, err := Parse(" bistro=EUR/50@Europe/Paris, diner=USD ,cafe=/20,")
+	require.NoError(t, err)
+	assert.Equal(t, Registry{
+		"bistro": {Currency: "EUR", MaxPageSize: 50, Location: paris},
+		"diner":  {Currency: "USD"},
+		"cafe":   {MaxPageSize: 20},
+	}, tenants)
+	assert.Equal(t, paris, tenants["bistro"].LocationOr(time.UTC))
+	assert.Equal(t, time.UTC, tenants["diner"].LocationOr(time.UTC))
+
+	for _, s := range []string{"bistro", "../bistro=EUR", "bistro=XYZ", "bistro=EUR/0", "bistro=EUR/many", "bistro=EUR@", "bistro=EUR@Mars/Olympus"} {
+		_, err := Parse(s)
+		assert.Equal(t, models.KindInvalidArgument, models.KindOf(err), s)
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	bistro := NewContext(context.TODO(), "bistro")
+
+	// Without a registry every valid tenant is served
+	var open Registry
+	id, _, err := open.Lookup(bistro)
+	require.NoError(t, err)
+	assert.Equal(t, "bistro", id)
+	id, _, err = open.Lookup(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, "", id)
+	_, _, err = open.Lookup(NewContext(context.TODO(), "../bistro"))
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+
+	// With one only the registered tenants are
+	tenants := Registry{"bistro": {Currency: "EUR"}}
+	id, config, err := tenants.Lookup(bistro)
+	require.NoError(t, err)
+	assert.Equal(t, "bistro", id)
+	assert.Equal(t, "EUR", config.Currency)
+	_, _, err = tenants.Lookup(NewContext(context.TODO(), "diner"))
+	assert.Equal(t, models.NotFound("unknown tenant diner"), err)
+	_, _, err = tenants.Lookup(context.TODO())
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
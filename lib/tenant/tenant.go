@@ -0,0 +1,90 @@
+// Tenant carries the restaurant a request acts for, so that one deployment
+// can host many restaurants without them seeing each other's data.
+package tenant
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jeffizhungry/polygon/models"
+)
+
+const (
+	// Header carries the tenant ID over HTTP
+	Header = "X-Tenant-ID"
+
+	maxIDLength = 64
+)
+
+type contextKey int
+
+const (
+	tenantContextKey contextKey = iota
+)
+
+// NewContext returns a context acting for the tenant
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, id)
+}
+
+// FromContext returns the tenant the context acts for, if any. Requests
+// without a tenant act for the default tenant, which has the empty ID.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantContextKey).(string)
+	return id, ok && id != ""
+}
+
+// Resolve returns the tenant the context acts for, the empty ID for the
+// default tenant. It fails with an invalid argument error for a malformed
+// tenant ID, see Validate.
+func Resolve(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	if err := Validate(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ToContext is a server RequestFunc moving the X-Tenant-ID header into the
+// context. A tenant already set on the context is kept.
+func ToContext(ctx context.Context, r *http.Request) context.Context {
+	if _, ok := FromContext(ctx); ok {
+		return ctx
+	}
+	if id := r.Header.Get(Header); id != "" {
+		return NewContext(ctx, id)
+	}
+	return ctx
+}
+
+// ToHTTP is a client RequestFunc moving the context's tenant into the
+// X-Tenant-ID header
+func ToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if id, ok := FromContext(ctx); ok {
+		r.Header.Set(Header, id)
+	}
+	return ctx
+}
+
+// Validate returns an invalid argument error unless id is a usable tenant ID:
+// up to 64 letters, digits, dashes and underscores
+func Validate(id string) error {
+	err := models.InvalidArgument("invalid tenant")
+	switch {
+	case id == "":
+		return err.WithField(Header, "cannot be empty string")
+	case len(id) > maxIDLength:
+		return err.WithField(Header, "cannot be longer than 64 characters")
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return err.WithField(Header, "can only contain letters, digits, - and _")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+// Urlpath builds and reads URL paths made of IDs, which may hold a /, ? or %
+// and still have to stay a single segment of the path.
+package urlpath
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// Append adds the segments to the path of u, escaping each one on its own
+func Append(u *url.URL, segments ...string) {
+	escaped := u.EscapedPath()
+	for _, s := range segments {
+		u.Path += "/" + s
+		escaped += "/" + url.PathEscape(s)
+	}
+	u.RawPath = escaped
+}
+
+// Var returns the named path variable of a request, unescaped. The router
+// must match the escaped path, see mux.Router.UseEncodedPath, so that an
+// escaped / does not split a variable in two.
+func Var(r *http.Request, name string) (string, bool) {
+	v, ok := mux.Vars(r)[name]
+	if !ok {
+		return "", false
+	}
+	v, err := url.PathUnescape(v)
+	if err != nil {
+		return "", false
+	}
+	return v, true
+}
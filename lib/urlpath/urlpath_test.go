@@ -0,0 +1,32 @@
+package urlpath
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppend(t *testing.T) {
+	u, err := url.Parse("http://localhost:8080/api")
+	assert.NoError(t, err)
+	Append(u, "dishes", "a/b?c%d", "price")
+	assert.Equal(t, "/api/dishes/a/b?c%d/price", u.Path)
+	assert.Equal(t, "http://localhost:8080/api/dishes/a%2Fb%3Fc%25d/price", u.String())
+}
+
+func TestVar(t *testing.T) {
+	var id string
+	router := mux.NewRouter().UseEncodedPath()
+	router.Path("/dishes/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = Var(r, "id")
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/dishes/a%2Fb%3Fc%25d", nil))
+	assert.Equal(t, "a/b?c%d", id)
+
+	_, ok := Var(httptest.NewRequest("GET", "/dishes", nil), "id")
+	assert.False(t, ok)
+}
@@ -3,13 +3,28 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
-	"polymail-api/config"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/jeffizhungry/polygon/config"
+	"github.com/jeffizhungry/polygon/dishes"
+	"github.com/jeffizhungry/polygon/dishes/storage"
+	"github.com/jeffizhungry/polygon/ingredients"
+	"github.com/jeffizhungry/polygon/inventory"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/exchange"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/menus"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/jeffizhungry/polygon/orders"
+	"github.com/jeffizhungry/polygon/promotions"
+	"github.com/jeffizhungry/polygon/taxes"
 )
 
 /**************************************
@@ -96,14 +111,141 @@ func poorManMetricsMiddleware(route string) endpoint.Middleware {
 	}
 }
 
+// openDishRepository opens the storage backend selected in config
+func openDishRepository() (storage.Repository, error) {
+	switch config.Storage.Backend {
+	case config.StorageMemory:
+		return storage.NewMemory(), nil
+	case config.StorageFile:
+		return storage.OpenFile(config.Storage.Dir, storage.FileOptions{
+			SnapshotEvery: config.Storage.SnapshotEvery,
+		})
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", config.Storage.Backend)
+}
+
+// makeAuthenticator sets up authentication as configured. It returns no
+// server options and a no-op middleware when auth is disabled.
+func makeAuthenticator() ([]httptransport.ServerOption, func(string) endpoint.Middleware, error) {
+	if config.Auth.Disabled {
+		logrus.Warn("Authentication is disabled, anyone can call the API")
+		return nil, func(string) endpoint.Middleware {
+			return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+		}, nil
+	}
+
+	var opts []auth.Option
+	if config.Auth.APIKeysFile != "" {
+		keys, err := auth.LoadAPIKeys(config.Auth.APIKeysFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, auth.WithAPIKeys(keys))
+	}
+	jwt := auth.JWTConfig{
+		Secret:   []byte(config.Auth.JWTSecret),
+		Issuer:   config.Auth.JWTIssuer,
+		Audience: config.Auth.JWTAudience,
+		Leeway:   config.Auth.JWTLeeway,
+	}
+	if config.Auth.JWTPublicKeyFile != "" {
+		b, err := ioutil.ReadFile(config.Auth.JWTPublicKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if jwt.PublicKey, err = auth.ParseRSAPublicKey(b); err != nil {
+			return nil, nil, fmt.Errorf("invalid JWT public key %v: %v", config.Auth.JWTPublicKeyFile, err)
+		}
+	}
+	if len(jwt.Secret) > 0 || jwt.PublicKey != nil {
+		opts = append(opts, auth.WithJWT(jwt))
+	}
+	if len(opts) == 0 {
+		return nil, nil, errors.New("no API keys or JWT keys are configured, set AUTH_DISABLED=true to run without authentication")
+	}
+
+	authenticator := auth.NewAuthenticator(opts...)
+	options := []httptransport.ServerOption{httptransport.ServerBefore(authenticator.ToContext)}
+	return options, auth.Middleware, nil
+}
+
+// authorized chains authentication with authorize, which enforces the
+// policy of a service. Requests are not authorized when auth is disabled.
+func authorized(authenticated, authorize func(string) endpoint.Middleware) func(string) endpoint.Middleware {
+	if config.Auth.Disabled {
+		return authenticated
+	}
+	return func(method string) endpoint.Middleware {
+		return endpoint.Chain(authenticated(method), authorize(method))
+	}
+}
+
 func main() {
 
 	// Initialize services and inject dependencies
 	svc := NewStringService()
+	location, err := time.LoadLocation(config.Menus.TimeZone)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unknown restaurant time zone")
+	}
+	var tenants tenant.Registry
+	if config.Tenants.Tenants != "" {
+		tenants, err = tenant.Parse(config.Tenants.Tenants)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid tenants")
+		}
+	}
+	ingredientService := ingredients.NewService(ingredients.WithTenants(tenants))
+	dayEnd, err := models.ParseTimeOfDay(config.Inventory.BusinessDayEnd)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid business day end")
+	}
+	inventoryService := inventory.NewService(
+		inventory.WithLocation(location),
+		inventory.WithBusinessDayEnd(dayEnd),
+		inventory.WithTenants(tenants),
+	)
+	dishRepo, err := openDishRepository()
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to open dish storage")
+	}
+	dishOptions := []dishes.Option{
+		dishes.WithRepository(dishRepo),
+		dishes.WithDefaultPageSize(config.Dishes.DefaultPageSize),
+		dishes.WithMaxPageSize(config.Dishes.MaxPageSize),
+		dishes.WithIdempotencyTTL(config.Dishes.IdempotencyTTL),
+		dishes.WithIngredients(ingredientService),
+		dishes.WithInventory(inventoryService),
+		dishes.WithTenants(tenants),
+	}
+	if config.Dishes.CursorSecret != "" {
+		dishOptions = append(dishOptions, dishes.WithCursorSecret([]byte(config.Dishes.CursorSecret)))
+	}
+	if config.Exchange.RatesFile != "" {
+		rates, err := exchange.NewFileProvider(config.Exchange.RatesFile, config.Exchange.ReloadEvery)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to load exchange rates")
+		}
+		dishOptions = append(dishOptions, dishes.WithExchangeRates(rates))
+	}
+	rounding, err := models.ParseRoundingRules(config.Exchange.Rounding)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid rounding rules")
+	}
+	dishOptions = append(dishOptions, dishes.WithRounding(rounding))
+	dishService := dishes.NewService(dishOptions...)
+	menuService := menus.NewService(dishService, menus.WithLocation(location), menus.WithTenants(tenants))
+	promotionService := promotions.NewService(dishService, promotions.WithLocation(location), promotions.WithTenants(tenants))
+	taxService := taxes.NewService(taxes.WithTenants(tenants))
+	orderOptions := []orders.Option{orders.WithInventory(inventoryService), orders.WithTenants(tenants)}
+	if config.Taxes.Jurisdiction != "" {
+		orderOptions = append(orderOptions, orders.WithTaxes(taxService, config.Taxes.Jurisdiction))
+	}
+	orderService := orders.NewService(dishService, orderOptions...)
 
 	// Initialize endpoints
 	toLowerEndpoint := makeToLowerEndpoint(svc)
-	toLowerEndpoint = poorManMetricsMiddleware(toLowerEndpoint)
+	toLowerEndpoint = poorManMetricsMiddleware("toLower")(toLowerEndpoint)
 	toLowerHandler := httptransport.NewServer(
 		context.Background(),
 		toLowerEndpoint,
@@ -112,7 +254,7 @@ func main() {
 	)
 
 	toUpperEndpoint := makeToUpperEndpoint(svc)
-	toUpperEndpoint = poorManMetricsMiddleware(toUpperEndpoint)
+	toUpperEndpoint = poorManMetricsMiddleware("toUpper")(toUpperEndpoint)
 	toUpperHandler := httptransport.NewServer(
 		context.Background(),
 		toUpperEndpoint,
@@ -121,7 +263,7 @@ func main() {
 	)
 
 	lengthEndpoint := makeLengthEndpoint(svc)
-	lengthEndpoint = poorManMetricsMiddleware(lengthEndpoint)
+	lengthEndpoint = poorManMetricsMiddleware("length")(lengthEndpoint)
 	lengthHandler := httptransport.NewServer(
 		context.Background(),
 		lengthEndpoint,
@@ -129,12 +271,45 @@ func main() {
 		encodeResponse,
 	)
 
+	// Every service requires authentication, and authorizes callers by role
+	authOptions, authenticated, err := makeAuthenticator()
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to set up authentication")
+	}
+	dishEndpoints := dishes.MakeServerEndpoints(dishService).Wrap(authorized(authenticated, dishes.Authorize))
+	dishHandler := dishes.MakeHTTPHandler(dishEndpoints, authOptions...)
+	menuHandler := menus.MakeHTTPHandler(menus.MakeServerEndpoints(menuService).Wrap(authorized(authenticated, menus.Policy.Middleware)), authOptions...)
+	ingredientHandler := ingredients.MakeHTTPHandler(ingredients.MakeServerEndpoints(ingredientService).Wrap(authorized(authenticated, ingredients.Policy.Middleware)), authOptions...)
+	inventoryHandler := inventory.MakeHTTPHandler(inventory.MakeServerEndpoints(inventoryService).Wrap(authorized(authenticated, inventory.Policy.Middleware)), authOptions...)
+	orderHandler := orders.MakeHTTPHandler(orders.MakeServerEndpoints(orderService).Wrap(authorized(authenticated, orders.Policy.Middleware)), authOptions...)
+	taxHandler := taxes.MakeHTTPHandler(taxes.MakeServerEndpoints(taxService).Wrap(authorized(authenticated, taxes.Policy.Middleware)), authOptions...)
+	promotionHandler := promotions.MakeHTTPHandler(promotions.MakeServerEndpoints(promotionService).Wrap(authorized(authenticated, promotions.Policy.Middleware)), authOptions...)
+
 	// Register endpoints
 	http.Handle("/toLower", toLowerHandler)
 	http.Handle("/toUpper", toUpperHandler)
 	http.Handle("/length", lengthHandler)
+	http.Handle("/dishes", dishHandler)
+	http.Handle("/dishes/", dishHandler)
+	http.Handle("/categories", dishHandler)
+	http.Handle("/categories/", dishHandler)
+	http.Handle("/menus", menuHandler)
+	http.Handle("/menus/", menuHandler)
+	http.Handle("/ingredients", ingredientHandler)
+	http.Handle("/ingredients/", ingredientHandler)
+	http.Handle("/stock", inventoryHandler)
+	http.Handle("/stock/", inventoryHandler)
+	http.Handle("/86", inventoryHandler)
+	http.Handle("/86/", inventoryHandler)
+	http.Handle("/orders", orderHandler)
+	http.Handle("/orders/", orderHandler)
+	http.Handle("/jurisdictions", taxHandler)
+	http.Handle("/jurisdictions/", taxHandler)
+	http.Handle("/promotions", promotionHandler)
+	http.Handle("/promotions/", promotionHandler)
+	http.Handle("/cart/", promotionHandler)
 
 	// Start server
-	logrus.Info("Listening on...  %v", config.Server.Address())
+	logrus.Infof("Listening on...  %v", config.Server.Address())
 	logrus.Fatal(http.ListenAndServe(config.Server.Address(), nil))
 }
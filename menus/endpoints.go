@@ -0,0 +1,281 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package menus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the menu endpoints, see dishes.Endpoints
+type Endpoints struct {
+	CreateMenuEndpoint endpoint.Endpoint
+	UpdateMenuEndpoint endpoint.Endpoint
+	DeleteMenuEndpoint endpoint.Endpoint
+	GetMenuEndpoint    endpoint.Endpoint
+	ListMenusEndpoint  endpoint.Endpoint
+	ActiveMenuEndpoint endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in a menus server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		CreateMenuEndpoint: MakeCreateMenuEndpoint(s),
+		UpdateMenuEndpoint: MakeUpdateMenuEndpoint(s),
+		DeleteMenuEndpoint: MakeDeleteMenuEndpoint(s),
+		GetMenuEndpoint:    MakeGetMenuEndpoint(s),
+		ListMenusEndpoint:  MakeListMenusEndpoint(s),
+		ActiveMenuEndpoint: MakeActiveMenuEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "DeleteMenu".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		CreateMenuEndpoint: mw("CreateMenu")(e.CreateMenuEndpoint),
+		UpdateMenuEndpoint: mw("UpdateMenu")(e.UpdateMenuEndpoint),
+		DeleteMenuEndpoint: mw("DeleteMenu")(e.DeleteMenuEndpoint),
+		GetMenuEndpoint:    mw("GetMenu")(e.GetMenuEndpoint),
+		ListMenusEndpoint:  mw("ListMenus")(e.ListMenusEndpoint),
+		ActiveMenuEndpoint: mw("ActiveMenu")(e.ActiveMenuEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service
+var Policy = auth.Policy{
+	"GetMenu":    auth.RoleViewer,
+	"ListMenus":  auth.RoleViewer,
+	"ActiveMenu": auth.RoleViewer,
+	"CreateMenu": auth.RoleEditor,
+	"UpdateMenu": auth.RoleEditor,
+	"DeleteMenu": auth.RoleAdmin,
+}
+
+// CreateMenu implements Service. Primarily useful in a client.
+func (e Endpoints) CreateMenu(ctx context.Context, p models.MenuParams) (*models.Menu, error) {
+	response, err := e.CreateMenuEndpoint(ctx, createMenuRequest{MenuParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(createMenuResponse)
+	return resp.Menu, resp.Err
+}
+
+// GetMenu implements Service. Primarily useful in a client.
+func (e Endpoints) GetMenu(ctx context.Context, id string) (*models.Menu, error) {
+	response, err := e.GetMenuEndpoint(ctx, getMenuRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(getMenuResponse)
+	return resp.Menu, resp.Err
+}
+
+// UpdateMenu implements Service. Primarily useful in a client.
+func (e Endpoints) UpdateMenu(ctx context.Context, id string, p models.MenuParams) (*models.Menu, error) {
+	response, err := e.UpdateMenuEndpoint(ctx, updateMenuRequest{ID: id, MenuParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(updateMenuResponse)
+	return resp.Menu, resp.Err
+}
+
+// DeleteMenu implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteMenu(ctx context.Context, id string, version int64) error {
+	response, err := e.DeleteMenuEndpoint(ctx, deleteMenuRequest{ID: id, Version: version})
+	if err != nil {
+		return err
+	}
+	resp := response.(deleteMenuResponse)
+	return resp.Err
+}
+
+// ListMenus implements Service. Primarily useful in a client.
+func (e Endpoints) ListMenus(ctx context.Context) ([]models.Menu, error) {
+	response, err := e.ListMenusEndpoint(ctx, listMenusRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listMenusResponse)
+	return resp.Menus, resp.Err
+}
+
+// ActiveMenu implements Service. Primarily useful in a client.
+func (e Endpoints) ActiveMenu(ctx context.Context, at time.Time) (*ActiveMenu, error) {
+	response, err := e.ActiveMenuEndpoint(ctx, activeMenuRequest{At: at})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(activeMenuResponse)
+	return resp.ActiveMenu, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+// etagHeader exposes the menu version as a strong entity tag
+func etagHeader(m *models.Menu) http.Header {
+	if m == nil {
+		return http.Header{}
+	}
+	return etag.Header(m.Version)
+}
+
+type createMenuRequest struct {
+	models.MenuParams
+}
+
+type createMenuResponse struct {
+	*models.Menu
+	Err error `json:"-"`
+}
+
+func (r createMenuResponse) error() error { return r.Err }
+
+func (r createMenuResponse) Headers() http.Header { return etagHeader(r.Menu) }
+
+// StatusCode reports 201 since a new menu was created
+func (r createMenuResponse) StatusCode() int { return http.StatusCreated }
+
+func MakeCreateMenuEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(createMenuRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		menu, err := s.CreateMenu(ctx, req.MenuParams)
+		resp := createMenuResponse{Menu: menu, Err: err}
+		return resp, nil
+	}
+}
+
+type getMenuRequest struct {
+	ID string `json:"id"`
+}
+
+type getMenuResponse struct {
+	*models.Menu
+	Err error `json:"-"`
+}
+
+func (r getMenuResponse) error() error { return r.Err }
+
+func (r getMenuResponse) Headers() http.Header { return etagHeader(r.Menu) }
+
+func MakeGetMenuEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(getMenuRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		menu, err := s.GetMenu(ctx, req.ID)
+		resp := getMenuResponse{Menu: menu, Err: err}
+		return resp, nil
+	}
+}
+
+type updateMenuRequest struct {
+	ID string `json:"id"`
+	models.MenuParams
+}
+
+type updateMenuResponse struct {
+	*models.Menu
+	Err error `json:"-"`
+}
+
+func (r updateMenuResponse) error() error { return r.Err }
+
+func (r updateMenuResponse) Headers() http.Header { return etagHeader(r.Menu) }
+
+func MakeUpdateMenuEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(updateMenuRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		menu, err := s.UpdateMenu(ctx, req.ID, req.MenuParams)
+		resp := updateMenuResponse{Menu: menu, Err: err}
+		return resp, nil
+	}
+}
+
+type deleteMenuRequest struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+type deleteMenuResponse struct {
+	Err error `json:"-"`
+}
+
+func (r deleteMenuResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing left to return
+func (r deleteMenuResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeDeleteMenuEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(deleteMenuRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeleteMenu(ctx, req.ID, req.Version)
+		resp := deleteMenuResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listMenusRequest struct{}
+
+type listMenusResponse struct {
+	Menus []models.Menu `json:"values"`
+	Err   error         `json:"-"`
+}
+
+func (r listMenusResponse) error() error { return r.Err }
+
+func MakeListMenusEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if _, ok := request.(listMenusRequest); !ok {
+			return nil, errors.New("programmer error")
+		}
+		menus, err := s.ListMenus(ctx)
+		resp := listMenusResponse{Menus: menus, Err: err}
+		return resp, nil
+	}
+}
+
+type activeMenuRequest struct {
+	At time.Time `json:"at"`
+}
+
+type activeMenuResponse struct {
+	*ActiveMenu
+	Err error `json:"-"`
+}
+
+func (r activeMenuResponse) error() error { return r.Err }
+
+func MakeActiveMenuEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(activeMenuRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		active, err := s.ActiveMenu(ctx, req.At)
+		resp := activeMenuResponse{ActiveMenu: active, Err: err}
+		return resp, nil
+	}
+}
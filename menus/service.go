@@ -0,0 +1,314 @@
+// Service implements the business logic for menus
+package menus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service manages menus and works out which one is active. Every operation
+// acts for the tenant carried by the context, see tenant.NewContext, and
+// never sees the menus of other tenants.
+type Service interface {
+	CreateMenu(ctx context.Context, p models.MenuParams) (*models.Menu, error)
+	GetMenu(ctx context.Context, id string) (*models.Menu, error)
+
+	// UpdateMenu and DeleteMenu fail with a conflict error if the menu is no
+	// longer at the expected version, see the dishes service.
+	UpdateMenu(ctx context.Context, id string, p models.MenuParams) (*models.Menu, error)
+	DeleteMenu(ctx context.Context, id string, version int64) error
+
+	// ListMenus returns every menu, highest priority first
+	ListMenus(ctx context.Context) ([]models.Menu, error)
+
+	// ActiveMenu returns the menu offered at the instant, or now if it is
+	// zero, along with its dishes. Availability windows are evaluated in the
+	// restaurant's time zone. It fails with a not found error when no menu
+	// is available.
+	ActiveMenu(ctx context.Context, at time.Time) (*ActiveMenu, error)
+}
+
+// Dishes looks up the dishes menus refer to, it is implemented by
+// dishes.Service
+type Dishes interface {
+	GetDish(ctx context.Context, id string, currency string) (*models.Dish, error)
+}
+
+// ActiveMenu is a menu as offered at an instant, with its dishes resolved
+type ActiveMenu struct {
+	Menu models.Menu `json:"menu"`
+
+	// At is the instant in the tenant's time zone
+	At time.Time `json:"at"`
+
+	Sections []ActiveSection `json:"sections"`
+}
+
+// ActiveSection is a menu section with its dishes, in menu order. Dishes
+// deleted since they were put on the menu are left out.
+type ActiveSection struct {
+	Name   string        `json:"name"`
+	Dishes []models.Dish `json:"dishes"`
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithLocation sets the restaurant's time zone, UTC by default. Tenants with a
+// time zone of their own follow it instead, see tenant.Config.
+func WithLocation(loc *time.Location) Option {
+	return func(r *resource) { r.location = loc }
+}
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(dishes Dishes, opts ...Option) Service {
+	r := &resource{
+		local:    make(map[key]models.Menu),
+		mu:       &sync.RWMutex{},
+		dishes:   dishes,
+		location: time.UTC,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// key identifies a menu, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	local    map[key]models.Menu
+	mu       *sync.RWMutex
+	dishes   Dishes
+	location *time.Location
+	tenants  tenant.Registry
+}
+
+// checkDishes verifies every dish on the menu exists
+func (r *resource) checkDishes(ctx context.Context, m *models.Menu) error {
+	bad := models.InvalidArgument("invalid menu")
+	for i, s := range m.Sections {
+		for j, id := range s.DishIDs {
+			_, err := r.dishes.GetDish(ctx, id, "")
+			if models.KindOf(err) == models.KindNotFound {
+				bad = bad.WithField(fmt.Sprintf("sections[%d].dishIds[%d]", i, j), "unknown dish")
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(bad.Fields) > 0 {
+		return bad
+	}
+	return nil
+}
+
+func (r *resource) CreateMenu(ctx context.Context, p models.MenuParams) (*models.Menu, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create model
+	menu := models.NewMenu(p)
+
+	// Validate
+	if err := menu.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.checkDishes(ctx, menu); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, menu.ID}] = *menu
+	return menu, nil
+}
+
+func (r *resource) GetMenu(ctx context.Context, id string) (*models.Menu, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	menu, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &menu, nil
+}
+
+func (r *resource) UpdateMenu(ctx context.Context, id string, p models.MenuParams) (*models.Menu, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	menu, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	if p.Version != nil {
+		if err := menu.CheckVersion(*p.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update the copy
+	menu.Apply(p)
+	menu.Version++
+	menu.Updated = time.Now()
+
+	// Validate
+	if err := menu.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Dishes deleted since are tolerated, unless the sections are replaced
+	if p.Sections != nil {
+		if err := r.checkDishes(ctx, &menu); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save model
+	r.local[key{t, id}] = menu
+	return &menu, nil
+}
+
+func (r *resource) DeleteMenu(ctx context.Context, id string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	menu, found := r.local[key{t, id}]
+	if !found {
+		return models.ErrNotFound
+	}
+	if err := menu.CheckVersion(version); err != nil {
+		return err
+	}
+
+	// Delete model
+	delete(r.local, key{t, id})
+	return nil
+}
+
+func (r *resource) ListMenus(ctx context.Context) ([]models.Menu, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.sorted(t), nil
+}
+
+// sorted returns every menu of the tenant, highest priority first, then by
+// name
+func (r *resource) sorted(t string) []models.Menu {
+	menus := []models.Menu{}
+	for k, m := range r.local {
+		if k.tenant == t {
+			menus = append(menus, m)
+		}
+	}
+	sort.Slice(menus, func(i, j int) bool {
+		a, b := &menus[i], &menus[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return menus
+}
+
+func (r *resource) ActiveMenu(ctx context.Context, at time.Time) (*ActiveMenu, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, config, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if at.IsZero() {
+		at = time.Now()
+	}
+	local := at.In(config.LocationOr(r.location))
+
+	// Find the first available menu in priority order
+	var menu *models.Menu
+	menus := r.sorted(t)
+	for i := range menus {
+		if menus[i].AvailableAt(local) {
+			menu = &menus[i]
+			break
+		}
+	}
+	if menu == nil {
+		return nil, models.NotFound("no menu is available at %v", local.Format(time.RFC3339))
+	}
+
+	// Resolve dishes
+	active := &ActiveMenu{
+		Menu:     *menu,
+		At:       local,
+		Sections: make([]ActiveSection, 0, len(menu.Sections)),
+	}
+	for _, s := range menu.Sections {
+		section := ActiveSection{Name: s.Name, Dishes: []models.Dish{}}
+		for _, id := range s.DishIDs {
+			dish, err := r.dishes.GetDish(ctx, id, "")
+			if models.KindOf(err) == models.KindNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			// Costs are for managers, not customers
+			dish.Costing = nil
+			section.Dishes = append(section.Dishes, *dish)
+		}
+		active.Sections = append(active.Sections, section)
+	}
+	return active, nil
+}
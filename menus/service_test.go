@@ -0,0 +1,233 @@
+//go:build integration
+// +build integration
+
+package menus
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/dishes"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeString(s string) *string {
+	return &s
+}
+
+func makeInt(v int) *int {
+	return &v
+}
+
+func makeDish(t *testing.T, s dishes.Service, name string) *models.Dish {
+	price := models.MustParseMoney("10.00", "USD")
+	dish, err := s.CreateDish(context.Background(), models.DishParams{Name: &name, Price: &price})
+	require.NoError(t, err)
+	return dish
+}
+
+func TestIntegrationActiveMenu(t *testing.T) {
+	ctx := context.Background()
+	dishService := dishes.NewService()
+	pancakes := makeDish(t, dishService, "Pancakes")
+	burger := makeDish(t, dishService, "Burger")
+	wings := makeDish(t, dishService, "Wings")
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	s := NewService(dishService, WithLocation(tokyo))
+
+	// Unknown dishes are rejected
+	_, err = s.CreateMenu(ctx, models.MenuParams{
+		Name:     makeString("Broken"),
+		Sections: &[]models.MenuSection{{Name: "Mains", DishIDs: []string{"missing"}}},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "sections[0].dishIds[0]", Message: "unknown dish"}}, err.(*models.Error).Fields)
+
+	// No menus, nothing is active
+	_, err = s.ActiveMenu(ctx, time.Time{})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+
+	allDay, err := s.CreateMenu(ctx, models.MenuParams{
+		Name:     makeString("All day"),
+		Sections: &[]models.MenuSection{{Name: "Mains", DishIDs: []string{burger.ID, pancakes.ID}}},
+	})
+	require.NoError(t, err)
+	breakfast, err := s.CreateMenu(ctx, models.MenuParams{
+		Name:     makeString("Breakfast"),
+		Sections: &[]models.MenuSection{{Name: "Sweet", DishIDs: []string{pancakes.ID}}},
+		Availability: &[]models.Availability{
+			{Days: models.Weekdays, Start: models.NewTimeOfDay(7, 0), End: models.NewTimeOfDay(11, 0)},
+		},
+		Priority: makeInt(10),
+	})
+	require.NoError(t, err)
+	lateNight, err := s.CreateMenu(ctx, models.MenuParams{
+		Name:     makeString("Late night"),
+		Sections: &[]models.MenuSection{{Name: "Snacks", DishIDs: []string{wings.ID}}},
+		Availability: &[]models.Availability{
+			{Days: []models.Weekday{models.Weekday(time.Friday)}, Start: models.NewTimeOfDay(22, 0), End: models.NewTimeOfDay(2, 0)},
+		},
+		Priority: makeInt(5),
+	})
+	require.NoError(t, err)
+
+	// Windows are evaluated in Tokyo time, 2017-06-02 is a Friday
+	testcases := []struct {
+		at       time.Time
+		expected string
+	}{
+		{time.Date(2017, time.June, 1, 23, 0, 0, 0, time.UTC), breakfast.ID},  // Fri 08:00
+		{time.Date(2017, time.June, 2, 3, 0, 0, 0, time.UTC), allDay.ID},      // Fri 12:00
+		{time.Date(2017, time.June, 2, 14, 0, 0, 0, time.UTC), lateNight.ID},  // Fri 23:00
+		{time.Date(2017, time.June, 2, 16, 30, 0, 0, time.UTC), lateNight.ID}, // Sat 01:30
+		{time.Date(2017, time.June, 2, 23, 0, 0, 0, time.UTC), allDay.ID},     // Sat 08:00
+	}
+	for _, tc := range testcases {
+		active, err := s.ActiveMenu(ctx, tc.at)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, active.Menu.ID, "%v", tc.at)
+		assert.Equal(t, tokyo, active.At.Location())
+	}
+
+	// Deleted dishes drop off the menu
+	require.NoError(t, dishService.DeleteDish(ctx, burger.ID, 0))
+	active, err := s.ActiveMenu(ctx, time.Date(2017, time.June, 2, 3, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, active.Sections, 1)
+	require.Len(t, active.Sections[0].Dishes, 1)
+	assert.Equal(t, pancakes.ID, active.Sections[0].Dishes[0].ID)
+
+	// Updates are versioned
+	_, err = s.UpdateMenu(ctx, allDay.ID, models.MenuParams{Priority: makeInt(1), Version: &allDay.Version})
+	require.NoError(t, err)
+	_, err = s.UpdateMenu(ctx, allDay.ID, models.MenuParams{Priority: makeInt(2), Version: &allDay.Version})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+
+	menus, err := s.ListMenus(ctx)
+	require.NoError(t, err)
+	require.Len(t, menus, 3)
+	assert.Equal(t, []string{breakfast.ID, lateNight.ID, allDay.ID}, []string{menus[0].ID, menus[1].ID, menus[2].ID})
+}
+
+func TestIntegrationMenusHTTP(t *testing.T) {
+	ctx := context.Background()
+	dishService := dishes.NewService()
+	pancakes := makeDish(t, dishService, "Pancakes")
+
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(dishService))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	menu, err := client.CreateMenu(ctx, models.MenuParams{
+		Name:     makeString("Breakfast"),
+		Sections: &[]models.MenuSection{{Name: "Sweet", DishIDs: []string{pancakes.ID}}},
+		Availability: &[]models.Availability{
+			{Days: models.Weekdays, Start: models.NewTimeOfDay(7, 0), End: models.NewTimeOfDay(11, 0)},
+		},
+	})
+	require.NoError(t, err)
+
+	got, err := client.GetMenu(ctx, menu.ID)
+	require.NoError(t, err)
+	assert.Equal(t, menu.Availability, got.Availability)
+
+	active, err := client.ActiveMenu(ctx, time.Date(2017, time.June, 2, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, menu.ID, active.Menu.ID)
+	require.Len(t, active.Sections, 1)
+	assert.Equal(t, []string{pancakes.ID}, []string{active.Sections[0].Dishes[0].ID})
+
+	_, err = client.ActiveMenu(ctx, time.Date(2017, time.June, 3, 8, 0, 0, 0, time.UTC))
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+
+	assert.Equal(t, models.KindConflict, models.KindOf(client.DeleteMenu(ctx, menu.ID, menu.Version+1)))
+	require.NoError(t, client.DeleteMenu(ctx, menu.ID, menu.Version))
+	_, err = client.GetMenu(ctx, menu.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+}
+
+func TestIntegrationMenusTenants(t *testing.T) {
+	dishService := dishes.NewService()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(dishService))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	price := models.MustParseMoney("10.00", "USD")
+	pasta, err := dishService.CreateDish(bistro, models.DishParams{Name: makeString("Pasta"), Price: &price})
+	require.NoError(t, err)
+	params := models.MenuParams{
+		Name:     makeString("All day"),
+		Sections: &[]models.MenuSection{{Name: "Mains", DishIDs: []string{pasta.ID}}},
+	}
+
+	// Menus only offer the tenant's own dishes
+	_, err = client.CreateMenu(diner, params)
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "sections[0].dishIds[0]", Message: "unknown dish"}}, err.(*models.Error).Fields)
+	menu, err := client.CreateMenu(bistro, params)
+	require.NoError(t, err)
+
+	// The diner sees none of the bistro's menus
+	_, err = client.GetMenu(diner, menu.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.UpdateMenu(diner, menu.ID, models.MenuParams{Name: makeString("Stolen")})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	assert.Equal(t, models.KindNotFound, models.KindOf(client.DeleteMenu(diner, menu.ID, 0)))
+	list, err := client.ListMenus(diner)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	_, err = client.ActiveMenu(diner, time.Time{})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+
+	active, err := client.ActiveMenu(bistro, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, menu.ID, active.Menu.ID)
+	assert.Equal(t, pasta.ID, active.Sections[0].Dishes[0].ID)
+
+	// Once tenants are registered, no other tenant is served
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	registry := tenant.Registry{"bistro": {Location: tokyo}}
+	dishService = dishes.NewService(dishes.WithTenants(registry))
+	registered := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(dishService, WithTenants(registry)))))
+	defer registered.Close()
+	client, err = MakeClientEndpoints(registered.URL)
+	require.NoError(t, err)
+	_, err = client.CreateMenu(tenant.NewContext(context.Background(), "cafe"), models.MenuParams{Name: makeString("All day")})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.ListMenus(tenant.NewContext(context.Background(), "cafe"))
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.ListMenus(context.Background())
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	list, err = client.ListMenus(bistro)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// Menu windows follow the tenant's own time zone
+	pasta, err = dishService.CreateDish(bistro, models.DishParams{Name: makeString("Pasta"), Price: &price})
+	require.NoError(t, err)
+	_, err = client.CreateMenu(bistro, models.MenuParams{
+		Name:     makeString("Breakfast"),
+		Sections: &[]models.MenuSection{{Name: "Mains", DishIDs: []string{pasta.ID}}},
+		Availability: &[]models.Availability{
+			{Days: models.Weekdays, Start: models.NewTimeOfDay(7, 0), End: models.NewTimeOfDay(11, 0)},
+		},
+	})
+	require.NoError(t, err)
+	active, err = client.ActiveMenu(bistro, time.Date(2017, time.June, 1, 23, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 8, active.At.Hour(), "08:00 in Tokyo")
+	_, err = client.ActiveMenu(bistro, time.Date(2017, time.June, 1, 8, 0, 0, 0, time.UTC))
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+}
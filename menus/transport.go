@@ -0,0 +1,365 @@
+// Transport exposes the service endpoints over HTTP.
+package menus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// POST    /menus         creates a menu
+// GET     /menus         lists every menu, highest priority first
+// GET     /menus/active  retrieves the menu offered now, or at the RFC 3339 time at
+// GET     /menus/{id}    retrieves a menu
+// PUT     /menus/{id}    replaces a menu, name is required
+// PATCH   /menus/{id}    partially updates a menu
+// DELETE  /menus/{id}    deletes a menu
+//
+// Responses carry the menu version in an ETag header. PUT, PATCH and DELETE
+// honour If-Match and fail with 409 Conflict when it changed.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("POST").Path("/menus").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CreateMenuEndpoint,
+		decodeCreateMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/menus").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListMenusEndpoint,
+		decodeListMenusRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/menus/active").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ActiveMenuEndpoint,
+		decodeActiveMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/menus/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetMenuEndpoint,
+		decodeGetMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/menus/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateMenuEndpoint,
+		decodePutMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/menus/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdateMenuEndpoint,
+		decodePatchMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/menus/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteMenuEndpoint,
+		decodeDeleteMenuRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in a menus client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		CreateMenuEndpoint: httptransport.NewClient("POST", tgt, encodeCreateMenuRequest, decodeCreateMenuResponse, options...).Endpoint(),
+		UpdateMenuEndpoint: httptransport.NewClient("PATCH", tgt, encodeUpdateMenuRequest, decodeUpdateMenuResponse, options...).Endpoint(),
+		DeleteMenuEndpoint: httptransport.NewClient("DELETE", tgt, encodeDeleteMenuRequest, decodeDeleteMenuResponse, options...).Endpoint(),
+		GetMenuEndpoint:    httptransport.NewClient("GET", tgt, encodeGetMenuRequest, decodeGetMenuResponse, options...).Endpoint(),
+		ListMenusEndpoint:  httptransport.NewClient("GET", tgt, encodeListMenusRequest, decodeListMenusResponse, options...).Endpoint(),
+		ActiveMenuEndpoint: httptransport.NewClient("GET", tgt, encodeActiveMenuRequest, decodeActiveMenuResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeCreateMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req createMenuRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.MenuParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getMenuRequest{ID: id}, nil
+}
+
+func decodePutMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeUpdateMenuRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// PUT replaces the whole resource, missing fields are reset
+	if req.Name == nil {
+		return nil, models.InvalidArgument("name is required")
+	}
+	if req.Sections == nil {
+		req.Sections = &[]models.MenuSection{}
+	}
+	if req.Availability == nil {
+		req.Availability = &[]models.Availability{}
+	}
+	if req.Priority == nil {
+		priority := 0
+		req.Priority = &priority
+	}
+	return req, nil
+}
+
+func decodePatchMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return decodeUpdateMenuRequest(r)
+}
+
+// decodeUpdateMenuRequest decodes the parts shared by PUT and PATCH
+func decodeUpdateMenuRequest(r *http.Request) (updateMenuRequest, error) {
+	var req updateMenuRequest
+	id, err := pathID(r)
+	if err != nil {
+		return req, err
+	}
+	var params models.MenuParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return req, models.InvalidArgument("malformed request body: %v", err)
+	}
+	params.Version, err = etag.ExpectedVersion(r, params.Version)
+	if err != nil {
+		return req, err
+	}
+	return updateMenuRequest{ID: id, MenuParams: params}, nil
+}
+
+func decodeDeleteMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	version, err := etag.ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	return deleteMenuRequest{ID: id, Version: version}, nil
+}
+
+func decodeListMenusRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listMenusRequest{}, nil
+}
+
+func decodeActiveMenuRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req activeMenuRequest
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, models.InvalidArgument("invalid query").WithField("at", "must be an RFC 3339 time")
+		}
+		req.At = at
+	}
+	return req, nil
+}
+
+// pathID extracts the {id} path variable
+func pathID(r *http.Request) (string, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return id, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeCreateMenuRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(createMenuRequest)
+	urlpath.Append(r.URL, "menus")
+	return httptransport.EncodeJSONRequest(ctx, r, req.MenuParams)
+}
+
+func encodeGetMenuRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getMenuRequest)
+	urlpath.Append(r.URL, "menus", req.ID)
+	return nil
+}
+
+func encodeUpdateMenuRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(updateMenuRequest)
+	urlpath.Append(r.URL, "menus", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.MenuParams)
+}
+
+func encodeDeleteMenuRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(deleteMenuRequest)
+	urlpath.Append(r.URL, "menus", req.ID)
+	etag.SetIfMatch(r, req.Version)
+	return nil
+}
+
+func encodeListMenusRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "menus")
+	return nil
+}
+
+func encodeActiveMenuRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(activeMenuRequest)
+	urlpath.Append(r.URL, "menus", "active")
+	if !req.At.IsZero() {
+		q := r.URL.Query()
+		q.Set("at", req.At.Format(time.RFC3339Nano))
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeCreateMenuResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createMenuResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeGetMenuResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getMenuResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeUpdateMenuResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp updateMenuResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeleteMenuResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deleteMenuResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListMenusResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listMenusResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeActiveMenuResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp activeMenuResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
@@ -0,0 +1,129 @@
+package models
+
+import "fmt"
+
+// Allergen is one of the 14 allergens EU law requires food businesses to
+// declare (Regulation 1169/2011, Annex II)
+type Allergen string
+
+const (
+	AllergenGluten      Allergen = "gluten"
+	AllergenCrustaceans Allergen = "crustaceans"
+	AllergenEggs        Allergen = "eggs"
+	AllergenFish        Allergen = "fish"
+	AllergenPeanuts     Allergen = "peanuts"
+	AllergenSoy         Allergen = "soy"
+	AllergenMilk        Allergen = "milk"
+	AllergenTreeNuts    Allergen = "tree_nuts"
+	AllergenCelery      Allergen = "celery"
+	AllergenMustard     Allergen = "mustard"
+	AllergenSesame      Allergen = "sesame"
+	AllergenSulphites   Allergen = "sulphites"
+	AllergenLupin       Allergen = "lupin"
+	AllergenMolluscs    Allergen = "molluscs"
+)
+
+// Allergens lists every allergen in the order of Annex II
+var Allergens = []Allergen{
+	AllergenGluten, AllergenCrustaceans, AllergenEggs, AllergenFish,
+	AllergenPeanuts, AllergenSoy, AllergenMilk, AllergenTreeNuts,
+	AllergenCelery, AllergenMustard, AllergenSesame, AllergenSulphites,
+	AllergenLupin, AllergenMolluscs,
+}
+
+// Valid reports if the allergen is one of Allergens
+func (a Allergen) Valid() bool {
+	for _, known := range Allergens {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Diet is a dietary label a dish can carry
+type Diet string
+
+const (
+	DietVegan      Diet = "vegan"
+	DietVegetarian Diet = "vegetarian"
+	DietGlutenFree Diet = "gluten_free"
+	DietHalal      Diet = "halal"
+	DietKosher     Diet = "kosher"
+)
+
+// Diets lists every dietary label
+var Diets = []Diet{DietVegan, DietVegetarian, DietGlutenFree, DietHalal, DietKosher}
+
+// Valid reports if the diet is one of Diets
+func (d Diet) Valid() bool {
+	for _, known := range Diets {
+		if d == known {
+			return true
+		}
+	}
+	return false
+}
+
+// dietExcludes lists the allergens a dish labeled with a diet cannot contain
+var dietExcludes = map[Diet][]Allergen{
+	DietVegan:      {AllergenEggs, AllergenFish, AllergenMilk, AllergenCrustaceans, AllergenMolluscs},
+	DietVegetarian: {AllergenFish, AllergenCrustaceans, AllergenMolluscs},
+	DietGlutenFree: {AllergenGluten},
+}
+
+// Contains reports if the dish declares the allergen
+func (d Dish) Contains(a Allergen) bool {
+	for _, declared := range d.Allergens {
+		if declared == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Suits reports if the dish is labeled with the diet. Vegan dishes suit
+// vegetarians too.
+func (d Dish) Suits(diet Diet) bool {
+	for _, label := range d.Diets {
+		if label == diet || (label == DietVegan && diet == DietVegetarian) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDietary adds every bad allergen and diet field of a dish to err.
+// Diets must not contradict the declared allergens, e.g. a vegan dish
+// cannot contain milk.
+func validateDietary(err *Error, d Dish) *Error {
+	seen := make(map[Allergen]bool)
+	for i, a := range d.Allergens {
+		field := fmt.Sprintf("allergens[%d]", i)
+		switch {
+		case !a.Valid():
+			err = err.WithField(field, fmt.Sprintf("unknown allergen %q", a))
+		case seen[a]:
+			err = err.WithField(field, "duplicate allergen")
+		}
+		seen[a] = true
+	}
+	seenDiets := make(map[Diet]bool)
+	for i, diet := range d.Diets {
+		field := fmt.Sprintf("diets[%d]", i)
+		switch {
+		case !diet.Valid():
+			err = err.WithField(field, fmt.Sprintf("unknown diet %q", diet))
+		case seenDiets[diet]:
+			err = err.WithField(field, "duplicate diet")
+		default:
+			for _, a := range dietExcludes[diet] {
+				if seen[a] {
+					err = err.WithField(field, fmt.Sprintf("%v dishes cannot contain %v", diet, a))
+				}
+			}
+		}
+		seenDiets[diet] = true
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+)
+
+// CategoryParams are the fields of a category that can be set on creation
+// and updated afterwards
+type CategoryParams struct {
+	Name *string `json:"name,omitempty"`
+
+	// Position orders categories on a menu, lowest first
+	Position *int `json:"position,omitempty"`
+
+	// Version is the version the update expects the category to be at. It is
+	// ignored on creation.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// Category is a section of the menu, such as starters or mains, grouping
+// dishes. Categories are listed by Position, then Name.
+type Category struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+
+	// TenantID is the restaurant owning the category, see Dish.TenantID
+	TenantID string `json:"tenantId,omitempty"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func NewCategory(params CategoryParams) *Category {
+	now := time.Now()
+	c := &Category{
+		ID:      random.SecureString(10),
+		Version: 1,
+		Created: now,
+		Updated: now,
+	}
+	if params.Name != nil {
+		c.Name = *params.Name
+	}
+	if params.Position != nil {
+		c.Position = *params.Position
+	}
+	return c
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (c Category) Validate() error {
+	err := InvalidArgument("invalid category")
+	if c.ID == "" {
+		err = err.WithField("id", "cannot be empty string")
+	}
+	if c.Name == "" {
+		err = err.WithField("name", "cannot be empty string")
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// CheckVersion returns a conflict error unless the category is at the
+// expected version. An expected version of 0 matches any version.
+func (c Category) CheckVersion(expected int64) error {
+	if expected != 0 && expected != c.Version {
+		return Conflict("category has been modified, current version is %d", c.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
+	}
+	return nil
+}
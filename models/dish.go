@@ -1,8 +1,10 @@
 package models
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jeffizhungry/polygon/lib/random"
 )
@@ -12,24 +14,104 @@ import (
 //
 // Inspiration from: https://github.com/stripe/stripe-go
 type DishParams struct {
-	Name  *string  `json:"name,omitempty"`
-	Price *float64 `json:"price,omitempty"`
+	Name  *string `json:"name,omitempty"`
+	Price *Money  `json:"price,omitempty"`
+
+	// Prices replaces the local price list, an empty list clears it
+	Prices *[]Money `json:"prices,omitempty"`
+
+	// CategoryID moves the dish into a category, an empty ID removes it from
+	// its category
+	CategoryID *string `json:"categoryId,omitempty"`
+
+	// Tags replaces the dish's tags, an empty list clears them
+	Tags *[]string `json:"tags,omitempty"`
+
+	// ModifierGroups replaces the dish's modifier groups, an empty list
+	// clears them
+	ModifierGroups *[]ModifierGroup `json:"modifierGroups,omitempty"`
+
+	// Allergens and Diets replace the dish's dietary information, an empty
+	// list clears it
+	Allergens *[]Allergen `json:"allergens,omitempty"`
+	Diets     *[]Diet     `json:"diets,omitempty"`
+
+	// Recipe replaces the dish's recipe, an empty list clears it
+	Recipe *[]RecipeLine `json:"recipe,omitempty"`
+
+	// TaxCategory sets how the dish is taxed, an empty category resets it to
+	// DefaultTaxCategory
+	TaxCategory *TaxCategory `json:"taxCategory,omitempty"`
+
+	// Version is the version the update expects the dish to be at. It is
+	// ignored on creation.
+	Version *int64 `json:"version,omitempty"`
 }
 
 type Dish struct {
-	ID    string
-	Name  string
-	Price float64
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price Money  `json:"price"`
+
+	// TenantID is the restaurant owning the dish, IDs are only unique within
+	// a tenant. It is empty for the default tenant.
+	TenantID string `json:"tenantId,omitempty"`
+
+	// Prices lists explicit local prices in other currencies than Price.
+	// They take precedence over converting Price.
+	Prices []Money `json:"prices,omitempty"`
+
+	// Quote is the price in the currency the dish was requested in. It is
+	// only set on responses, never stored.
+	Quote *PriceQuote `json:"quote,omitempty"`
+
+	// CategoryID is the menu section the dish is listed in, if any
+	CategoryID string `json:"categoryId,omitempty"`
+
+	// Tags are free form labels, normalized by NormalizeTags
+	Tags []string `json:"tags,omitempty"`
+
+	// ModifierGroups are the choices made when ordering the dish, see
+	// CalculatePrice
+	ModifierGroups []ModifierGroup `json:"modifierGroups,omitempty"`
 
-	Created time.Time
-	Updated time.Time
+	// Allergens the dish contains, which must be declared to customers, and
+	// the diets it suits
+	Allergens []Allergen `json:"allergens,omitempty"`
+	Diets     []Diet     `json:"diets,omitempty"`
+
+	// Recipe lists the ingredients that go into one portion
+	Recipe []RecipeLine `json:"recipe,omitempty"`
+
+	// TaxCategory is how the dish is taxed, see TaxedAs
+	TaxCategory TaxCategory `json:"taxCategory,omitempty"`
+
+	// Costing is what the dish costs to make, computed from its recipe. It
+	// is only set on responses, never stored.
+	Costing *DishCosting `json:"costing,omitempty"`
+
+	// Nutrition is one serving of the dish, computed from its recipe when
+	// every ingredient has nutrition data. It is only set on responses.
+	Nutrition *NutritionFacts `json:"nutrition,omitempty"`
+
+	// Availability is whether the dish can be ordered right now, when stock
+	// is tracked. It is only set on responses.
+	Availability *DishAvailability `json:"availability,omitempty"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
 }
 
 func NewDish(params DishParams) *Dish {
+	now := time.Now()
 	d := &Dish{
 		ID:      random.SecureString(10),
-		Created: time.Now(),
-		Updated: time.Now(),
+		Version: 1,
+		Created: now,
+		Updated: now,
 	}
 	if params.Name != nil {
 		d.Name = *params.Name
@@ -37,18 +119,143 @@ func NewDish(params DishParams) *Dish {
 	if params.Price != nil {
 		d.Price = *params.Price
 	}
+	if params.Prices != nil {
+		d.Prices = *params.Prices
+	}
+	if params.CategoryID != nil {
+		d.CategoryID = *params.CategoryID
+	}
+	if params.Tags != nil {
+		d.Tags = NormalizeTags(*params.Tags)
+	}
+	if params.ModifierGroups != nil {
+		d.ModifierGroups = *params.ModifierGroups
+	}
+	if params.Allergens != nil {
+		d.Allergens = *params.Allergens
+	}
+	if params.Diets != nil {
+		d.Diets = *params.Diets
+	}
+	if params.Recipe != nil {
+		d.Recipe = *params.Recipe
+	}
+	if params.TaxCategory != nil {
+		d.TaxCategory = *params.TaxCategory
+	}
 	return d
 }
 
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+// NormalizeTags trims and lower cases tags, and drops duplicates. Tags keep
+// their order otherwise.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized
+}
+
+// HasTag reports if the dish carries the normalized tag
+func (d Dish) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceQuote is a dish's price in a requested currency
+type PriceQuote struct {
+	Price Money `json:"price"`
+
+	// Converted is set when no local price was set in the currency and the
+	// price was converted at current exchange rates instead
+	Converted bool `json:"converted"`
+}
+
+// LocalPrice returns the price explicitly set in currency, if any
+func (d Dish) LocalPrice(currency string) (Money, bool) {
+	if d.Price.Currency == currency {
+		return d.Price, true
+	}
+	for _, p := range d.Prices {
+		if p.Currency == currency {
+			return p, true
+		}
+	}
+	return Money{}, false
+}
+
+// Validate returns an invalid argument error listing every bad field
 func (d Dish) Validate() error {
+	err := InvalidArgument("invalid dish")
 	if d.ID == "" {
-		return errors.New("Id cannot be empty string")
+		err = err.WithField("id", "cannot be empty string")
 	}
 	if d.Name == "" {
-		return errors.New("Name cannot be empty string")
+		err = err.WithField("name", "cannot be empty string")
+	}
+	if msg := d.Price.invalid(); msg != "" {
+		err = err.WithField("price", msg)
+	} else if d.Price.IsZero() {
+		err = err.WithField("price", "cannot be free")
+	}
+	seen := map[string]bool{d.Price.Currency: true}
+	for i, p := range d.Prices {
+		field := fmt.Sprintf("prices[%d]", i)
+		switch msg := p.invalid(); {
+		case msg != "":
+			err = err.WithField(field, msg)
+		case p.IsZero():
+			err = err.WithField(field, "cannot be free")
+		case seen[p.Currency]:
+			err = err.WithField(field, fmt.Sprintf("duplicate %v price", p.Currency))
+		}
+		seen[p.Currency] = true
+	}
+	if len(d.Tags) > maxTags {
+		err = err.WithField("tags", fmt.Sprintf("cannot have more than %d tags", maxTags))
 	}
-	if d.Price == 0 {
-		return errors.New("Price cannot be free")
+	for i, t := range d.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case t == "":
+			err = err.WithField(field, "cannot be empty string")
+		case utf8.RuneCountInString(t) > maxTagLength:
+			err = err.WithField(field, fmt.Sprintf("cannot be longer than %d characters", maxTagLength))
+		}
+	}
+	if d.TaxCategory != "" && !d.TaxCategory.Valid() {
+		err = err.WithField("taxCategory", fmt.Sprintf("unknown tax category %q", d.TaxCategory))
+	}
+	err = validateModifierGroups(err, d.ModifierGroups, d.Price.Currency)
+	err = validateDietary(err, d)
+	err = validateRecipe(err, d.Recipe)
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// CheckVersion returns a conflict error unless the dish is at the expected
+// version. An expected version of 0 matches any version.
+func (d Dish) CheckVersion(expected int64) error {
+	if expected != 0 && expected != d.Version {
+		return Conflict("dish has been modified, current version is %d", d.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
 	}
 	return nil
 }
@@ -1,5 +1,104 @@
 package models
 
-import "errors"
+import (
+	"bytes"
+	"fmt"
+)
 
-var ErrNotFound = errors.New("not found")
+// ErrorKind classifies an error independently of any transport, so that
+// every transport can map it onto its own status codes.
+type ErrorKind string
+
+const (
+	KindNotFound         ErrorKind = "not_found"
+	KindInvalidArgument  ErrorKind = "invalid_argument"
+	KindConflict         ErrorKind = "conflict"
+	KindUnauthenticated  ErrorKind = "unauthenticated"
+	KindPermissionDenied ErrorKind = "permission_denied"
+	KindInternal         ErrorKind = "internal"
+)
+
+// FieldError describes what is wrong with a single input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the structured error returned by our services
+type Error struct {
+	Kind    ErrorKind    `json:"kind"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	var buf bytes.Buffer
+	buf.WriteString(e.Message)
+	for i, f := range e.Fields {
+		if i == 0 {
+			buf.WriteString(": ")
+		} else {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%v %v", f.Field, f.Message)
+	}
+	return buf.String()
+}
+
+// WithField returns a copy of the error with an additional field detail. The
+// receiver is left untouched so that package level errors can be shared.
+func (e *Error) WithField(field, message string) *Error {
+	fields := make([]FieldError, len(e.Fields), len(e.Fields)+1)
+	copy(fields, e.Fields)
+	return &Error{
+		Kind:    e.Kind,
+		Message: e.Message,
+		Fields:  append(fields, FieldError{Field: field, Message: message}),
+	}
+}
+
+// NewError creates an error of the given kind
+func NewError(kind ErrorKind, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return NewError(KindNotFound, format, args...)
+}
+
+func InvalidArgument(format string, args ...interface{}) *Error {
+	return NewError(KindInvalidArgument, format, args...)
+}
+
+func Conflict(format string, args ...interface{}) *Error {
+	return NewError(KindConflict, format, args...)
+}
+
+func Unauthenticated(format string, args ...interface{}) *Error {
+	return NewError(KindUnauthenticated, format, args...)
+}
+
+func PermissionDenied(format string, args ...interface{}) *Error {
+	return NewError(KindPermissionDenied, format, args...)
+}
+
+func Internal(format string, args ...interface{}) *Error {
+	return NewError(KindInternal, format, args...)
+}
+
+// KindOf reports the kind of err. Errors that did not originate from this
+// package are considered internal.
+func KindOf(err error) ErrorKind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+var ErrNotFound = NotFound("not found")
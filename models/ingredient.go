@@ -0,0 +1,242 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+)
+
+// IngredientParams are the fields of an ingredient that can be set on
+// creation and updated afterwards
+type IngredientParams struct {
+	Name *string `json:"name,omitempty"`
+
+	// Cost is the price of one Unit of the ingredient
+	Cost *Money `json:"cost,omitempty"`
+	Unit *Unit  `json:"unit,omitempty"`
+
+	// Nutrition per 100 g, and the weights needed to work out how many
+	// grams recipes measured by volume or count use
+	Nutrition  *Nutrition `json:"nutrition,omitempty"`
+	Density    *float64   `json:"density,omitempty"`
+	ItemWeight *float64   `json:"itemWeight,omitempty"`
+
+	// Version is the version the update expects the ingredient to be at. It
+	// is ignored on creation.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// Ingredient is something dishes are made of, bought at Cost per Unit, e.g.
+// flour at 1.20 USD per kg
+type Ingredient struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Cost Money  `json:"cost"`
+	Unit Unit   `json:"unit"`
+
+	// Nutrition is per 100 g, dishes using an ingredient without it have no
+	// nutrition facts
+	Nutrition *Nutrition `json:"nutrition,omitempty"`
+
+	// Density in g/ml and ItemWeight in g convert recipe quantities
+	// measured by volume or count into grams
+	Density    float64 `json:"density,omitempty"`
+	ItemWeight float64 `json:"itemWeight,omitempty"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func NewIngredient(params IngredientParams) *Ingredient {
+	now := time.Now()
+	i := &Ingredient{
+		ID:      random.SecureString(10),
+		Version: 1,
+		Created: now,
+		Updated: now,
+	}
+	i.Apply(params)
+	return i
+}
+
+// Apply sets every field given in params
+func (i *Ingredient) Apply(params IngredientParams) {
+	if params.Name != nil {
+		i.Name = *params.Name
+	}
+	if params.Cost != nil {
+		i.Cost = *params.Cost
+	}
+	if params.Unit != nil {
+		i.Unit = *params.Unit
+	}
+	if params.Nutrition != nil {
+		i.Nutrition = params.Nutrition
+	}
+	if params.Density != nil {
+		i.Density = *params.Density
+	}
+	if params.ItemWeight != nil {
+		i.ItemWeight = *params.ItemWeight
+	}
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (i Ingredient) Validate() error {
+	err := InvalidArgument("invalid ingredient")
+	if i.ID == "" {
+		err = err.WithField("id", "cannot be empty string")
+	}
+	if i.Name == "" {
+		err = err.WithField("name", "cannot be empty string")
+	}
+	if msg := i.Cost.invalid(); msg != "" {
+		err = err.WithField("cost", msg)
+	}
+	if !i.Unit.Valid() {
+		err = err.WithField("unit", fmt.Sprintf("unknown unit %q", i.Unit))
+	}
+	if i.Nutrition != nil {
+		err = i.Nutrition.validatePer100g(err, "nutrition")
+	}
+	if i.Density < 0 || math.IsInf(i.Density, 0) || math.IsNaN(i.Density) {
+		err = err.WithField("density", "must be a non-negative number")
+	}
+	if i.ItemWeight < 0 || math.IsInf(i.ItemWeight, 0) || math.IsNaN(i.ItemWeight) {
+		err = err.WithField("itemWeight", "must be a non-negative number")
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// CheckVersion returns a conflict error unless the ingredient is at the
+// expected version. An expected version of 0 matches any version.
+func (i Ingredient) CheckVersion(expected int64) error {
+	if expected != 0 && expected != i.Version {
+		return Conflict("ingredient has been modified, current version is %d", i.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
+	}
+	return nil
+}
+
+// CostOf returns the cost of a quantity of the ingredient, rounded half up to
+// the minor unit of its currency
+func (i Ingredient) CostOf(q Quantity) (Money, error) {
+	ratio, err := q.Ratio(i.Unit)
+	if err != nil {
+		return Money{}, err
+	}
+	return i.Cost.MulRat(ratio, Rounding{})
+}
+
+// Grams returns the weight of a quantity of the ingredient. Quantities
+// measured by volume need the ingredient's Density, and those measured by
+// count its ItemWeight.
+func (i Ingredient) Grams(q Quantity) (float64, error) {
+	var per float64
+	switch q.Unit.Dimension() {
+	case DimensionMass:
+		per = 1
+	case DimensionVolume:
+		if i.Density == 0 {
+			return 0, InvalidArgument("%v has no density", i.Name)
+		}
+		per = i.Density
+	case DimensionCount:
+		if i.ItemWeight == 0 {
+			return 0, InvalidArgument("%v has no item weight", i.Name)
+		}
+		per = i.ItemWeight
+	default:
+		return 0, InvalidArgument("unknown unit %q", q.Unit)
+	}
+	base, err := q.Ratio(baseUnits[q.Unit.Dimension()])
+	if err != nil {
+		return 0, err
+	}
+	amount, _ := base.Float64()
+	return amount * per, nil
+}
+
+/**************************************
+ * Recipes
+ *************************************/
+
+// RecipeLine is a quantity of an ingredient that goes into a dish
+type RecipeLine struct {
+	IngredientID string   `json:"ingredientId"`
+	Quantity     Quantity `json:"quantity"`
+}
+
+// validateRecipe adds every bad recipe field of a dish to err. Whether the
+// ingredients exist and the units convert is up to the service.
+func validateRecipe(err *Error, recipe []RecipeLine) *Error {
+	seen := make(map[string]bool)
+	for i, l := range recipe {
+		field := fmt.Sprintf("recipe[%d]", i)
+		switch {
+		case l.IngredientID == "":
+			err = err.WithField(field+".ingredientId", "cannot be empty string")
+		case seen[l.IngredientID]:
+			err = err.WithField(field+".ingredientId", "duplicate ingredient")
+		}
+		seen[l.IngredientID] = true
+		switch q := l.Quantity; {
+		case !q.Unit.Valid():
+			err = err.WithField(field+".quantity.unit", fmt.Sprintf("unknown unit %q", q.Unit))
+		case q.Amount <= 0 || math.IsInf(q.Amount, 0) || math.IsNaN(q.Amount):
+			err = err.WithField(field+".quantity.amount", "must be positive")
+		}
+	}
+	return err
+}
+
+// DishCosting is what a dish costs to make and what it earns, in the
+// currency of its base price. It is computed from the dish's recipe and the
+// current ingredient costs on every response, and never stored.
+type DishCosting struct {
+	Lines []CostLine `json:"lines"`
+	Cost  Money      `json:"cost"`
+
+	// Margin is the gross margin, the base price less the cost, and
+	// MarginPercent the margin as a percentage of the base price
+	Margin        Money   `json:"margin"`
+	MarginPercent float64 `json:"marginPercent"`
+}
+
+// CostLine is the cost of a single recipe line
+type CostLine struct {
+	IngredientID string   `json:"ingredientId"`
+	Name         string   `json:"name"`
+	Quantity     Quantity `json:"quantity"`
+	Cost         Money    `json:"cost"`
+}
+
+// NewDishCosting sums the cost lines of a dish, which must already be in
+// the currency of its base price, and works out the margin
+func NewDishCosting(d Dish, lines []CostLine) (*DishCosting, error) {
+	cost := Money{Currency: d.Price.Currency}
+	for _, l := range lines {
+		var err error
+		if cost, err = cost.Add(l.Cost); err != nil {
+			return nil, err
+		}
+	}
+	margin, err := d.Price.Sub(cost)
+	if err != nil {
+		return nil, err
+	}
+	c := &DishCosting{Lines: lines, Cost: cost, Margin: margin}
+	if d.Price.Amount != 0 {
+		percent := float64(margin.Amount) * 100 / float64(d.Price.Amount)
+		c.MarginPercent = math.Floor(percent*10+0.5) / 10
+	}
+	return c, nil
+}
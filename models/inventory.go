@@ -0,0 +1,154 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// StockKind is what a stock level counts, ingredients or whole dishes such as
+// bought in desserts
+type StockKind string
+
+const (
+	StockIngredient StockKind = "ingredient"
+	StockDish       StockKind = "dish"
+)
+
+// Valid reports if the kind is known
+func (k StockKind) Valid() bool {
+	return k == StockIngredient || k == StockDish
+}
+
+// StockLevel is how much of an ingredient or dish is on hand. Items without
+// a stock level are not tracked and never run out.
+type StockLevel struct {
+	Kind   StockKind `json:"kind"`
+	ItemID string    `json:"itemId"`
+
+	// Quantity is in any unit for ingredients, and counts portions for
+	// dishes
+	Quantity Quantity `json:"quantity"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (l StockLevel) Validate() error {
+	err := InvalidArgument("invalid stock level")
+	if !l.Kind.Valid() {
+		err = err.WithField("kind", fmt.Sprintf("unknown stock kind %q", l.Kind))
+	}
+	if l.ItemID == "" {
+		err = err.WithField("itemId", "cannot be empty string")
+	}
+	switch q := l.Quantity; {
+	case !q.Unit.Valid():
+		err = err.WithField("quantity.unit", fmt.Sprintf("unknown unit %q", q.Unit))
+	case l.Kind == StockDish && q.Unit != UnitEach:
+		err = err.WithField("quantity.unit", "dishes are counted in each")
+	}
+	if a := l.Quantity.Amount; a < 0 || math.IsInf(a, 0) || math.IsNaN(a) {
+		err = err.WithField("quantity.amount", "must be a non-negative number")
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// Covers reports if at least q is on hand. Quantities that do not convert to
+// the unit of the level are never covered.
+func (l StockLevel) Covers(q Quantity) bool {
+	need, err := q.Ratio(l.Quantity.Unit)
+	if err != nil {
+		return false
+	}
+	have := new(big.Rat).SetFloat64(l.Quantity.Amount)
+	return have != nil && have.Cmp(need) >= 0
+}
+
+// StockUsage is a quantity of an ingredient or dish taken out of stock
+type StockUsage struct {
+	Kind     StockKind `json:"kind"`
+	ItemID   string    `json:"itemId"`
+	Quantity Quantity  `json:"quantity"`
+}
+
+// StockUsage returns what making quantity portions of the dish takes out of
+// stock: the portions themselves and every ingredient of its recipe
+func (d Dish) StockUsage(quantity int64) []StockUsage {
+	usage := make([]StockUsage, 0, len(d.Recipe)+1)
+	usage = append(usage, StockUsage{
+		Kind:     StockDish,
+		ItemID:   d.ID,
+		Quantity: Quantity{Amount: float64(quantity), Unit: UnitEach},
+	})
+	for _, l := range d.Recipe {
+		usage = append(usage, StockUsage{
+			Kind:     StockIngredient,
+			ItemID:   l.IngredientID,
+			Quantity: Quantity{Amount: l.Quantity.Amount * float64(quantity), Unit: l.Quantity.Unit},
+		})
+	}
+	return usage
+}
+
+/**************************************
+ * 86
+ *************************************/
+
+// EightySix takes a dish off sale by hand, in kitchen slang "86" it, until
+// the end of the business day unless lifted earlier
+type EightySix struct {
+	DishID string    `json:"dishId"`
+	Reason string    `json:"reason,omitempty"`
+	Until  time.Time `json:"until"`
+
+	Created time.Time `json:"created"`
+}
+
+// Active reports if the dish is still off sale at t
+func (e EightySix) Active(t time.Time) bool {
+	return t.Before(e.Until)
+}
+
+// EndOfBusinessDay returns when the business day running at t ends, the
+// first time after t the clock in its location reads end. Restaurants open
+// past midnight end their day in the early hours, e.g. at 04:00.
+func EndOfBusinessDay(t time.Time, end TimeOfDay) time.Time {
+	y, m, d := t.Date()
+	cutoff := time.Date(y, m, d, int(end)/60, int(end)%60, 0, 0, t.Location())
+	if !cutoff.After(t) {
+		cutoff = time.Date(y, m, d+1, int(end)/60, int(end)%60, 0, 0, t.Location())
+	}
+	return cutoff
+}
+
+// UnavailableReason is why a dish cannot be ordered
+type UnavailableReason string
+
+const (
+	// UnavailableEightySixed dishes were taken off sale by hand
+	UnavailableEightySixed UnavailableReason = "86"
+
+	// UnavailableSoldOut dishes with their own stock have no portions left
+	UnavailableSoldOut UnavailableReason = "sold_out"
+
+	// UnavailableOutOfStock dishes lack an ingredient for another portion
+	UnavailableOutOfStock UnavailableReason = "out_of_stock"
+)
+
+// DishAvailability is whether a dish can be ordered right now. It is
+// computed from stock levels and 86s on every response, and never stored.
+type DishAvailability struct {
+	Available bool              `json:"available"`
+	Reason    UnavailableReason `json:"reason,omitempty"`
+
+	// OutOfStock lists the ingredients that ran out
+	OutOfStock []string `json:"outOfStock,omitempty"`
+
+	// Until is when a manual 86 expires
+	Until *time.Time `json:"until,omitempty"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndOfBusinessDay(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	testcases := []struct {
+		at       time.Time
+		end      TimeOfDay
+		expected time.Time
+	}{
+		// Late service belongs to the day it started on
+		{time.Date(2024, 3, 8, 21, 0, 0, 0, nyc), NewTimeOfDay(4, 0), time.Date(2024, 3, 9, 4, 0, 0, 0, nyc)},
+		{time.Date(2024, 3, 9, 1, 30, 0, 0, nyc), NewTimeOfDay(4, 0), time.Date(2024, 3, 9, 4, 0, 0, 0, nyc)},
+		{time.Date(2024, 3, 9, 4, 0, 0, 0, nyc), NewTimeOfDay(4, 0), time.Date(2024, 3, 10, 4, 0, 0, 0, nyc)},
+		{time.Date(2024, 3, 9, 23, 59, 0, 0, nyc), 0, time.Date(2024, 3, 10, 0, 0, 0, 0, nyc)},
+	}
+	for _, tc := range testcases {
+		assert.True(t, tc.expected.Equal(EndOfBusinessDay(tc.at, tc.end)), tc.at.String())
+	}
+
+	// Days running into a clock change are shorter
+	until := EndOfBusinessDay(time.Date(2024, 3, 9, 12, 0, 0, 0, nyc), NewTimeOfDay(4, 0))
+	assert.Equal(t, 15*time.Hour, until.Sub(time.Date(2024, 3, 9, 12, 0, 0, 0, nyc)))
+
+	e := EightySix{Until: until}
+	assert.True(t, e.Active(until.Add(-time.Second)))
+	assert.False(t, e.Active(until))
+}
+
+func TestStockLevelCovers(t *testing.T) {
+	level := StockLevel{Kind: StockIngredient, ItemID: "flour", Quantity: Quantity{0.25, UnitKilogram}}
+	require.NoError(t, level.Validate())
+	assert.True(t, level.Covers(Quantity{250, UnitGram}))
+	assert.False(t, level.Covers(Quantity{251, UnitGram}))
+	assert.False(t, level.Covers(Quantity{1, UnitCup}))
+
+	level = StockLevel{Kind: "crate", Quantity: Quantity{-1, UnitEach}}
+	err := level.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "kind", Message: `unknown stock kind "crate"`},
+		{Field: "itemId", Message: "cannot be empty string"},
+		{Field: "quantity.amount", Message: "must be a non-negative number"},
+	}, err.(*Error).Fields)
+}
+
+func TestDishStockUsage(t *testing.T) {
+	d := Dish{ID: "pizza", Recipe: []RecipeLine{
+		{IngredientID: "flour", Quantity: Quantity{250, UnitGram}},
+		{IngredientID: "mozzarella", Quantity: Quantity{4, UnitOunce}},
+	}}
+	assert.Equal(t, []StockUsage{
+		{Kind: StockDish, ItemID: "pizza", Quantity: Quantity{3, UnitEach}},
+		{Kind: StockIngredient, ItemID: "flour", Quantity: Quantity{750, UnitGram}},
+		{Kind: StockIngredient, ItemID: "mozzarella", Quantity: Quantity{12, UnitOunce}},
+	}, d.StockUsage(3))
+}
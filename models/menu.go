@@ -0,0 +1,271 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+)
+
+// MenuParams are the fields of a menu that can be set on creation and
+// updated afterwards
+type MenuParams struct {
+	Name         *string         `json:"name,omitempty"`
+	Sections     *[]MenuSection  `json:"sections,omitempty"`
+	Availability *[]Availability `json:"availability,omitempty"`
+	Priority     *int            `json:"priority,omitempty"`
+
+	// Version is the version the update expects the menu to be at. It is
+	// ignored on creation.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// Menu is an ordered selection of dishes, offered during its availability
+// windows. When several menus are available at once, the one with the
+// highest Priority is active.
+type Menu struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Sections []MenuSection `json:"sections"`
+
+	// Availability lists when the menu is offered, it is always offered if
+	// there are no windows
+	Availability []Availability `json:"availability,omitempty"`
+	Priority     int            `json:"priority"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// MenuSection is a titled, ordered list of dishes on a menu
+type MenuSection struct {
+	Name    string   `json:"name"`
+	DishIDs []string `json:"dishIds"`
+}
+
+func NewMenu(params MenuParams) *Menu {
+	now := time.Now()
+	m := &Menu{
+		ID:       random.SecureString(10),
+		Sections: []MenuSection{},
+		Version:  1,
+		Created:  now,
+		Updated:  now,
+	}
+	m.Apply(params)
+	return m
+}
+
+// Apply sets every field given in params
+func (m *Menu) Apply(params MenuParams) {
+	if params.Name != nil {
+		m.Name = *params.Name
+	}
+	if params.Sections != nil {
+		m.Sections = *params.Sections
+	}
+	if params.Availability != nil {
+		m.Availability = *params.Availability
+	}
+	if params.Priority != nil {
+		m.Priority = *params.Priority
+	}
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (m Menu) Validate() error {
+	err := InvalidArgument("invalid menu")
+	if m.ID == "" {
+		err = err.WithField("id", "cannot be empty string")
+	}
+	if m.Name == "" {
+		err = err.WithField("name", "cannot be empty string")
+	}
+	for i, s := range m.Sections {
+		field := fmt.Sprintf("sections[%d]", i)
+		if s.Name == "" {
+			err = err.WithField(field+".name", "cannot be empty string")
+		}
+		seen := make(map[string]bool)
+		for j, id := range s.DishIDs {
+			switch {
+			case id == "":
+				err = err.WithField(fmt.Sprintf("%v.dishIds[%d]", field, j), "cannot be empty string")
+			case seen[id]:
+				err = err.WithField(fmt.Sprintf("%v.dishIds[%d]", field, j), "duplicate dish")
+			}
+			seen[id] = true
+		}
+	}
+	err = validateAvailability(err, "availability", m.Availability)
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// CheckVersion returns a conflict error unless the menu is at the expected
+// version. An expected version of 0 matches any version.
+func (m Menu) CheckVersion(expected int64) error {
+	if expected != 0 && expected != m.Version {
+		return Conflict("menu has been modified, current version is %d", m.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
+	}
+	return nil
+}
+
+// AvailableAt reports if the menu is offered at t. The caller converts t into
+// the restaurant's time zone first, windows are in local wall clock time.
+func (m Menu) AvailableAt(t time.Time) bool {
+	if len(m.Availability) == 0 {
+		return true
+	}
+	for _, a := range m.Availability {
+		if a.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+/**************************************
+ * Availability
+ *************************************/
+
+// Availability is a daily time window on some days of the week, e.g. 07:00
+// to 11:00 on weekdays. Start is inclusive and End exclusive. A window whose
+// End is not after its Start runs past midnight into the following day, and
+// one whose Start and End are equal lasts all day.
+type Availability struct {
+	Days  []Weekday `json:"days"`
+	Start TimeOfDay `json:"start"`
+	End   TimeOfDay `json:"end"`
+}
+
+// Contains reports if the wall clock time of t falls in the window
+func (a Availability) Contains(t time.Time) bool {
+	now := TimeOfDay(t.Hour()*60 + t.Minute())
+	today := Weekday(t.Weekday())
+	yesterday := Weekday((t.Weekday() + 6) % 7)
+
+	switch {
+	case a.Start == a.End:
+		return a.on(today)
+	case a.Start < a.End:
+		return a.on(today) && now >= a.Start && now < a.End
+	default:
+		// Past midnight, the window started the day before
+		return (a.on(today) && now >= a.Start) || (a.on(yesterday) && now < a.End)
+	}
+}
+
+// validateAvailability adds a field error to err for every bad window, field
+// names the list of windows
+func validateAvailability(err *Error, field string, windows []Availability) *Error {
+	for i, a := range windows {
+		f := fmt.Sprintf("%v[%d]", field, i)
+		if len(a.Days) == 0 {
+			err = err.WithField(f+".days", "cannot be empty")
+		}
+		if !a.Start.valid() {
+			err = err.WithField(f+".start", "must be between 00:00 and 23:59")
+		}
+		if !a.End.valid() {
+			err = err.WithField(f+".end", "must be between 00:00 and 23:59")
+		}
+	}
+	return err
+}
+
+func (a Availability) on(day Weekday) bool {
+	for _, d := range a.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Weekday is a time.Weekday encoded in JSON by its three letter name, e.g.
+// "mon"
+type Weekday time.Weekday
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Weekdays and Weekend are shorthands for common availability days
+var (
+	Weekdays = []Weekday{Weekday(time.Monday), Weekday(time.Tuesday), Weekday(time.Wednesday), Weekday(time.Thursday), Weekday(time.Friday)}
+	Weekend  = []Weekday{Weekday(time.Saturday), Weekday(time.Sunday)}
+)
+
+func (d Weekday) String() string {
+	if d < 0 || int(d) >= len(weekdayNames) {
+		return fmt.Sprintf("Weekday(%d)", int(d))
+	}
+	return weekdayNames[d]
+}
+
+func (d Weekday) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Weekday) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for i, name := range weekdayNames {
+		if strings.EqualFold(s, name) {
+			*d = Weekday(i)
+			return nil
+		}
+	}
+	return InvalidArgument("unknown weekday %q, must be one of %v", s, strings.Join(weekdayNames, ", "))
+}
+
+// TimeOfDay counts minutes since midnight, encoded in JSON as "HH:MM"
+type TimeOfDay int
+
+// NewTimeOfDay returns the time of day at hour:minute
+func NewTimeOfDay(hour, minute int) TimeOfDay {
+	return TimeOfDay(hour*60 + minute)
+}
+
+func (t TimeOfDay) valid() bool {
+	return t >= 0 && t < 24*60
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseTimeOfDay parses a time of day formatted as "HH:MM"
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	parsed, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, InvalidArgument("invalid time of day %q, must be HH:MM", s)
+	}
+	return NewTimeOfDay(parsed.Hour(), parsed.Minute()), nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailabilityContains(t *testing.T) {
+	breakfast := Availability{Days: Weekdays, Start: NewTimeOfDay(7, 0), End: NewTimeOfDay(11, 0)}
+	lateNight := Availability{Days: []Weekday{Weekday(time.Friday)}, Start: NewTimeOfDay(22, 0), End: NewTimeOfDay(2, 0)}
+	weekend := Availability{Days: Weekend}
+
+	// 2017-06-02 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2017, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	testcases := []struct {
+		name     string
+		window   Availability
+		at       time.Time
+		expected bool
+	}{
+		{"breakfast start", breakfast, at(2, 7, 0), true},
+		{"breakfast end", breakfast, at(2, 11, 0), false},
+		{"breakfast before", breakfast, at(2, 6, 59), false},
+		{"breakfast on saturday", breakfast, at(3, 8, 0), false},
+		{"late night friday", lateNight, at(2, 23, 30), true},
+		{"late night past midnight", lateNight, at(3, 1, 59), true},
+		{"late night ended", lateNight, at(3, 2, 0), false},
+		{"late night thursday", lateNight, at(1, 23, 30), false},
+		{"late night early friday", lateNight, at(2, 1, 0), false},
+		{"weekend all day", weekend, at(4, 0, 0), true},
+		{"weekend on friday", weekend, at(2, 23, 59), false},
+	}
+	for _, tc := range testcases {
+		assert.Equal(t, tc.expected, tc.window.Contains(tc.at), tc.name)
+	}
+}
+
+func TestAvailabilityJSON(t *testing.T) {
+	var a Availability
+	require.NoError(t, json.Unmarshal([]byte(`{"days":["Mon","tue"],"start":"07:30","end":"23:05"}`), &a))
+	assert.Equal(t, []Weekday{Weekday(time.Monday), Weekday(time.Tuesday)}, a.Days)
+	assert.Equal(t, NewTimeOfDay(7, 30), a.Start)
+	assert.Equal(t, NewTimeOfDay(23, 5), a.End)
+
+	b, err := json.Marshal(a)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"days":["mon","tue"],"start":"07:30","end":"23:05"}`, string(b))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"days":["monday"]}`), &a))
+	assert.Error(t, json.Unmarshal([]byte(`{"start":"24:00"}`), &a))
+}
+
+func TestMenuValidate(t *testing.T) {
+	m := NewMenu(MenuParams{})
+	m.Sections = []MenuSection{{DishIDs: []string{"a", "a", ""}}}
+	m.Availability = []Availability{{Start: -1}}
+	err := m.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "cannot be empty string"},
+		{Field: "sections[0].name", Message: "cannot be empty string"},
+		{Field: "sections[0].dishIds[1]", Message: "duplicate dish"},
+		{Field: "sections[0].dishIds[2]", Message: "cannot be empty string"},
+		{Field: "availability[0].days", Message: "cannot be empty"},
+		{Field: "availability[0].start", Message: "must be between 00:00 and 23:59"},
+	}, err.(*Error).Fields)
+}
@@ -0,0 +1,243 @@
+package models
+
+import "fmt"
+
+// ModifierGroup is a choice customers make when ordering a dish, e.g. its
+// size, a side, or up to three toppings
+type ModifierGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Min and Max bound how many modifiers can be chosen from the group, a
+	// Max of 0 allows any number
+	Min int `json:"min"`
+	Max int `json:"max"`
+
+	// Required groups need at least one modifier chosen, even if Min is 0
+	Required bool `json:"required"`
+
+	Modifiers []Modifier `json:"modifiers"`
+}
+
+// Modifier is an option within a modifier group
+type Modifier struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// PriceDelta is added to the dish price when the modifier is chosen. It
+	// is in the currency of the dish's base price, and is negative for
+	// cheaper options.
+	PriceDelta Money `json:"priceDelta"`
+}
+
+// minChosen is the least number of modifiers that must be chosen
+func (g ModifierGroup) minChosen() int {
+	if g.Required && g.Min < 1 {
+		return 1
+	}
+	return g.Min
+}
+
+func (g ModifierGroup) modifier(id string) (Modifier, bool) {
+	for _, m := range g.Modifiers {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Modifier{}, false
+}
+
+// ModifierGroup returns the dish's modifier group with the ID, if any
+func (d Dish) ModifierGroup(id string) (ModifierGroup, bool) {
+	for _, g := range d.ModifierGroups {
+		if g.ID == id {
+			return g, true
+		}
+	}
+	return ModifierGroup{}, false
+}
+
+// validateModifierGroups adds every bad modifier group field of a dish priced
+// in currency to err
+func validateModifierGroups(err *Error, groups []ModifierGroup, currency string) *Error {
+	seenGroups := make(map[string]bool)
+	for i, g := range groups {
+		field := fmt.Sprintf("modifierGroups[%d]", i)
+		switch {
+		case g.ID == "":
+			err = err.WithField(field+".id", "cannot be empty string")
+		case seenGroups[g.ID]:
+			err = err.WithField(field+".id", "duplicate modifier group")
+		}
+		seenGroups[g.ID] = true
+		if g.Name == "" {
+			err = err.WithField(field+".name", "cannot be empty string")
+		}
+		switch {
+		case g.Min < 0:
+			err = err.WithField(field+".min", "cannot be negative")
+		case g.Min > len(g.Modifiers):
+			err = err.WithField(field+".min", "cannot be more than the number of modifiers")
+		}
+		switch {
+		case g.Max < 0:
+			err = err.WithField(field+".max", "cannot be negative")
+		case g.Max != 0 && g.Max < g.minChosen():
+			err = err.WithField(field+".max", "cannot be less than min")
+		}
+		if len(g.Modifiers) == 0 {
+			err = err.WithField(field+".modifiers", "cannot be empty")
+		}
+
+		seen := make(map[string]bool)
+		for j, m := range g.Modifiers {
+			field := fmt.Sprintf("%v.modifiers[%d]", field, j)
+			switch {
+			case m.ID == "":
+				err = err.WithField(field+".id", "cannot be empty string")
+			case seen[m.ID]:
+				err = err.WithField(field+".id", "duplicate modifier")
+			}
+			seen[m.ID] = true
+			if m.Name == "" {
+				err = err.WithField(field+".name", "cannot be empty string")
+			}
+
+			// Deltas may be negative, so validate their magnitude
+			magnitude := m.PriceDelta
+			if magnitude.Amount < 0 {
+				magnitude = magnitude.Neg()
+			}
+			switch msg := magnitude.invalid(); {
+			case msg != "":
+				err = err.WithField(field+".priceDelta", msg)
+			case m.PriceDelta.Currency != currency:
+				err = err.WithField(field+".priceDelta", "must be in "+currency)
+			}
+		}
+	}
+	return err
+}
+
+/**************************************
+ * Price calculation
+ *************************************/
+
+// ModifierChoice picks a modifier from one of the dish's groups
+type ModifierChoice struct {
+	GroupID    string `json:"groupId"`
+	ModifierID string `json:"modifierId"`
+}
+
+// MaxQuantity bounds the portions of a single configuration, keeping
+// pricing and discounts cheap to work out
+const MaxQuantity = 500
+
+// Configuration is a dish as a customer orders it
+type Configuration struct {
+
+	// Quantity is the number of portions, 0 means 1, up to MaxQuantity
+	Quantity int64 `json:"quantity,omitempty"`
+
+	Modifiers []ModifierChoice `json:"modifiers,omitempty"`
+}
+
+// PriceLine is a single line of a price breakdown, either the dish itself or
+// a chosen modifier
+type PriceLine struct {
+	Description string `json:"description"`
+	GroupID     string `json:"groupId,omitempty"`
+	ModifierID  string `json:"modifierId,omitempty"`
+	Amount      Money  `json:"amount"`
+}
+
+// PriceBreakdown itemizes the price of a configured dish. UnitPrice sums the
+// lines, and Total is UnitPrice times Quantity.
+type PriceBreakdown struct {
+	Lines     []PriceLine `json:"lines"`
+	UnitPrice Money       `json:"unitPrice"`
+	Quantity  int64       `json:"quantity"`
+	Total     Money       `json:"total"`
+}
+
+// CalculatePrice validates the configuration against the dish's modifier
+// groups and prices it in the currency of the dish's base price. It returns
+// an invalid argument error listing every problem with the configuration.
+func (d Dish) CalculatePrice(c Configuration) (*PriceBreakdown, error) {
+	bad := InvalidArgument("invalid configuration")
+	quantity := c.Quantity
+	switch {
+	case quantity == 0:
+		quantity = 1
+	case quantity < 0:
+		bad = bad.WithField("quantity", "cannot be negative")
+	case quantity > MaxQuantity:
+		bad = bad.WithField("quantity", fmt.Sprintf("cannot be more than %d", MaxQuantity))
+	}
+
+	// Price every choice
+	lines := []PriceLine{{Description: d.Name, Amount: d.Price}}
+	chosen := make(map[string]int)
+	seen := make(map[ModifierChoice]bool)
+	for i, choice := range c.Modifiers {
+		field := fmt.Sprintf("modifiers[%d]", i)
+		group, ok := d.ModifierGroup(choice.GroupID)
+		if !ok {
+			bad = bad.WithField(field+".groupId", "unknown modifier group")
+			continue
+		}
+		modifier, ok := group.modifier(choice.ModifierID)
+		if !ok {
+			bad = bad.WithField(field+".modifierId", "unknown modifier")
+			continue
+		}
+		if seen[choice] {
+			bad = bad.WithField(field, "chosen more than once")
+			continue
+		}
+		seen[choice] = true
+		chosen[group.ID]++
+		lines = append(lines, PriceLine{
+			Description: group.Name + ": " + modifier.Name,
+			GroupID:     group.ID,
+			ModifierID:  modifier.ID,
+			Amount:      modifier.PriceDelta,
+		})
+	}
+
+	// Check selection bounds
+	for _, g := range d.ModifierGroups {
+		n := chosen[g.ID]
+		switch {
+		case n < g.minChosen():
+			bad = bad.WithField("modifiers", fmt.Sprintf("choose at least %d from %v", g.minChosen(), g.Name))
+		case g.Max != 0 && n > g.Max:
+			bad = bad.WithField("modifiers", fmt.Sprintf("choose at most %d from %v", g.Max, g.Name))
+		}
+	}
+	if len(bad.Fields) > 0 {
+		return nil, bad
+	}
+
+	// Total
+	unit := Money{Currency: d.Price.Currency}
+	for _, l := range lines {
+		var err error
+		if unit, err = unit.Add(l.Amount); err != nil {
+			return nil, err
+		}
+	}
+	if unit.Amount < 0 {
+		return nil, bad.WithField("modifiers", "cannot make the price negative")
+	}
+	total, err := unit.Mul(quantity)
+	if err != nil {
+		return nil, err
+	}
+	return &PriceBreakdown{
+		Lines:     lines,
+		UnitPrice: unit,
+		Quantity:  quantity,
+		Total:     total,
+	}, nil
+}
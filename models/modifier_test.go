@@ -0,0 +1,134 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeBurger() Dish {
+	d := NewDish(DishParams{})
+	d.Name = "Burger"
+	d.Price = MustParseMoney("10.00", "USD")
+	d.ModifierGroups = []ModifierGroup{
+		{
+			ID: "size", Name: "Size", Max: 1, Required: true,
+			Modifiers: []Modifier{
+				{ID: "small", Name: "Small", PriceDelta: MustParseMoney("-2.00", "USD")},
+				{ID: "regular", Name: "Regular", PriceDelta: MustParseMoney("0", "USD")},
+				{ID: "large", Name: "Large", PriceDelta: MustParseMoney("2.50", "USD")},
+			},
+		},
+		{
+			ID: "extras", Name: "Extras", Max: 2,
+			Modifiers: []Modifier{
+				{ID: "bacon", Name: "Bacon", PriceDelta: MustParseMoney("1.50", "USD")},
+				{ID: "cheese", Name: "Cheese", PriceDelta: MustParseMoney("0.75", "USD")},
+				{ID: "egg", Name: "Egg", PriceDelta: MustParseMoney("1.00", "USD")},
+			},
+		},
+	}
+	return *d
+}
+
+func TestDishCalculatePrice(t *testing.T) {
+	burger := makeBurger()
+	require.NoError(t, burger.Validate())
+
+	breakdown, err := burger.CalculatePrice(Configuration{
+		Quantity: 2,
+		Modifiers: []ModifierChoice{
+			{GroupID: "size", ModifierID: "large"},
+			{GroupID: "extras", ModifierID: "bacon"},
+			{GroupID: "extras", ModifierID: "cheese"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []PriceLine{
+		{Description: "Burger", Amount: MustParseMoney("10.00", "USD")},
+		{Description: "Size: Large", GroupID: "size", ModifierID: "large", Amount: MustParseMoney("2.50", "USD")},
+		{Description: "Extras: Bacon", GroupID: "extras", ModifierID: "bacon", Amount: MustParseMoney("1.50", "USD")},
+		{Description: "Extras: Cheese", GroupID: "extras", ModifierID: "cheese", Amount: MustParseMoney("0.75", "USD")},
+	}, breakdown.Lines)
+	assert.Equal(t, "14.75 USD", breakdown.UnitPrice.String())
+	assert.Equal(t, int64(2), breakdown.Quantity)
+	assert.Equal(t, "29.50 USD", breakdown.Total.String())
+
+	// Negative deltas
+	breakdown, err = burger.CalculatePrice(Configuration{
+		Modifiers: []ModifierChoice{{GroupID: "size", ModifierID: "small"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "8.00 USD", breakdown.Total.String())
+}
+
+func TestDishCalculatePriceInvalid(t *testing.T) {
+	burger := makeBurger()
+	testcases := map[string]struct {
+		config   Configuration
+		expected []FieldError
+	}{
+		"required group missing": {
+			Configuration{},
+			[]FieldError{{Field: "modifiers", Message: "choose at least 1 from Size"}},
+		},
+		"too many": {
+			Configuration{Modifiers: []ModifierChoice{
+				{GroupID: "size", ModifierID: "small"},
+				{GroupID: "size", ModifierID: "large"},
+			}},
+			[]FieldError{{Field: "modifiers", Message: "choose at most 1 from Size"}},
+		},
+		"unknown": {
+			Configuration{Quantity: -1, Modifiers: []ModifierChoice{
+				{GroupID: "size", ModifierID: "huge"},
+				{GroupID: "sauce", ModifierID: "ketchup"},
+				{GroupID: "size", ModifierID: "regular"},
+			}},
+			[]FieldError{
+				{Field: "quantity", Message: "cannot be negative"},
+				{Field: "modifiers[0].modifierId", Message: "unknown modifier"},
+				{Field: "modifiers[1].groupId", Message: "unknown modifier group"},
+			},
+		},
+		"too many portions": {
+			Configuration{Quantity: 100000000, Modifiers: []ModifierChoice{{GroupID: "size", ModifierID: "regular"}}},
+			[]FieldError{{Field: "quantity", Message: "cannot be more than 500"}},
+		},
+		"twice": {
+			Configuration{Modifiers: []ModifierChoice{
+				{GroupID: "size", ModifierID: "regular"},
+				{GroupID: "extras", ModifierID: "egg"},
+				{GroupID: "extras", ModifierID: "egg"},
+			}},
+			[]FieldError{{Field: "modifiers[2]", Message: "chosen more than once"}},
+		},
+	}
+	for name, tc := range testcases {
+		_, err := burger.CalculatePrice(tc.config)
+		require.Error(t, err, name)
+		assert.Equal(t, tc.expected, err.(*Error).Fields, name)
+	}
+}
+
+func TestDishValidateModifierGroups(t *testing.T) {
+	d := makeBurger()
+	d.ModifierGroups[0].Min = 4
+	d.ModifierGroups[1].ID = "size"
+	d.ModifierGroups[1].Modifiers[0].PriceDelta = MustParseMoney("1.50", "EUR")
+	d.ModifierGroups[1].Modifiers[2].ID = "cheese"
+	d.ModifierGroups = append(d.ModifierGroups, ModifierGroup{ID: "sauce", Name: "Sauce", Max: -1})
+
+	err := d.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "modifierGroups[0].min", Message: "cannot be more than the number of modifiers"},
+		{Field: "modifierGroups[0].max", Message: "cannot be less than min"},
+		{Field: "modifierGroups[1].id", Message: "duplicate modifier group"},
+		{Field: "modifierGroups[1].modifiers[0].priceDelta", Message: "must be in USD"},
+		{Field: "modifierGroups[1].modifiers[2].id", Message: "duplicate modifier"},
+		{Field: "modifierGroups[2].max", Message: "cannot be negative"},
+		{Field: "modifierGroups[2].modifiers", Message: "cannot be empty"},
+	}, err.(*Error).Fields)
+}
@@ -0,0 +1,387 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxMoneyAmount is the largest amount, in minor units, a valid Money may
+// hold. It leaves plenty of headroom for totals to be summed in an int64.
+const MaxMoneyAmount int64 = 1e12
+
+// DefaultCurrency is assumed for legacy prices that were stored as a bare
+// float, before prices carried a currency.
+var DefaultCurrency = "USD"
+
+// currencyExponents holds the number of minor unit digits of each supported
+// ISO 4217 currency.
+var currencyExponents = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2,
+	"CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2, "RON": 2, "RUB": 2, "SAR": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2,
+	"USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// CurrencyExponent returns the number of minor unit digits of an ISO 4217
+// currency code, e.g. 2 for USD and 0 for JPY.
+func CurrencyExponent(currency string) (int, bool) {
+	exp, ok := currencyExponents[currency]
+	return exp, ok
+}
+
+// Money is an exact amount in a currency. Amount counts minor units, e.g.
+// cents for USD, so sums never drift like floats do.
+//
+// Money is encoded in JSON as {"amount": "12.50", "currency": "USD"}. The
+// amount is a string, so clients never round trip it through a float.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns amount minor units of currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "12.50" or "-3" in currency.
+// The amount may have at most as many decimal places as the currency has.
+func ParseMoney(amount, currency string) (Money, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, InvalidArgument("unknown currency %q", currency)
+	}
+	invalid := InvalidArgument("invalid %v amount %q", currency, amount)
+
+	s := amount
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+		if frac == "" {
+			return Money{}, invalid
+		}
+	}
+	if whole == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, invalid
+	}
+	if len(frac) > exp {
+		return Money{}, InvalidArgument("%v amount %q has more than %d decimal places", currency, amount, exp)
+	}
+
+	// Anything longer cannot be a valid amount and might overflow
+	digits := strings.TrimLeft(whole, "0") + frac + strings.Repeat("0", exp-len(frac))
+	if len(digits) > 18 {
+		return Money{}, InvalidArgument("%v amount %q is out of range", currency, amount)
+	}
+	var minor int64
+	if digits != "" {
+		v, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return Money{}, invalid
+		}
+		minor = v
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on error. It is meant for
+// constants and tests.
+func MustParseMoney(amount, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MoneyFromFloat converts a legacy float price, rounding to the nearest minor
+// unit of currency.
+func MoneyFromFloat(v float64, currency string) (Money, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, InvalidArgument("unknown currency %q", currency)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Money{}, InvalidArgument("invalid amount %v", v)
+	}
+	return ParseMoney(strconv.FormatFloat(v, 'f', exp, 64), currency)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// exponent returns the currency's exponent, falling back to 2 for unknown
+// currencies so that invalid values can still be printed.
+func (m Money) exponent() int {
+	if exp, ok := currencyExponents[m.Currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Decimal formats the amount with the currency's decimal places, e.g. "12.50"
+func (m Money) Decimal() string {
+	exp := m.exponent()
+	sign, minor := "", uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, minor = "-", uint64(-m.Amount)
+	}
+	s := strconv.FormatUint(minor, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+// String formats the money as e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports if the amount is zero, in any currency
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// invalid describes what is wrong with the money, if anything
+func (m Money) invalid() string {
+	switch {
+	case m.Currency == "":
+		return "currency cannot be empty"
+	case !validCurrency(m.Currency):
+		return fmt.Sprintf("unknown currency %q", m.Currency)
+	case m.Amount < 0:
+		return "cannot be negative"
+	case m.Amount > MaxMoneyAmount:
+		return "cannot be more than " + Money{Amount: MaxMoneyAmount, Currency: m.Currency}.Decimal()
+	}
+	return ""
+}
+
+func validCurrency(currency string) bool {
+	_, ok := CurrencyExponent(currency)
+	return ok
+}
+
+// Validate checks that the currency is supported and the amount is neither
+// negative nor larger than MaxMoneyAmount.
+func (m Money) Validate() error {
+	if msg := m.invalid(); msg != "" {
+		return InvalidArgument("invalid money: %v", msg)
+	}
+	return nil
+}
+
+/**************************************
+ * Arithmetic
+ *************************************/
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return InvalidArgument("currency mismatch: %v and %v", m.Currency, o.Currency)
+	}
+	return nil
+}
+
+func errOverflow(op string) error {
+	return InvalidArgument("money %v overflows", op)
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, errOverflow("addition")
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, errOverflow("subtraction")
+	}
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m * n, e.g. the price of n portions
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount != 0 && n != 0 {
+		p := m.Amount * n
+		if p/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+			return Money{}, errOverflow("multiplication")
+		}
+		return Money{Amount: p, Currency: m.Currency}, nil
+	}
+	return Money{Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 as m is less than, equal to or greater than o.
+// Both must be in the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Less orders money by currency, then amount. It is a total order meant for
+// sorting, use Cmp to compare amounts.
+func (m Money) Less(o Money) bool {
+	if m.Currency != o.Currency {
+		return m.Currency < o.Currency
+	}
+	return m.Amount < o.Amount
+}
+
+// Allocate splits m in proportion to ratios without losing a minor unit. The
+// remainder left by rounding down is handed out one minor unit at a time,
+// starting with the first share, so e.g. 10.00 split 1:1:1 gives 3.34, 3.33
+// and 3.33.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, InvalidArgument("allocation ratios cannot be negative")
+		}
+		if total+r < total {
+			return nil, errOverflow("allocation")
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, InvalidArgument("allocation ratios cannot all be zero")
+	}
+
+	// Allocate the absolute amount, so the remainder is always positive
+	amount, sign := m.Amount, int64(1)
+	if amount < 0 {
+		if amount == math.MinInt64 {
+			return nil, errOverflow("allocation")
+		}
+		amount, sign = -amount, -1
+	}
+
+	shares := make([]Money, len(ratios))
+	remainder := amount
+	for i, r := range ratios {
+		if r != 0 && amount > math.MaxInt64/r {
+			return nil, errOverflow("allocation")
+		}
+		share := amount * r / total
+		shares[i] = Money{Amount: share, Currency: m.Currency}
+		remainder -= share
+	}
+	for i := 0; remainder > 0; i = (i + 1) % len(shares) {
+		if ratios[i] == 0 {
+			continue
+		}
+		shares[i].Amount++
+		remainder--
+	}
+	for i := range shares {
+		shares[i].Amount *= sign
+	}
+	return shares, nil
+}
+
+// Split divides m into n shares as equal as possible
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, InvalidArgument("cannot split into %d shares", n)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+/**************************************
+ * JSON
+ *************************************/
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes the {"amount": "12.50", "currency": "USD"} form. As a
+// migration path, a bare number is decoded as a legacy float price in the
+// DefaultCurrency, so dishes stored or sent before prices carried a currency
+// keep working.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	// Legacy float price
+	if len(b) > 0 && b[0] != '{' {
+		var v float64
+		if err := json.Unmarshal(b, &v); err != nil {
+			return InvalidArgument("money must be an object with an amount and currency")
+		}
+		legacy, err := MoneyFromFloat(v, DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = legacy
+		return nil
+	}
+
+	var raw moneyJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency
+	}
+	parsed, err := ParseMoney(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
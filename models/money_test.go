@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	testcases := []struct {
+		amount   string
+		currency string
+		expected int64
+		err      bool
+	}{
+		{"12.50", "USD", 1250, false},
+		{"12.5", "USD", 1250, false},
+		{"12", "USD", 1200, false},
+		{"0.07", "USD", 7, false},
+		{"-3", "USD", -300, false},
+		{"1200", "JPY", 1200, false},
+		{"1.234", "KWD", 1234, false},
+		{"000.10", "EUR", 10, false},
+		{"12.505", "USD", 0, true},
+		{"12.5", "JPY", 0, true},
+		{"12.", "USD", 0, true},
+		{".5", "USD", 0, true},
+		{"1e3", "USD", 0, true},
+		{"", "USD", 0, true},
+		{"12", "XXX", 0, true},
+		{"99999999999999999999", "USD", 0, true},
+	}
+	for _, tc := range testcases {
+		m, err := ParseMoney(tc.amount, tc.currency)
+		if tc.err {
+			assert.Error(t, err, tc.amount)
+			continue
+		}
+		require.NoError(t, err, tc.amount)
+		assert.Equal(t, NewMoney(tc.expected, tc.currency), m, tc.amount)
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	assert.Equal(t, "12.50", NewMoney(1250, "USD").Decimal())
+	assert.Equal(t, "0.05", NewMoney(5, "USD").Decimal())
+	assert.Equal(t, "-0.05", NewMoney(-5, "USD").Decimal())
+	assert.Equal(t, "1200", NewMoney(1200, "JPY").Decimal())
+	assert.Equal(t, "0.001", NewMoney(1, "BHD").Decimal())
+	assert.Equal(t, "12.50 EUR", NewMoney(1250, "EUR").String())
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	m, err := MoneyFromFloat(0.1+0.2, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, NewMoney(30, "USD"), m)
+
+	m, err = MoneyFromFloat(1234.6, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, NewMoney(1235, "JPY"), m)
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := MustParseMoney("10.25", "USD")
+	b := MustParseMoney("0.75", "USD")
+
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	assert.Equal(t, "11.00", sum.Decimal())
+
+	diff, err := b.Sub(a)
+	require.NoError(t, err)
+	assert.Equal(t, "-9.50", diff.Decimal())
+
+	prod, err := a.Mul(3)
+	require.NoError(t, err)
+	assert.Equal(t, "30.75", prod.Decimal())
+
+	c, err := a.Cmp(b)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c)
+
+	// Currencies never mix
+	_, err = a.Add(MustParseMoney("1", "EUR"))
+	assert.Error(t, err)
+	_, err = a.Cmp(MustParseMoney("1", "EUR"))
+	assert.Error(t, err)
+
+	// Overflow
+	_, err = NewMoney(1<<62, "USD").Mul(4)
+	assert.Error(t, err)
+	_, err = NewMoney(1<<62, "USD").Add(NewMoney(1<<62, "USD"))
+	assert.Error(t, err)
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	shares, err := MustParseMoney("10", "USD").Split(3)
+	require.NoError(t, err)
+	assert.Equal(t, []Money{NewMoney(334, "USD"), NewMoney(333, "USD"), NewMoney(333, "USD")}, shares)
+
+	shares, err = NewMoney(-100, "USD").Allocate(1, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Money{NewMoney(-34, "USD"), NewMoney(0, "USD"), NewMoney(-66, "USD")}, shares)
+
+	_, err = NewMoney(100, "USD").Allocate(0, 0)
+	assert.Error(t, err)
+	_, err = NewMoney(100, "USD").Split(0)
+	assert.Error(t, err)
+}
+
+func TestMoneyValidate(t *testing.T) {
+	assert.NoError(t, MustParseMoney("12.50", "USD").Validate())
+	assert.Error(t, NewMoney(-1, "USD").Validate())
+	assert.Error(t, NewMoney(MaxMoneyAmount+1, "USD").Validate())
+	assert.Error(t, NewMoney(100, "").Validate())
+	assert.Error(t, NewMoney(100, "XXX").Validate())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(MustParseMoney("12.5", "USD"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"12.50","currency":"USD"}`, string(b))
+
+	var m Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"1200","currency":"JPY"}`), &m))
+	assert.Equal(t, NewMoney(1200, "JPY"), m)
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"12.505","currency":"USD"}`), &m))
+	assert.Error(t, json.Unmarshal([]byte(`"12.50"`), &m))
+
+	// Legacy float prices decode in the default currency
+	var d Dish
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"a","name":"Pasta","price":12.5}`), &d))
+	assert.Equal(t, NewMoney(1250, DefaultCurrency), d.Price)
+}
+
+func TestDishValidatePrice(t *testing.T) {
+	d := Dish{ID: "a", Name: "Pasta", Price: NewMoney(-100, "USD")}
+	err := d.Validate()
+	require.IsType(t, &Error{}, err)
+	assert.Equal(t, []FieldError{{Field: "price", Message: "cannot be negative"}}, err.(*Error).Fields)
+
+	d.Price = NewMoney(0, "USD")
+	err = d.Validate()
+	require.IsType(t, &Error{}, err)
+	assert.Equal(t, []FieldError{{Field: "price", Message: "cannot be free"}}, err.(*Error).Fields)
+}
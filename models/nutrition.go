@@ -0,0 +1,230 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Nutrition holds nutrient amounts, per 100 g for ingredients and per
+// serving for dishes. Calories are kcal, sodium is in mg and everything else
+// in grams.
+type Nutrition struct {
+	Calories      float64 `json:"calories"`
+	Fat           float64 `json:"fat"`
+	SaturatedFat  float64 `json:"saturatedFat"`
+	Carbohydrates float64 `json:"carbohydrates"`
+	Sugar         float64 `json:"sugar"`
+	Protein       float64 `json:"protein"`
+	Sodium        float64 `json:"sodium"`
+}
+
+// Scale returns the nutrition of factor times the amount, e.g. 2.5 for 250 g
+// of an ingredient with nutrition per 100 g
+func (n Nutrition) Scale(factor float64) Nutrition {
+	return Nutrition{
+		Calories:      n.Calories * factor,
+		Fat:           n.Fat * factor,
+		SaturatedFat:  n.SaturatedFat * factor,
+		Carbohydrates: n.Carbohydrates * factor,
+		Sugar:         n.Sugar * factor,
+		Protein:       n.Protein * factor,
+		Sodium:        n.Sodium * factor,
+	}
+}
+
+// Add returns the sum of both nutritions
+func (n Nutrition) Add(o Nutrition) Nutrition {
+	return Nutrition{
+		Calories:      n.Calories + o.Calories,
+		Fat:           n.Fat + o.Fat,
+		SaturatedFat:  n.SaturatedFat + o.SaturatedFat,
+		Carbohydrates: n.Carbohydrates + o.Carbohydrates,
+		Sugar:         n.Sugar + o.Sugar,
+		Protein:       n.Protein + o.Protein,
+		Sodium:        n.Sodium + o.Sodium,
+	}
+}
+
+// validatePer100g adds every implausible nutrient of 100 g of an ingredient
+// to err, with fields under prefix
+func (n Nutrition) validatePer100g(err *Error, prefix string) *Error {
+	for _, v := range []struct {
+		field string
+		value float64
+	}{
+		{"calories", n.Calories},
+		{"fat", n.Fat},
+		{"saturatedFat", n.SaturatedFat},
+		{"carbohydrates", n.Carbohydrates},
+		{"sugar", n.Sugar},
+		{"protein", n.Protein},
+		{"sodium", n.Sodium},
+	} {
+		if v.value < 0 || math.IsInf(v.value, 0) || math.IsNaN(v.value) {
+			err = err.WithField(prefix+"."+v.field, "must be a non-negative number")
+		}
+	}
+	if n.SaturatedFat > n.Fat {
+		err = err.WithField(prefix+".saturatedFat", "cannot be more than fat")
+	}
+	if n.Sugar > n.Carbohydrates {
+		err = err.WithField(prefix+".sugar", "cannot be more than carbohydrates")
+	}
+	if n.Fat+n.Carbohydrates+n.Protein+n.Sodium/1000 > 100 {
+		err = err.WithField(prefix, "cannot weigh more than 100 g")
+	}
+	return err
+}
+
+// NutritionFacts is the nutrition of one serving of a dish, computed from
+// its recipe. It is only set on responses, never stored.
+type NutritionFacts struct {
+	// ServingSize is the weight of a serving in grams
+	ServingSize float64   `json:"servingSize"`
+	PerServing  Nutrition `json:"perServing"`
+}
+
+/**************************************
+ * Labels
+ *************************************/
+
+// Daily reference values for an adult on a 2,000 kcal diet, as used by FDA
+// nutrition labels
+const (
+	dailyFat           = 78
+	dailySaturatedFat  = 20
+	dailySodium        = 2300
+	dailyCarbohydrates = 275
+)
+
+// NutritionLabel is a nutrition facts label, with amounts rounded the way
+// FDA labels round them
+type NutritionLabel struct {
+	ServingSize string          `json:"servingSize"`
+	Calories    float64         `json:"calories"`
+	Nutrients   []LabelNutrient `json:"nutrients"`
+}
+
+// LabelNutrient is a line of a nutrition label
+type LabelNutrient struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+
+	// DailyValue is the percentage of the daily reference value, for
+	// nutrients that have one
+	DailyValue *int `json:"dailyValue,omitempty"`
+
+	// Indent is set for nutrients that are part of the line above, such as
+	// saturated fat
+	Indent bool `json:"indent,omitempty"`
+}
+
+// roundTo rounds v to the nearest multiple of step
+func roundTo(v, step float64) float64 {
+	return math.Floor(v/step+0.5) * step
+}
+
+// roundGrams rounds fat sized amounts: to 0.5 g below 5 g, whole grams
+// above, and nothing below 0.5 g
+func roundGrams(v float64, halves bool) float64 {
+	switch {
+	case v < 0.5:
+		return 0
+	case halves && v < 5:
+		return roundTo(v, 0.5)
+	}
+	return roundTo(v, 1)
+}
+
+func dailyValue(amount, reference float64) *int {
+	percent := int(roundTo(amount*100/reference, 1))
+	return &percent
+}
+
+// Label renders the facts as a nutrition label
+func (f NutritionFacts) Label() NutritionLabel {
+	n := f.PerServing
+	calories := 0.0
+	switch {
+	case n.Calories < 5:
+	case n.Calories <= 50:
+		calories = roundTo(n.Calories, 5)
+	default:
+		calories = roundTo(n.Calories, 10)
+	}
+	sodium := 0.0
+	switch {
+	case n.Sodium < 5:
+	case n.Sodium <= 140:
+		sodium = roundTo(n.Sodium, 5)
+	default:
+		sodium = roundTo(n.Sodium, 10)
+	}
+	return NutritionLabel{
+		ServingSize: strconv.FormatFloat(roundTo(f.ServingSize, 1), 'f', -1, 64) + " g",
+		Calories:    calories,
+		Nutrients: []LabelNutrient{
+			{Name: "Total Fat", Amount: roundGrams(n.Fat, true), Unit: "g", DailyValue: dailyValue(n.Fat, dailyFat)},
+			{Name: "Saturated Fat", Amount: roundGrams(n.SaturatedFat, true), Unit: "g", DailyValue: dailyValue(n.SaturatedFat, dailySaturatedFat), Indent: true},
+			{Name: "Sodium", Amount: sodium, Unit: "mg", DailyValue: dailyValue(n.Sodium, dailySodium)},
+			{Name: "Total Carbohydrate", Amount: roundGrams(n.Carbohydrates, false), Unit: "g", DailyValue: dailyValue(n.Carbohydrates, dailyCarbohydrates)},
+			{Name: "Total Sugars", Amount: roundGrams(n.Sugar, false), Unit: "g", Indent: true},
+			{Name: "Protein", Amount: roundGrams(n.Protein, false), Unit: "g"},
+		},
+	}
+}
+
+// labelWidth is the width of a plain text label
+const labelWidth = 36
+
+// Text renders the label as a plain text table, e.g.
+//
+//	Nutrition Facts
+//	Serving size                   350 g
+//	====================================
+//	Calories                         520
+//	------------------------------------
+//	                      % Daily Value*
+//	Total Fat 12g                    15%
+//	  Saturated Fat 4g               20%
+//	...
+func (l NutritionLabel) Text() string {
+	var buf bytes.Buffer
+	rule := func(c string) { buf.WriteString(strings.Repeat(c, labelWidth) + "\n") }
+	row := func(left, right string) {
+		if right == "" {
+			buf.WriteString(left + "\n")
+			return
+		}
+		pad := labelWidth - len(left) - len(right)
+		if pad < 1 {
+			pad = 1
+		}
+		buf.WriteString(left + strings.Repeat(" ", pad) + right + "\n")
+	}
+
+	buf.WriteString("Nutrition Facts\n")
+	row("Serving size", l.ServingSize)
+	rule("=")
+	row("Calories", strconv.FormatFloat(l.Calories, 'f', -1, 64))
+	rule("-")
+	row("", "% Daily Value*")
+	for _, n := range l.Nutrients {
+		left := fmt.Sprintf("%v %v%v", n.Name, strconv.FormatFloat(n.Amount, 'f', -1, 64), n.Unit)
+		if n.Indent {
+			left = "  " + left
+		}
+		right := ""
+		if n.DailyValue != nil {
+			right = fmt.Sprintf("%d%%", *n.DailyValue)
+		}
+		row(left, right)
+	}
+	rule("-")
+	buf.WriteString("* Percent Daily Values are based on\na 2,000 calorie diet.\n")
+	return buf.String()
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngredientGrams(t *testing.T) {
+	milk := Ingredient{Name: "Milk", Density: 1.03}
+	egg := Ingredient{Name: "Egg", ItemWeight: 50}
+	testcases := []struct {
+		ingredient Ingredient
+		quantity   Quantity
+		expected   float64
+	}{
+		{milk, Quantity{8, UnitOunce}, 226.796185},
+		{milk, Quantity{0.5, UnitLiter}, 515},
+		{milk, Quantity{1, UnitCup}, 243.68588},
+		{egg, Quantity{3, UnitEach}, 150},
+	}
+	for _, tc := range testcases {
+		grams, err := tc.ingredient.Grams(tc.quantity)
+		require.NoError(t, err, tc.quantity.String())
+		assert.InDelta(t, tc.expected, grams, 1e-5, tc.quantity.String())
+	}
+
+	_, err := Ingredient{Name: "Oil"}.Grams(Quantity{100, UnitMilliliter})
+	assert.EqualError(t, err, "Oil has no density")
+	_, err = Ingredient{Name: "Lemon"}.Grams(Quantity{1, UnitEach})
+	assert.EqualError(t, err, "Lemon has no item weight")
+}
+
+func TestIngredientNutritionValidate(t *testing.T) {
+	i := NewIngredient(IngredientParams{})
+	i.Name, i.Cost, i.Unit = "Butter", MustParseMoney("8.00", "USD"), UnitKilogram
+	i.Density = -1
+	i.Nutrition = &Nutrition{Calories: 717, Fat: 81, SaturatedFat: 90, Carbohydrates: 20, Sugar: -1}
+	err := i.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "nutrition.sugar", Message: "must be a non-negative number"},
+		{Field: "nutrition.saturatedFat", Message: "cannot be more than fat"},
+		{Field: "nutrition", Message: "cannot weigh more than 100 g"},
+		{Field: "density", Message: "must be a non-negative number"},
+	}, err.(*Error).Fields)
+
+	i.Density = 0.91
+	i.Nutrition = &Nutrition{Calories: 717, Fat: 81, SaturatedFat: 51, Carbohydrates: 0.1, Sugar: 0.1, Protein: 0.9, Sodium: 11}
+	assert.NoError(t, i.Validate())
+}
+
+func TestNutritionLabel(t *testing.T) {
+	facts := NutritionFacts{
+		ServingSize: 349.6,
+		PerServing: Nutrition{
+			Calories:      523.4,
+			Fat:           11.7,
+			SaturatedFat:  4.2,
+			Carbohydrates: 80.4,
+			Sugar:         3.3,
+			Protein:       22.6,
+			Sodium:        912,
+		},
+	}
+	label := facts.Label()
+	assert.Equal(t, "350 g", label.ServingSize)
+	assert.Equal(t, 520.0, label.Calories)
+	require.Len(t, label.Nutrients, 6)
+	assert.Equal(t, 12.0, label.Nutrients[0].Amount)
+	assert.Equal(t, 15, *label.Nutrients[0].DailyValue)
+	assert.Equal(t, 4.0, label.Nutrients[1].Amount)
+	assert.Equal(t, 21, *label.Nutrients[1].DailyValue)
+	assert.Equal(t, 910.0, label.Nutrients[2].Amount)
+	assert.Nil(t, label.Nutrients[4].DailyValue)
+
+	assert.Equal(t, "Nutrition Facts\n"+
+		"Serving size                   350 g\n"+
+		"====================================\n"+
+		"Calories                         520\n"+
+		"------------------------------------\n"+
+		"                      % Daily Value*\n"+
+		"Total Fat 12g                    15%\n"+
+		"  Saturated Fat 4g               21%\n"+
+		"Sodium 910mg                     40%\n"+
+		"Total Carbohydrate 80g           29%\n"+
+		"  Total Sugars 3g\n"+
+		"Protein 23g\n"+
+		"------------------------------------\n"+
+		"* Percent Daily Values are based on\n"+
+		"a 2,000 calorie diet.\n", label.Text())
+
+	// Small amounts round to halves, or to nothing
+	label = NutritionFacts{ServingSize: 30, PerServing: Nutrition{Calories: 4, Fat: 2.3, SaturatedFat: 0.4, Sodium: 62}}.Label()
+	assert.Equal(t, 0.0, label.Calories)
+	assert.Equal(t, 2.5, label.Nutrients[0].Amount)
+	assert.Equal(t, 0.0, label.Nutrients[1].Amount)
+	assert.Equal(t, 60.0, label.Nutrients[2].Amount)
+}
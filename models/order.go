@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+)
+
+// OrderStatus is where an order is in its lifecycle
+type OrderStatus string
+
+const (
+	OrderPlaced    OrderStatus = "placed"
+	OrderAccepted  OrderStatus = "accepted"
+	OrderPreparing OrderStatus = "preparing"
+	OrderReady     OrderStatus = "ready"
+	OrderCompleted OrderStatus = "completed"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// orderTransitions lists the statuses each status can move to. Orders can be
+// cancelled until they are completed, including ready orders nobody picks up.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPlaced:    {OrderAccepted, OrderCancelled},
+	OrderAccepted:  {OrderPreparing, OrderCancelled},
+	OrderPreparing: {OrderReady, OrderCancelled},
+	OrderReady:     {OrderCompleted, OrderCancelled},
+}
+
+// Valid reports if the status is known
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderPlaced, OrderAccepted, OrderPreparing, OrderReady, OrderCompleted, OrderCancelled:
+		return true
+	}
+	return false
+}
+
+// Final reports if the order can no longer change
+func (s OrderStatus) Final() bool {
+	return s == OrderCompleted || s == OrderCancelled
+}
+
+// CanMoveTo reports if an order can go straight from s to the status
+func (s OrderStatus) CanMoveTo(to OrderStatus) bool {
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderLineParams is a dish as ordered, see Configuration
+type OrderLineParams struct {
+	DishID string `json:"dishId"`
+	Configuration
+
+	// Notes are passed on to the kitchen, e.g. "no onions"
+	Notes string `json:"notes,omitempty"`
+}
+
+// OrderParams are the fields of an order given when placing it. Orders are
+// never edited afterwards, only moved through their lifecycle.
+type OrderParams struct {
+	Lines []OrderLineParams `json:"lines"`
+	Notes string            `json:"notes,omitempty"`
+}
+
+// Validate returns an invalid argument error listing every bad field, the
+// dishes and their configurations are checked when pricing the lines
+func (p OrderParams) Validate() error {
+	err := InvalidArgument("invalid order")
+	if len(p.Lines) == 0 {
+		err = err.WithField("lines", "cannot be empty")
+	}
+	for i, l := range p.Lines {
+		if l.DishID == "" {
+			err = err.WithField(fmt.Sprintf("lines[%d].dishId", i), "cannot be empty string")
+		}
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// OrderLine is a dish as ordered. Name and Price snapshot the dish when the
+// order was placed, so later changes to the dish leave the order untouched.
+type OrderLine struct {
+	DishID string `json:"dishId"`
+	Name   string `json:"name"`
+
+	// Price itemizes the dish and its chosen modifiers, and carries the
+	// quantity, see Dish.CalculatePrice
+	Price PriceBreakdown `json:"price"`
+
+	// TaxCategory snapshots how the dish is taxed, see Dish.TaxedAs
+	TaxCategory TaxCategory `json:"taxCategory"`
+
+	Notes string `json:"notes,omitempty"`
+}
+
+// StatusChange records when an order moved into a status
+type StatusChange struct {
+	Status OrderStatus `json:"status"`
+	At     time.Time   `json:"at"`
+}
+
+type Order struct {
+	ID     string      `json:"id"`
+	Status OrderStatus `json:"status"`
+	Lines  []OrderLine `json:"lines"`
+	Notes  string      `json:"notes,omitempty"`
+
+	// Total is what the customer pays: the line totals, all priced in its
+	// currency, plus any tax not included in them
+	Total Money `json:"total"`
+
+	// Tax itemizes the tax on the order, if it was taxed
+	Tax *TaxBreakdown `json:"tax,omitempty"`
+
+	// History lists every status the order has been in, oldest first,
+	// starting with placed
+	History []StatusChange `json:"history"`
+
+	// Version starts at 1 and is incremented by every status change
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// NewOrder places an order for the priced lines. It fails with an invalid
+// argument error if the lines are priced in different currencies.
+func NewOrder(lines []OrderLine, notes string) (*Order, error) {
+	if len(lines) == 0 {
+		return nil, InvalidArgument("invalid order").WithField("lines", "cannot be empty")
+	}
+	currency := lines[0].Price.Total.Currency
+	total := Money{Currency: currency}
+	for i, l := range lines {
+		sum, err := total.Add(l.Price.Total)
+		if err != nil {
+			return nil, InvalidArgument("invalid order").
+				WithField(fmt.Sprintf("lines[%d].dishId", i), "must be priced in "+currency)
+		}
+		total = sum
+	}
+	now := time.Now()
+	return &Order{
+		ID:      random.SecureString(10),
+		Status:  OrderPlaced,
+		Lines:   lines,
+		Notes:   notes,
+		Total:   total,
+		History: []StatusChange{{Status: OrderPlaced, At: now}},
+		Version: 1,
+		Created: now,
+		Updated: now,
+	}, nil
+}
+
+// ApplyTax levies the jurisdiction's taxes on the order lines and sets the
+// total to the gross amount
+func (o *Order) ApplyTax(j Jurisdiction) error {
+	lines := make([]TaxableLine, len(o.Lines))
+	for i, l := range o.Lines {
+		lines[i] = TaxableLine{Category: l.TaxCategory, Amount: l.Price.Total}
+	}
+	tax, err := j.CalculateTax(lines)
+	if err != nil {
+		return err
+	}
+	o.Tax = tax
+	o.Total = tax.Gross
+	return nil
+}
+
+// Transition moves the order into status at the instant, recording it in
+// the history. It fails with a conflict error if the order cannot move
+// there from its current status.
+func (o *Order) Transition(to OrderStatus, at time.Time) error {
+	if !to.Valid() {
+		return InvalidArgument("invalid status").
+			WithField("status", fmt.Sprintf("unknown order status %q", to))
+	}
+	if o.Status.Final() {
+		return Conflict("order is already %v", o.Status)
+	}
+	if !o.Status.CanMoveTo(to) {
+		next := make([]string, 0, len(orderTransitions[o.Status]))
+		for _, s := range orderTransitions[o.Status] {
+			next = append(next, string(s))
+		}
+		return Conflict("order is %v and cannot become %v", o.Status, to).
+			WithField("status", "must be one of "+strings.Join(next, ", "))
+	}
+	o.Status = to
+	o.History = append(o.History, StatusChange{Status: to, At: at})
+	o.Version++
+	o.Updated = at
+	return nil
+}
+
+// StatusAt returns when the order moved into status, or the zero time if it
+// never did
+func (o Order) StatusAt(status OrderStatus) time.Time {
+	for _, c := range o.History {
+		if c.Status == status {
+			return c.At
+		}
+	}
+	return time.Time{}
+}
+
+// CheckVersion returns a conflict error unless the order is at the expected
+// version. An expected version of 0 matches any version.
+func (o Order) CheckVersion(expected int64) error {
+	if expected != 0 && expected != o.Version {
+		return Conflict("order has been modified, current version is %d", o.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
+	}
+	return nil
+}
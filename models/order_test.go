@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderTransitions(t *testing.T) {
+	line := OrderLine{DishID: "pasta", Name: "Pasta", Price: PriceBreakdown{Total: MustParseMoney("20", "USD")}}
+	order, err := NewOrder([]OrderLine{line, line}, "")
+	require.NoError(t, err)
+	assert.Equal(t, OrderPlaced, order.Status)
+	assert.Equal(t, "40.00 USD", order.Total.String())
+
+	// The happy path
+	start := order.Created
+	for i, status := range []OrderStatus{OrderAccepted, OrderPreparing, OrderReady, OrderCompleted} {
+		at := start.Add(time.Duration(i+1) * time.Minute)
+		require.NoError(t, order.Transition(status, at), string(status))
+		assert.Equal(t, at, order.StatusAt(status))
+	}
+	assert.Equal(t, int64(5), order.Version)
+	assert.Len(t, order.History, 5)
+	assert.Equal(t, start, order.StatusAt(OrderPlaced))
+	assert.True(t, order.StatusAt(OrderCancelled).IsZero())
+
+	// Final orders stay put
+	err = order.Transition(OrderCancelled, time.Now())
+	assert.EqualError(t, err, "order is already completed")
+
+	// Statuses cannot be skipped or revisited
+	order, err = NewOrder([]OrderLine{line}, "")
+	require.NoError(t, err)
+	err = order.Transition(OrderReady, time.Now())
+	require.Error(t, err)
+	assert.Equal(t, KindConflict, KindOf(err))
+	assert.Equal(t, []FieldError{{Field: "status", Message: "must be one of accepted, cancelled"}}, err.(*Error).Fields)
+	require.NoError(t, order.Transition(OrderAccepted, time.Now()))
+	assert.Equal(t, KindConflict, KindOf(order.Transition(OrderPlaced, time.Now())))
+	assert.Equal(t, KindInvalidArgument, KindOf(order.Transition("eaten", time.Now())))
+	require.NoError(t, order.Transition(OrderCancelled, time.Now()))
+
+	// Lines share a currency
+	euros := OrderLine{Price: PriceBreakdown{Total: MustParseMoney("5", "EUR")}}
+	_, err = NewOrder([]OrderLine{line, euros}, "")
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{{Field: "lines[1].dishId", Message: "must be priced in USD"}}, err.(*Error).Fields)
+}
@@ -0,0 +1,644 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jeffizhungry/polygon/lib/random"
+)
+
+// DiscountKind is how a promotion takes money off
+type DiscountKind string
+
+const (
+	// DiscountPercent takes Percent off every qualifying line
+	DiscountPercent DiscountKind = "percent"
+
+	// DiscountFixed takes Amount off the qualifying lines, shared between
+	// them in proportion to their prices
+	DiscountFixed DiscountKind = "fixed"
+
+	// DiscountBuyGet takes Percent off Get items for every Buy items bought,
+	// the cheapest qualifying items, e.g. buy one get one free
+	DiscountBuyGet DiscountKind = "buy_get"
+)
+
+// Discount is what a promotion takes off the qualifying lines of a cart
+type Discount struct {
+	Kind DiscountKind `json:"kind"`
+
+	// Percent is a decimal percentage such as "15". Buy and get discounts
+	// give the items away if it is empty.
+	Percent string `json:"percent,omitempty"`
+
+	// Amount is taken off by fixed discounts, never more than the lines
+	// cost
+	Amount *Money `json:"amount,omitempty"`
+
+	Buy int64 `json:"buy,omitempty"`
+	Get int64 `json:"get,omitempty"`
+}
+
+// ratio returns the percentage taken off as an exact fraction
+func (d Discount) ratio() (*big.Rat, bool) {
+	if d.Kind == DiscountBuyGet && d.Percent == "" {
+		return big.NewRat(1, 1), true
+	}
+	return parsePercent(d.Percent)
+}
+
+// String describes the discount to customers, e.g. "15% off" or "buy 2 get
+// 1 free"
+func (d Discount) String() string {
+	switch d.Kind {
+	case DiscountPercent:
+		return d.Percent + "% off"
+	case DiscountFixed:
+		if d.Amount != nil {
+			return d.Amount.String() + " off"
+		}
+	case DiscountBuyGet:
+		off := "free"
+		if ratio, ok := d.ratio(); ok && ratio.Cmp(big.NewRat(1, 1)) != 0 {
+			off = d.Percent + "% off"
+		}
+		return fmt.Sprintf("buy %d get %d %v", d.Buy, d.Get, off)
+	}
+	return string(d.Kind)
+}
+
+// validateDiscount adds a field error to err for every bad discount field
+func validateDiscount(err *Error, d Discount) *Error {
+	switch d.Kind {
+	case DiscountPercent, DiscountBuyGet:
+		if ratio, ok := d.ratio(); !ok {
+			err = err.WithField("discount.percent", "must be a non-negative decimal number")
+		} else if ratio.Sign() == 0 || ratio.Cmp(big.NewRat(1, 1)) > 0 {
+			err = err.WithField("discount.percent", "must be more than 0 and at most 100")
+		}
+	case DiscountFixed:
+		switch {
+		case d.Amount == nil:
+			err = err.WithField("discount.amount", "is required")
+		case d.Amount.invalid() != "":
+			err = err.WithField("discount.amount", d.Amount.invalid())
+		case d.Amount.IsZero():
+			err = err.WithField("discount.amount", "cannot be zero")
+		}
+	default:
+		err = err.WithField("discount.kind", "must be one of percent, fixed, buy_get")
+	}
+	if d.Kind == DiscountBuyGet {
+		if d.Buy <= 0 {
+			err = err.WithField("discount.buy", "must be positive")
+		}
+		if d.Get <= 0 {
+			err = err.WithField("discount.get", "must be positive")
+		}
+	}
+	return err
+}
+
+// PromotionConditions restrict when a promotion applies. Every condition
+// given must hold.
+type PromotionConditions struct {
+
+	// DishIDs and CategoryIDs select the qualifying lines, a line qualifies
+	// if its dish is listed or is in a listed category. Every line
+	// qualifies if both are empty.
+	DishIDs     []string `json:"dishIds,omitempty"`
+	CategoryIDs []string `json:"categoryIds,omitempty"`
+
+	// Availability limits the promotion to daily windows, e.g. a happy
+	// hour, in the restaurant's time zone. It always applies if empty.
+	Availability []Availability `json:"availability,omitempty"`
+
+	// StartsAt and EndsAt bound when the promotion runs, EndsAt is
+	// exclusive
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+
+	// MinSpend is the least the cart must cost before any discount
+	MinSpend *Money `json:"minSpend,omitempty"`
+}
+
+// Qualifies reports if a line for the dish in the category qualifies
+func (c PromotionConditions) Qualifies(dishID, categoryID string) bool {
+	if len(c.DishIDs) == 0 && len(c.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range c.DishIDs {
+		if id == dishID {
+			return true
+		}
+	}
+	for _, id := range c.CategoryIDs {
+		if categoryID != "" && id == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// RunsAt reports if t is within the promotion's run and availability. The
+// caller converts t into the restaurant's time zone first.
+func (c PromotionConditions) RunsAt(t time.Time) bool {
+	if c.StartsAt != nil && t.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && !t.Before(*c.EndsAt) {
+		return false
+	}
+	if len(c.Availability) == 0 {
+		return true
+	}
+	for _, a := range c.Availability {
+		if a.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// PromotionParams are the fields of a promotion that can be set on creation
+// and updated afterwards
+type PromotionParams struct {
+	Name *string `json:"name,omitempty"`
+
+	// Code makes the promotion a coupon, an empty code makes it automatic
+	Code *string `json:"code,omitempty"`
+
+	Conditions *PromotionConditions `json:"conditions,omitempty"`
+	Discount   *Discount            `json:"discount,omitempty"`
+	Priority   *int                 `json:"priority,omitempty"`
+	Exclusive  *bool                `json:"exclusive,omitempty"`
+
+	// Version is the version the update expects the promotion to be at. It
+	// is ignored on creation.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// Promotion discounts carts that meet its conditions. Promotions are tried
+// highest Priority first, and stack unless one of them is Exclusive.
+type Promotion struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Code is the coupon code customers enter, upper cased. Coupons only
+	// apply to carts carrying their code, other promotions to every cart.
+	Code string `json:"code,omitempty"`
+
+	Conditions PromotionConditions `json:"conditions"`
+	Discount   Discount            `json:"discount"`
+	Priority   int                 `json:"priority"`
+
+	// Exclusive promotions are never combined with others. They only apply
+	// if no promotion with a higher priority did, and stop any lower one.
+	Exclusive bool `json:"exclusive"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func NewPromotion(params PromotionParams) *Promotion {
+	now := time.Now()
+	p := &Promotion{
+		ID:      random.SecureString(10),
+		Version: 1,
+		Created: now,
+		Updated: now,
+	}
+	p.Apply(params)
+	return p
+}
+
+// Apply sets every field given in params
+func (p *Promotion) Apply(params PromotionParams) {
+	if params.Name != nil {
+		p.Name = *params.Name
+	}
+	if params.Code != nil {
+		p.Code = NormalizeCode(*params.Code)
+	}
+	if params.Conditions != nil {
+		p.Conditions = *params.Conditions
+	}
+	if params.Discount != nil {
+		p.Discount = *params.Discount
+	}
+	if params.Priority != nil {
+		p.Priority = *params.Priority
+	}
+	if params.Exclusive != nil {
+		p.Exclusive = *params.Exclusive
+	}
+}
+
+const maxCodeLength = 32
+
+// NormalizeCode trims and upper cases a coupon code, codes are matched
+// regardless of case
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (p Promotion) Validate() error {
+	err := InvalidArgument("invalid promotion")
+	if p.ID == "" {
+		err = err.WithField("id", "cannot be empty string")
+	}
+	if p.Name == "" {
+		err = err.WithField("name", "cannot be empty string")
+	}
+	switch {
+	case len(p.Code) > maxCodeLength:
+		err = err.WithField("code", fmt.Sprintf("cannot be longer than %d characters", maxCodeLength))
+	case strings.IndexFunc(p.Code, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' }) >= 0:
+		err = err.WithField("code", "can only contain letters, digits, dashes and underscores")
+	}
+	c := p.Conditions
+	for i, id := range c.DishIDs {
+		if id == "" {
+			err = err.WithField(fmt.Sprintf("conditions.dishIds[%d]", i), "cannot be empty string")
+		}
+	}
+	for i, id := range c.CategoryIDs {
+		if id == "" {
+			err = err.WithField(fmt.Sprintf("conditions.categoryIds[%d]", i), "cannot be empty string")
+		}
+	}
+	err = validateAvailability(err, "conditions.availability", c.Availability)
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		err = err.WithField("conditions.endsAt", "must be after startsAt")
+	}
+	if c.MinSpend != nil {
+		if msg := c.MinSpend.invalid(); msg != "" {
+			err = err.WithField("conditions.minSpend", msg)
+		}
+	}
+	err = validateDiscount(err, p.Discount)
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// CheckVersion returns a conflict error unless the promotion is at the
+// expected version. An expected version of 0 matches any version.
+func (p Promotion) CheckVersion(expected int64) error {
+	if expected != 0 && expected != p.Version {
+		return Conflict("promotion has been modified, current version is %d", p.Version).
+			WithField("version", fmt.Sprintf("expected %d", expected))
+	}
+	return nil
+}
+
+// SortPromotions orders promotions the way they are tried: highest priority
+// first, then by name
+func SortPromotions(promotions []Promotion) {
+	sort.Slice(promotions, func(i, j int) bool {
+		a, b := &promotions[i], &promotions[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+}
+
+/**************************************
+ * Carts
+ *************************************/
+
+// Cart is what a customer is about to order, with the coupon codes they
+// entered
+type Cart struct {
+	Lines []OrderLineParams `json:"lines"`
+	Codes []string          `json:"codes,omitempty"`
+
+	// At is when the cart is priced, now if it is zero
+	At time.Time `json:"at"`
+}
+
+// CartLine is a priced line of a cart
+type CartLine struct {
+	DishID     string `json:"dishId"`
+	CategoryID string `json:"categoryId,omitempty"`
+	Name       string `json:"name"`
+
+	// Price is the line before discounts, see Dish.CalculatePrice
+	Price PriceBreakdown `json:"price"`
+
+	// Discount sums every discount on the line, Total is what is left to
+	// pay for it
+	Discount Money `json:"discount"`
+	Total    Money `json:"total"`
+}
+
+// AppliedDiscount is a promotion that discounted the cart
+type AppliedDiscount struct {
+	PromotionID string `json:"promotionId"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Amount      Money  `json:"amount"`
+
+	// Explanation tells customers what they got, e.g. "15% off (Beer,
+	// Wine)"
+	Explanation string `json:"explanation"`
+
+	// Lines lists the indexes of the cart lines it discounted
+	Lines []int `json:"lines"`
+}
+
+// SkippedPromotion is a promotion that was tried and did not apply, or a
+// coupon code that matches no promotion
+type SkippedPromotion struct {
+	PromotionID string `json:"promotionId,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Code        string `json:"code,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// CartPrice is the price of a cart after promotions
+type CartPrice struct {
+	Lines     []CartLine        `json:"lines"`
+	Discounts []AppliedDiscount `json:"discounts"`
+
+	// Skipped explains why the promotions tried did not apply
+	Skipped []SkippedPromotion `json:"skipped,omitempty"`
+
+	Subtotal Money `json:"subtotal"`
+	Discount Money `json:"discount"`
+	Total    Money `json:"total"`
+}
+
+// ApplyPromotions prices the lines, with Price and the dish fields set, after
+// every promotion that applies at t. Promotions are tried in the order of
+// SortPromotions, each discounting what is left of the lines after the ones
+// before it, so discounts never add up to more than a line costs. Coupons are
+// only tried if their code is among codes. The caller converts t into the
+// restaurant's time zone first.
+func ApplyPromotions(promotions []Promotion, lines []CartLine, codes []string, t time.Time) (*CartPrice, error) {
+	if len(lines) == 0 {
+		return nil, InvalidArgument("invalid cart").WithField("lines", "cannot be empty")
+	}
+	currency := lines[0].Price.Total.Currency
+	zero := Money{Currency: currency}
+	price := &CartPrice{
+		Lines:     make([]CartLine, len(lines)),
+		Discounts: []AppliedDiscount{},
+		Subtotal:  zero,
+		Discount:  zero,
+	}
+	for i, l := range lines {
+		if l.Price.Total.Currency != currency {
+			return nil, InvalidArgument("invalid cart").
+				WithField(fmt.Sprintf("lines[%d].dishId", i), "must be priced in "+currency)
+		}
+		l.Discount = zero
+		l.Total = l.Price.Total
+		price.Lines[i] = l
+		price.Subtotal.Amount += l.Price.Total.Amount
+	}
+
+	// Match the coupon codes
+	known := make(map[string]bool)
+	for _, p := range promotions {
+		known[p.Code] = p.Code != ""
+	}
+	entered := make(map[string]bool)
+	for _, code := range codes {
+		code = NormalizeCode(code)
+		if code == "" || entered[code] {
+			continue
+		}
+		entered[code] = true
+		if !known[code] {
+			price.Skipped = append(price.Skipped, SkippedPromotion{Code: code, Reason: "unknown coupon code"})
+		}
+	}
+
+	// Try every promotion in turn
+	sorted := append([]Promotion(nil), promotions...)
+	SortPromotions(sorted)
+	exclusive := "" // the exclusive promotion that applied, if any
+	for _, p := range sorted {
+		if p.Code != "" && !entered[p.Code] {
+			continue
+		}
+		skip := func(reason string) {
+			price.Skipped = append(price.Skipped, SkippedPromotion{
+				PromotionID: p.ID,
+				Name:        p.Name,
+				Code:        p.Code,
+				Reason:      reason,
+			})
+		}
+		switch {
+		case exclusive != "":
+			skip("cannot be combined with " + exclusive)
+			continue
+		case p.Exclusive && len(price.Discounts) > 0:
+			names := make([]string, len(price.Discounts))
+			for i, d := range price.Discounts {
+				names[i] = d.Name
+			}
+			skip("cannot be combined with " + strings.Join(names, ", "))
+			continue
+		case !p.Conditions.RunsAt(t):
+			skip("not running at " + t.Format("Mon 15:04"))
+			continue
+		}
+		if min := p.Conditions.MinSpend; min != nil {
+			if min.Currency != currency {
+				skip("minimum spend is in " + min.Currency)
+				continue
+			}
+			if price.Subtotal.Amount < min.Amount {
+				skip(fmt.Sprintf("spend at least %v", *min))
+				continue
+			}
+		}
+		var qualifying []int
+		for i, l := range price.Lines {
+			if p.Conditions.Qualifies(l.DishID, l.CategoryID) {
+				qualifying = append(qualifying, i)
+			}
+		}
+		if len(qualifying) == 0 {
+			skip("no qualifying dishes in the cart")
+			continue
+		}
+
+		// Work out the discount of every qualifying line
+		off, reason, err := p.Discount.apply(price.Lines, qualifying)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			skip(reason)
+			continue
+		}
+		applied := AppliedDiscount{
+			PromotionID: p.ID,
+			Name:        p.Name,
+			Code:        p.Code,
+			Amount:      zero,
+			Lines:       []int{},
+		}
+		var names []string
+		for k, i := range qualifying {
+			if off[k] == 0 {
+				continue
+			}
+			l := &price.Lines[i]
+			l.Discount.Amount += off[k]
+			l.Total.Amount -= off[k]
+			applied.Amount.Amount += off[k]
+			applied.Lines = append(applied.Lines, i)
+			names = append(names, l.Name)
+		}
+		applied.Explanation = fmt.Sprintf("%v (%v)", p.Discount, strings.Join(names, ", "))
+		price.Discounts = append(price.Discounts, applied)
+		price.Discount.Amount += applied.Amount.Amount
+		if p.Exclusive {
+			exclusive = p.Name
+		}
+	}
+	price.Total = Money{Amount: price.Subtotal.Amount - price.Discount.Amount, Currency: currency}
+	return price, nil
+}
+
+// apply works out how many minor units to take off each qualifying line,
+// never more than is left of it. It returns why nothing could be taken off
+// instead, if so.
+func (d Discount) apply(lines []CartLine, qualifying []int) ([]int64, string, error) {
+	off := make([]int64, len(qualifying))
+	var left int64
+	for _, i := range qualifying {
+		left += lines[i].Total.Amount
+	}
+	if left == 0 {
+		return nil, "nothing left to discount", nil
+	}
+
+	switch d.Kind {
+	case DiscountPercent:
+		ratio, ok := d.ratio()
+		if !ok {
+			return nil, "", InvalidArgument("invalid discount percent %q", d.Percent)
+		}
+		for k, i := range qualifying {
+			amount, err := lines[i].Total.MulRat(ratio, Rounding{})
+			if err != nil {
+				return nil, "", err
+			}
+			off[k] = amount.Amount
+		}
+
+	case DiscountFixed:
+		if d.Amount == nil {
+			return nil, "", InvalidArgument("fixed discount without an amount")
+		}
+		if d.Amount.Currency != lines[qualifying[0]].Total.Currency {
+			return nil, "discount is in " + d.Amount.Currency, nil
+		}
+		total := *d.Amount
+		if total.Amount > left {
+			total.Amount = left
+		}
+		ratios := make([]int64, len(qualifying))
+		for k, i := range qualifying {
+			ratios[k] = lines[i].Total.Amount
+		}
+		shares, err := total.Allocate(ratios...)
+		if err != nil {
+			return nil, "", err
+		}
+		for k := range shares {
+			off[k] = shares[k].Amount
+		}
+
+	case DiscountBuyGet:
+		ratio, ok := d.ratio()
+		if !ok {
+			return nil, "", InvalidArgument("invalid discount percent %q", d.Percent)
+		}
+
+		// Line up every item, dearest first, and discount the cheapest Get
+		// of every Buy+Get in a row. Lines are runs of items at the same
+		// price, so count the discounted items of each run rather than
+		// lining up every item.
+		type run struct {
+			k     int
+			price Money
+			count int64
+		}
+		var runs []run
+		var items int64
+		for k, i := range qualifying {
+			runs = append(runs, run{k, lines[i].Price.UnitPrice, lines[i].Price.Quantity})
+			items += lines[i].Price.Quantity
+		}
+		sort.SliceStable(runs, func(a, b int) bool {
+			return runs[a].price.Amount > runs[b].price.Amount
+		})
+		group := d.Buy + d.Get
+		if group <= 0 || items < group {
+			return nil, fmt.Sprintf("needs %d qualifying items, the cart has %d", group, items), nil
+		}
+
+		// discounted counts the discounted items among the first n, only
+		// whole groups count
+		whole := items / group * group
+		discounted := func(n int64) int64 {
+			if n > whole {
+				n = whole
+			}
+			free := n%group - d.Buy
+			if free < 0 {
+				free = 0
+			}
+			return n/group*d.Get + free
+		}
+		var start int64
+		for _, r := range runs {
+			free := discounted(start+r.count) - discounted(start)
+			start += r.count
+			if free == 0 {
+				continue
+			}
+			amount, err := r.price.MulRat(ratio, Rounding{})
+			if err != nil {
+				return nil, "", err
+			}
+			off[r.k] += amount.Amount * free
+		}
+
+	default:
+		return nil, "", InvalidArgument("unknown discount kind %q", d.Kind)
+	}
+
+	// Never take off more than is left
+	var total int64
+	for k, i := range qualifying {
+		if off[k] > lines[i].Total.Amount {
+			off[k] = lines[i].Total.Amount
+		}
+		total += off[k]
+	}
+	if total == 0 {
+		return nil, "nothing left to discount", nil
+	}
+	return off, "", nil
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cartLine(dishID, categoryID, name, unit string, quantity int64) CartLine {
+	price := usd(unit)
+	total, _ := price.Mul(quantity)
+	return CartLine{
+		DishID:     dishID,
+		CategoryID: categoryID,
+		Name:       name,
+		Price:      PriceBreakdown{UnitPrice: price, Quantity: quantity, Total: total},
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	minSpend := usd("30")
+	five := usd("5")
+	promotions := []Promotion{
+		{
+			ID: "coupon", Name: "Five off", Code: "SAVE5", Priority: 0,
+			Conditions: PromotionConditions{MinSpend: &minSpend},
+			Discount:   Discount{Kind: DiscountFixed, Amount: &five},
+		},
+		{
+			ID: "staff", Name: "Staff meal", Priority: 1, Exclusive: true,
+			Discount: Discount{Kind: DiscountPercent, Percent: "50"},
+		},
+		{
+			ID: "happy", Name: "Happy hour", Priority: 10,
+			Conditions: PromotionConditions{
+				CategoryIDs:  []string{"drinks"},
+				Availability: []Availability{{Days: Weekdays, Start: NewTimeOfDay(16, 0), End: NewTimeOfDay(18, 0)}},
+			},
+			Discount: Discount{Kind: DiscountPercent, Percent: "20"},
+		},
+		{
+			ID: "bogo", Name: "Beer BOGO", Priority: 5,
+			Conditions: PromotionConditions{DishIDs: []string{"beer"}},
+			Discount:   Discount{Kind: DiscountBuyGet, Buy: 1, Get: 1},
+		},
+	}
+	lines := []CartLine{
+		cartLine("beer", "drinks", "Beer", "6", 3),
+		cartLine("wine", "drinks", "Wine", "9", 1),
+		cartLine("burger", "mains", "Burger", "12", 1),
+	}
+	wednesday := time.Date(2017, 6, 7, 17, 0, 0, 0, time.UTC)
+
+	// Happy hour and BOGO stack, then the coupon shares five off
+	price, err := ApplyPromotions(promotions, lines, []string{" save5", "BOGUS"}, wednesday)
+	require.NoError(t, err)
+	assert.Equal(t, "39.00 USD", price.Subtotal.String())
+	require.Len(t, price.Discounts, 3)
+	assert.Equal(t, AppliedDiscount{
+		PromotionID: "happy", Name: "Happy hour", Amount: usd("5.40"),
+		Explanation: "20% off (Beer, Wine)", Lines: []int{0, 1},
+	}, price.Discounts[0])
+	assert.Equal(t, "6.00 USD", price.Discounts[1].Amount.String())
+	assert.Equal(t, "buy 1 get 1 free (Beer)", price.Discounts[1].Explanation)
+	assert.Equal(t, AppliedDiscount{
+		PromotionID: "coupon", Name: "Five off", Code: "SAVE5", Amount: usd("5"),
+		Explanation: "5.00 USD off (Beer, Wine, Burger)", Lines: []int{0, 1, 2},
+	}, price.Discounts[2])
+	assert.Equal(t, []SkippedPromotion{
+		{Code: "BOGUS", Reason: "unknown coupon code"},
+		{PromotionID: "staff", Name: "Staff meal", Reason: "cannot be combined with Happy hour, Beer BOGO"},
+	}, price.Skipped)
+	assert.Equal(t, "11.13 USD", price.Lines[0].Discount.String())
+	assert.Equal(t, "6.87 USD", price.Lines[0].Total.String())
+	assert.Equal(t, "5.90 USD", price.Lines[1].Total.String())
+	assert.Equal(t, "9.83 USD", price.Lines[2].Total.String())
+	assert.Equal(t, "16.40 USD", price.Discount.String())
+	assert.Equal(t, "22.60 USD", price.Total.String())
+
+	// After happy hour, without the coupon
+	price, err = ApplyPromotions(promotions, lines, nil, wednesday.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, price.Discounts, 1)
+	assert.Equal(t, "bogo", price.Discounts[0].PromotionID)
+	assert.Equal(t, []SkippedPromotion{
+		{PromotionID: "happy", Name: "Happy hour", Reason: "not running at Wed 19:00"},
+		{PromotionID: "staff", Name: "Staff meal", Reason: "cannot be combined with Beer BOGO"},
+	}, price.Skipped)
+	assert.Equal(t, "33.00 USD", price.Total.String())
+
+	// An exclusive promotion that wins stops every other
+	promotions[1].Priority = 20
+	price, err = ApplyPromotions(promotions, lines, []string{"SAVE5"}, wednesday)
+	require.NoError(t, err)
+	require.Len(t, price.Discounts, 1)
+	assert.Equal(t, "19.50 USD", price.Total.String())
+	require.Len(t, price.Skipped, 3)
+	assert.Equal(t, "cannot be combined with Staff meal", price.Skipped[2].Reason)
+
+	// Conditions that are not met
+	price, err = ApplyPromotions(promotions[:1], lines[2:], []string{"SAVE5"}, wednesday)
+	require.NoError(t, err)
+	assert.Equal(t, []SkippedPromotion{
+		{PromotionID: "coupon", Name: "Five off", Code: "SAVE5", Reason: "spend at least 30.00 USD"},
+	}, price.Skipped)
+	price, err = ApplyPromotions(promotions[3:], lines[:1], nil, wednesday)
+	require.NoError(t, err)
+	assert.Equal(t, "12.00 USD", price.Total.String(), "three beers get one free")
+	price, err = ApplyPromotions(promotions[3:], lines[1:], nil, wednesday)
+	require.NoError(t, err)
+	assert.Equal(t, "no qualifying dishes in the cart", price.Skipped[0].Reason)
+
+	// Buy and get discounts count items without lining each one up
+	threeForTwo := Promotion{ID: "three", Name: "Three for two", Discount: Discount{Kind: DiscountBuyGet, Buy: 2, Get: 1}}
+	price, err = ApplyPromotions([]Promotion{threeForTwo}, []CartLine{
+		cartLine("beer", "drinks", "Beer", "6", 100000000),
+		cartLine("wine", "drinks", "Wine", "9", 100000000),
+	}, nil, wednesday)
+	require.NoError(t, err)
+	assert.Equal(t, "199999998.00 USD", price.Lines[0].Discount.String())
+	assert.Equal(t, "299999997.00 USD", price.Lines[1].Discount.String())
+	assert.Equal(t, "1000000005.00 USD", price.Total.String())
+}
+
+func TestPromotionValidate(t *testing.T) {
+	start := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := NewPromotion(PromotionParams{Code: func(s string) *string { return &s }("half off!")})
+	p.Conditions = PromotionConditions{
+		DishIDs:      []string{""},
+		Availability: []Availability{{Start: NewTimeOfDay(16, 0)}},
+		StartsAt:     &start,
+		EndsAt:       &start,
+	}
+	p.Discount = Discount{Kind: DiscountBuyGet, Percent: "150"}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Equal(t, "HALF OFF!", p.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "cannot be empty string"},
+		{Field: "code", Message: "can only contain letters, digits, dashes and underscores"},
+		{Field: "conditions.dishIds[0]", Message: "cannot be empty string"},
+		{Field: "conditions.availability[0].days", Message: "cannot be empty"},
+		{Field: "conditions.endsAt", Message: "must be after startsAt"},
+		{Field: "discount.percent", Message: "must be more than 0 and at most 100"},
+		{Field: "discount.buy", Message: "must be positive"},
+		{Field: "discount.get", Message: "must be positive"},
+	}, err.(*Error).Fields)
+
+	p = NewPromotion(PromotionParams{})
+	p.Name = "Ten off"
+	p.Discount = Discount{Kind: DiscountFixed}
+	err = p.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{{Field: "discount.amount", Message: "is required"}}, err.(*Error).Fields)
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"math/big"
+	"strings"
+)
+
+// RoundingMode decides which way amounts that fall between two minor units,
+// or between two multiples of a rounding increment, are rounded.
+type RoundingMode string
+
+const (
+	RoundHalfUp   RoundingMode = "half_up"   // to nearest, ties away from zero
+	RoundHalfEven RoundingMode = "half_even" // to nearest, ties to even
+	RoundDown     RoundingMode = "down"      // towards zero
+	RoundUp       RoundingMode = "up"        // away from zero
+)
+
+// Rounding rounds exact amounts to Money. Increment is the smallest step in
+// minor units, e.g. 5 rounds CHF to 0.05 and 100 rounds USD to whole dollars.
+// The zero value rounds half up to a single minor unit.
+type Rounding struct {
+	Mode      RoundingMode `json:"mode"`
+	Increment int64        `json:"increment"`
+}
+
+// Validate checks the mode is known and the increment is not negative
+func (r Rounding) Validate() error {
+	switch r.Mode {
+	case "", RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+	default:
+		return InvalidArgument("unknown rounding mode %q", r.Mode)
+	}
+	if r.Increment < 0 {
+		return InvalidArgument("rounding increment cannot be negative")
+	}
+	return nil
+}
+
+// Round rounds amount, an exact number of minor units of currency, to Money
+func (r Rounding) Round(amount *big.Rat, currency string) (Money, error) {
+	inc := r.Increment
+	if inc == 0 {
+		inc = 1
+	}
+
+	// Count whole increments, then round the fraction left over
+	steps := new(big.Rat).Quo(amount, new(big.Rat).SetInt64(inc))
+	n, rem := new(big.Int).QuoRem(steps.Num(), steps.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		away := false
+		switch r.Mode {
+		case RoundDown:
+		case RoundUp:
+			away = true
+		default:
+			// Compare the fraction against one half
+			twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+			switch twice.Cmp(steps.Denom()) {
+			case 1:
+				away = true
+			case 0:
+				away = r.Mode != RoundHalfEven || n.Bit(0) == 1
+			}
+		}
+		if away {
+			n.Add(n, big.NewInt(int64(rem.Sign())))
+		}
+	}
+
+	n.Mul(n, big.NewInt(inc))
+	if !n.IsInt64() {
+		return Money{}, InvalidArgument("amount %v is out of range", amount.FloatString(2))
+	}
+	return Money{Amount: n.Int64(), Currency: currency}, nil
+}
+
+// MulRat returns m times an exact factor, rounded back to Money. It is the
+// building block for conversions, percentages and tax.
+func (m Money) MulRat(factor *big.Rat, r Rounding) (Money, error) {
+	exact := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), factor)
+	return r.Round(exact, m.Currency)
+}
+
+// parsePercent parses a decimal percentage such as "8.875" into an exact
+// fraction, e.g. 0.08875. Negative, exponent and fraction forms are rejected.
+func parsePercent(s string) (*big.Rat, bool) {
+	if s == "" || strings.ContainsAny(s, "eE/") {
+		return nil, false
+	}
+	p, ok := new(big.Rat).SetString(s)
+	if !ok || p.Sign() < 0 {
+		return nil, false
+	}
+	return p.Quo(p, big.NewRat(100, 1)), true
+}
+
+// ParseRounding parses a rounding mode and an increment given as a decimal
+// amount of currency, e.g. "half_up" and "0.05" for CHF.
+func ParseRounding(mode, increment, currency string) (Rounding, error) {
+	r := Rounding{Mode: RoundingMode(mode)}
+	if increment != "" {
+		inc, err := ParseMoney(increment, currency)
+		if err != nil {
+			return r, err
+		}
+		r.Increment = inc.Amount
+	}
+	if err := r.Validate(); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// ParseRoundingRules parses per currency rounding rules written as e.g.
+// "CHF=half_up/0.05,JPY=up/10", where the increment is optional.
+func ParseRoundingRules(s string) (map[string]Rounding, error) {
+	rules := make(map[string]Rounding)
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return nil, InvalidArgument("malformed rounding rule %q", rule)
+		}
+		currency := strings.TrimSpace(parts[0])
+		if _, ok := CurrencyExponent(currency); !ok {
+			return nil, InvalidArgument("unknown currency %q", currency)
+		}
+		spec := strings.SplitN(strings.TrimSpace(parts[1]), "/", 2)
+		increment := ""
+		if len(spec) == 2 {
+			increment = spec[1]
+		}
+		r, err := ParseRounding(spec[0], increment, currency)
+		if err != nil {
+			return nil, err
+		}
+		rules[currency] = r
+	}
+	return rules, nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundingRound(t *testing.T) {
+	testcases := []struct {
+		amount   string
+		rounding Rounding
+		expected int64
+	}{
+		{"12.5", Rounding{}, 13},
+		{"-12.5", Rounding{}, -13},
+		{"12.5", Rounding{Mode: RoundHalfEven}, 12},
+		{"13.5", Rounding{Mode: RoundHalfEven}, 14},
+		{"12.01", Rounding{Mode: RoundUp}, 13},
+		{"12.99", Rounding{Mode: RoundDown}, 12},
+		{"-12.99", Rounding{Mode: RoundDown}, -12},
+		{"1124.9", Rounding{Increment: 5}, 1125},
+		{"1122.4", Rounding{Increment: 5}, 1120},
+		{"1150", Rounding{Mode: RoundHalfEven, Increment: 100}, 1200},
+		{"1250", Rounding{Mode: RoundHalfEven, Increment: 100}, 1200},
+		{"1201", Rounding{Mode: RoundUp, Increment: 100}, 1300},
+	}
+	for _, tc := range testcases {
+		amount, _ := new(big.Rat).SetString(tc.amount)
+		m, err := tc.rounding.Round(amount, "USD")
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, m.Amount, "%v %+v", tc.amount, tc.rounding)
+	}
+}
+
+func TestParseRoundingRules(t *testing.T) {
+	rules, err := ParseRoundingRules("CHF=half_up/0.05, JPY=up/10,EUR=half_even")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Rounding{
+		"CHF": {Mode: RoundHalfUp, Increment: 5},
+		"JPY": {Mode: RoundUp, Increment: 10},
+		"EUR": {Mode: RoundHalfEven},
+	}, rules)
+
+	rules, err = ParseRoundingRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, s := range []string{"CHF", "CHF=sideways", "CHF=up/0.001", "XXX=up"} {
+		_, err := ParseRoundingRules(s)
+		assert.Error(t, err, s)
+	}
+}
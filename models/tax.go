@@ -0,0 +1,394 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// TaxCategory is how a dish is taxed, jurisdictions tax e.g. alcohol at a
+// higher rate and exempt packaged food
+type TaxCategory string
+
+const (
+	TaxPreparedFood TaxCategory = "prepared_food"
+	TaxPackagedFood TaxCategory = "packaged_food"
+	TaxBeverage     TaxCategory = "beverage"
+	TaxAlcohol      TaxCategory = "alcohol"
+)
+
+// DefaultTaxCategory is assumed for dishes without a tax category
+const DefaultTaxCategory = TaxPreparedFood
+
+// TaxCategories lists every tax category
+var TaxCategories = []TaxCategory{TaxPreparedFood, TaxPackagedFood, TaxBeverage, TaxAlcohol}
+
+// Valid reports if the category is one of TaxCategories
+func (c TaxCategory) Valid() bool {
+	for _, known := range TaxCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// TaxedAs returns the dish's tax category, DefaultTaxCategory if it has none
+func (d Dish) TaxedAs() TaxCategory {
+	if d.TaxCategory == "" {
+		return DefaultTaxCategory
+	}
+	return d.TaxCategory
+}
+
+// TaxRate is one of the taxes levied in a jurisdiction, e.g. a state sales
+// tax and a city sales tax on top of it
+type TaxRate struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Percent is a decimal percentage such as "8.875". It is a string so
+	// that it is exact.
+	Percent string `json:"percent"`
+
+	// Categories lists the tax categories the rate applies to, every
+	// category if empty
+	Categories []TaxCategory `json:"categories,omitempty"`
+}
+
+// ratio returns the rate as an exact fraction, e.g. 0.08875
+func (r TaxRate) ratio() (*big.Rat, bool) {
+	return parsePercent(r.Percent)
+}
+
+// AppliesTo reports if the rate is levied on the category
+func (r TaxRate) AppliesTo(c TaxCategory) bool {
+	if len(r.Categories) == 0 {
+		return true
+	}
+	for _, known := range r.Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// TaxRoundingLevel is where tax is rounded to whole minor units
+type TaxRoundingLevel string
+
+const (
+	// TaxRoundPerLine rounds the tax of every line, the order pays their sum
+	TaxRoundPerLine TaxRoundingLevel = "per_line"
+
+	// TaxRoundPerOrder sums the exact tax of every line and rounds once per
+	// rate. Lines are then itemized so their tax adds up to the rounded
+	// total.
+	TaxRoundPerOrder TaxRoundingLevel = "per_order"
+)
+
+// Jurisdiction is a place with its own taxes, such as a city
+type Jurisdiction struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Rates are levied on top of each other, each on the price before tax
+	Rates []TaxRate `json:"rates"`
+
+	// Inclusive jurisdictions, like most of Europe, include tax in menu
+	// prices. Elsewhere tax is added to them.
+	Inclusive bool `json:"inclusive"`
+
+	// RoundPer is per_line or per_order, per_order by default
+	RoundPer TaxRoundingLevel `json:"roundPer,omitempty"`
+
+	// Rounding is how amounts are rounded to minor units, half_up by
+	// default
+	Rounding RoundingMode `json:"rounding,omitempty"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// Validate returns an invalid argument error listing every bad field
+func (j Jurisdiction) Validate() error {
+	err := InvalidArgument("invalid jurisdiction")
+	if j.ID == "" {
+		err = err.WithField("id", "cannot be empty string")
+	}
+	if j.Name == "" {
+		err = err.WithField("name", "cannot be empty string")
+	}
+	seen := make(map[string]bool)
+	for i, r := range j.Rates {
+		field := fmt.Sprintf("rates[%d]", i)
+		switch {
+		case r.ID == "":
+			err = err.WithField(field+".id", "cannot be empty string")
+		case seen[r.ID]:
+			err = err.WithField(field+".id", fmt.Sprintf("duplicate rate %q", r.ID))
+		}
+		seen[r.ID] = true
+		if ratio, ok := r.ratio(); !ok {
+			err = err.WithField(field+".percent", "must be a non-negative decimal number")
+		} else if ratio.Cmp(big.NewRat(1, 1)) > 0 {
+			err = err.WithField(field+".percent", "cannot be more than 100")
+		}
+		for k, c := range r.Categories {
+			if !c.Valid() {
+				err = err.WithField(fmt.Sprintf("%v.categories[%d]", field, k), fmt.Sprintf("unknown tax category %q", c))
+			}
+		}
+	}
+	switch j.RoundPer {
+	case "", TaxRoundPerLine, TaxRoundPerOrder:
+	default:
+		err = err.WithField("roundPer", "must be per_line or per_order")
+	}
+	if r := (Rounding{Mode: j.Rounding}); r.Validate() != nil {
+		err = err.WithField("rounding", fmt.Sprintf("unknown rounding mode %q", j.Rounding))
+	}
+	if len(err.Fields) > 0 {
+		return err
+	}
+	return nil
+}
+
+// TaxableLine is an amount to tax, e.g. the total of an order line. It is a
+// gross amount in inclusive jurisdictions and a net amount otherwise.
+type TaxableLine struct {
+	Category TaxCategory `json:"category"`
+	Amount   Money       `json:"amount"`
+}
+
+// TaxAmount is the tax levied at one rate
+type TaxAmount struct {
+	RateID  string `json:"rateId"`
+	Name    string `json:"name"`
+	Percent string `json:"percent"`
+
+	// Taxable is the net amount the rate was levied on
+	Taxable Money `json:"taxable"`
+	Amount  Money `json:"amount"`
+}
+
+// LineTax itemizes the tax on one line
+type LineTax struct {
+	Category TaxCategory `json:"category"`
+	Net      Money       `json:"net"`
+	Tax      Money       `json:"tax"`
+	Gross    Money       `json:"gross"`
+
+	// Taxes lists the rates levied on the line, in the jurisdiction's order
+	Taxes []TaxAmount `json:"taxes,omitempty"`
+}
+
+// TaxBreakdown itemizes the tax on a set of lines, such as an order, per line
+// and per rate. Net plus Tax is always Gross, and the lines and rates add up
+// to the totals exactly.
+type TaxBreakdown struct {
+	JurisdictionID string    `json:"jurisdictionId"`
+	Inclusive      bool      `json:"inclusive"`
+	Lines          []LineTax `json:"lines"`
+
+	// Rates totals every rate levied on any line, for receipts and reports
+	Rates []TaxAmount `json:"rates"`
+
+	Net   Money `json:"net"`
+	Tax   Money `json:"tax"`
+	Gross Money `json:"gross"`
+}
+
+// CalculateTax levies the jurisdiction's taxes on the lines. Lines without a
+// category are taxed as DefaultTaxCategory, and every line must be in the
+// same currency.
+func (j Jurisdiction) CalculateTax(lines []TaxableLine) (*TaxBreakdown, error) {
+	bad := InvalidArgument("invalid taxable lines")
+	if len(lines) == 0 {
+		bad = bad.WithField("lines", "cannot be empty")
+	}
+	currency := ""
+	for i, l := range lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		if l.Category != "" && !l.Category.Valid() {
+			bad = bad.WithField(field+".category", fmt.Sprintf("unknown tax category %q", l.Category))
+		}
+		if currency == "" {
+			currency = l.Amount.Currency
+		}
+		switch msg := l.Amount.invalid(); {
+		case msg != "":
+			bad = bad.WithField(field+".amount", msg)
+		case l.Amount.Currency != currency:
+			bad = bad.WithField(field+".amount", "must be in "+currency)
+		}
+	}
+	if len(bad.Fields) > 0 {
+		return nil, bad
+	}
+
+	// Work out the exact tax of every line at every rate. Inclusive amounts
+	// are first split into the net amount and the tax on it.
+	rounding := Rounding{Mode: j.Rounding}
+	ratios := make([]*big.Rat, len(j.Rates))
+	for i, r := range j.Rates {
+		ratio, ok := r.ratio()
+		if !ok {
+			return nil, InvalidArgument("invalid tax rate %q", r.ID)
+		}
+		ratios[i] = ratio
+	}
+	exact := make([][]*big.Rat, len(lines))
+	categories := make([]TaxCategory, len(lines))
+	for l, line := range lines {
+		category := line.Category
+		if category == "" {
+			category = DefaultTaxCategory
+		}
+		categories[l] = category
+		combined := new(big.Rat)
+		for i, r := range j.Rates {
+			if r.AppliesTo(category) {
+				combined.Add(combined, ratios[i])
+			}
+		}
+		net := new(big.Rat).SetInt64(line.Amount.Amount)
+		if j.Inclusive {
+			net.Quo(net, combined.Add(combined, big.NewRat(1, 1)))
+		}
+		exact[l] = make([]*big.Rat, len(j.Rates))
+		for i, r := range j.Rates {
+			if r.AppliesTo(category) {
+				exact[l][i] = new(big.Rat).Mul(net, ratios[i])
+			}
+		}
+	}
+
+	// Round the tax, per line or once per rate
+	taxes := make([][]Money, len(lines))
+	for l := range lines {
+		taxes[l] = make([]Money, len(j.Rates))
+	}
+	for i := range j.Rates {
+		var levied []int
+		sum := new(big.Rat)
+		for l := range lines {
+			if exact[l][i] == nil {
+				continue
+			}
+			levied = append(levied, l)
+			sum.Add(sum, exact[l][i])
+			if j.RoundPer == TaxRoundPerLine {
+				tax, err := rounding.Round(exact[l][i], currency)
+				if err != nil {
+					return nil, err
+				}
+				taxes[l][i] = tax
+			}
+		}
+		if j.RoundPer == TaxRoundPerLine || len(levied) == 0 {
+			continue
+		}
+		total, err := rounding.Round(sum, currency)
+		if err != nil {
+			return nil, err
+		}
+		shares := make([]*big.Rat, len(levied))
+		for k, l := range levied {
+			shares[k] = exact[l][i]
+		}
+		for k, amount := range apportion(total.Amount, shares) {
+			taxes[levied[k]][i] = Money{Amount: amount, Currency: currency}
+		}
+	}
+
+	// Itemize
+	zero := Money{Currency: currency}
+	b := &TaxBreakdown{
+		JurisdictionID: j.ID,
+		Inclusive:      j.Inclusive,
+		Lines:          make([]LineTax, len(lines)),
+		Net:            zero,
+		Tax:            zero,
+		Gross:          zero,
+	}
+	rates := make([]*TaxAmount, len(j.Rates))
+	for l, line := range lines {
+		lt := LineTax{Category: categories[l], Tax: zero}
+		for i := range j.Rates {
+			if exact[l][i] != nil {
+				lt.Tax.Amount += taxes[l][i].Amount
+			}
+		}
+		if j.Inclusive {
+			lt.Gross = line.Amount
+			lt.Net = Money{Amount: line.Amount.Amount - lt.Tax.Amount, Currency: currency}
+		} else {
+			lt.Net = line.Amount
+			lt.Gross = Money{Amount: line.Amount.Amount + lt.Tax.Amount, Currency: currency}
+		}
+		for i, r := range j.Rates {
+			if exact[l][i] == nil {
+				continue
+			}
+			lt.Taxes = append(lt.Taxes, TaxAmount{
+				RateID:  r.ID,
+				Name:    r.Name,
+				Percent: r.Percent,
+				Taxable: lt.Net,
+				Amount:  taxes[l][i],
+			})
+			if rates[i] == nil {
+				rates[i] = &TaxAmount{RateID: r.ID, Name: r.Name, Percent: r.Percent, Taxable: zero, Amount: zero}
+			}
+			rates[i].Taxable.Amount += lt.Net.Amount
+			rates[i].Amount.Amount += taxes[l][i].Amount
+		}
+		b.Lines[l] = lt
+		b.Net.Amount += lt.Net.Amount
+		b.Tax.Amount += lt.Tax.Amount
+		b.Gross.Amount += lt.Gross.Amount
+	}
+	b.Rates = make([]TaxAmount, 0, len(rates))
+	for _, r := range rates {
+		if r != nil {
+			b.Rates = append(b.Rates, *r)
+		}
+	}
+	return b, nil
+}
+
+// apportion splits total minor units between shares in proportion to their
+// exact amounts. Every share gets its exact amount rounded down, and the
+// minor units left over go to the shares with the largest fractions, so
+// the parts always add up to total.
+func apportion(total int64, shares []*big.Rat) []int64 {
+	parts := make([]int64, len(shares))
+	fractions := make([]*big.Rat, len(shares))
+	left := total
+	for i, s := range shares {
+		whole, rem := new(big.Int).QuoRem(s.Num(), s.Denom(), new(big.Int))
+		if rem.Sign() < 0 {
+			whole.Sub(whole, big.NewInt(1))
+		}
+		parts[i] = whole.Int64()
+		fractions[i] = new(big.Rat).Sub(s, new(big.Rat).SetInt(whole))
+		left -= parts[i]
+	}
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return fractions[order[a]].Cmp(fractions[order[b]]) > 0
+	})
+	for k := 0; left > 0 && len(order) > 0; k = (k + 1) % len(order) {
+		parts[order[k]]++
+		left--
+	}
+	for k := len(order) - 1; left < 0 && len(order) > 0; k = (k + len(order) - 1) % len(order) {
+		parts[order[k]]--
+		left++
+	}
+	return parts
+}
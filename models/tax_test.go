@@ -0,0 +1,159 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func usd(amount string) Money { return MustParseMoney(amount, "USD") }
+
+func TestCalculateTaxExclusive(t *testing.T) {
+	j := Jurisdiction{
+		ID:   "springfield",
+		Name: "Springfield",
+		Rates: []TaxRate{
+			{ID: "state", Name: "State sales tax", Percent: "6.25", Categories: []TaxCategory{TaxPreparedFood, TaxBeverage, TaxAlcohol}},
+			{ID: "city", Name: "City sales tax", Percent: "2"},
+			{ID: "liquor", Name: "Liquor tax", Percent: "3", Categories: []TaxCategory{TaxAlcohol}},
+		},
+		RoundPer: TaxRoundPerLine,
+	}
+	require.NoError(t, j.Validate())
+	b, err := j.CalculateTax([]TaxableLine{
+		{Amount: usd("10.05")},
+		{Category: TaxAlcohol, Amount: usd("7.99")},
+		{Category: TaxPackagedFood, Amount: usd("3.33")},
+	})
+	require.NoError(t, err)
+
+	// Lines
+	require.Len(t, b.Lines, 3)
+	assert.Equal(t, TaxPreparedFood, b.Lines[0].Category)
+	assert.Equal(t, "0.83 USD", b.Lines[0].Tax.String())
+	assert.Equal(t, "10.88 USD", b.Lines[0].Gross.String())
+	assert.Len(t, b.Lines[1].Taxes, 3)
+	assert.Equal(t, "0.90 USD", b.Lines[1].Tax.String())
+	require.Len(t, b.Lines[2].Taxes, 1)
+	assert.Equal(t, "city", b.Lines[2].Taxes[0].RateID)
+	assert.Equal(t, "0.07 USD", b.Lines[2].Tax.String())
+
+	// Rates
+	require.Len(t, b.Rates, 3)
+	assert.Equal(t, TaxAmount{RateID: "state", Name: "State sales tax", Percent: "6.25", Taxable: usd("18.04"), Amount: usd("1.13")}, b.Rates[0])
+	assert.Equal(t, TaxAmount{RateID: "city", Name: "City sales tax", Percent: "2", Taxable: usd("21.37"), Amount: usd("0.43")}, b.Rates[1])
+	assert.Equal(t, TaxAmount{RateID: "liquor", Name: "Liquor tax", Percent: "3", Taxable: usd("7.99"), Amount: usd("0.24")}, b.Rates[2])
+
+	// Totals
+	assert.False(t, b.Inclusive)
+	assert.Equal(t, "21.37 USD", b.Net.String())
+	assert.Equal(t, "1.80 USD", b.Tax.String())
+	assert.Equal(t, "23.17 USD", b.Gross.String())
+}
+
+func TestCalculateTaxRoundingLevel(t *testing.T) {
+	j := Jurisdiction{ID: "x", Name: "X", Rates: []TaxRate{{ID: "sales", Percent: "10"}}}
+	lines := []TaxableLine{{Amount: usd("0.05")}, {Amount: usd("0.05")}, {Amount: usd("0.05")}}
+
+	// Half a cent on every line rounds up three times
+	j.RoundPer = TaxRoundPerLine
+	b, err := j.CalculateTax(lines)
+	require.NoError(t, err)
+	assert.Equal(t, "0.03 USD", b.Tax.String())
+
+	// but only once on the order, which the lines then add up to
+	j.RoundPer = TaxRoundPerOrder
+	b, err = j.CalculateTax(lines)
+	require.NoError(t, err)
+	assert.Equal(t, "0.02 USD", b.Tax.String())
+	assert.Equal(t, "0.02 USD", b.Rates[0].Amount.String())
+	assert.Equal(t, []int64{1, 1, 0}, []int64{b.Lines[0].Tax.Amount, b.Lines[1].Tax.Amount, b.Lines[2].Tax.Amount})
+	assert.Equal(t, "0.17 USD", b.Gross.String())
+}
+
+func TestCalculateTaxInclusive(t *testing.T) {
+	j := Jurisdiction{
+		ID:   "fr",
+		Name: "France",
+		Rates: []TaxRate{
+			{ID: "vat", Name: "TVA", Percent: "20", Categories: []TaxCategory{TaxPreparedFood, TaxBeverage, TaxAlcohol}},
+			{ID: "vat-reduced", Name: "TVA réduite", Percent: "5.5", Categories: []TaxCategory{TaxPackagedFood}},
+		},
+		Inclusive: true,
+		RoundPer:  TaxRoundPerLine,
+	}
+	eur := func(amount string) Money { return MustParseMoney(amount, "EUR") }
+	lines := []TaxableLine{
+		{Category: TaxPreparedFood, Amount: eur("12")},
+		{Category: TaxPackagedFood, Amount: eur("2.11")},
+		{Category: TaxPreparedFood, Amount: eur("9.99")},
+	}
+	b, err := j.CalculateTax(lines)
+	require.NoError(t, err)
+	assert.True(t, b.Inclusive)
+	assert.Equal(t, LineTax{
+		Category: TaxPreparedFood,
+		Net:      eur("10"),
+		Tax:      eur("2"),
+		Gross:    eur("12"),
+		Taxes:    []TaxAmount{{RateID: "vat", Name: "TVA", Percent: "20", Taxable: eur("10"), Amount: eur("2")}},
+	}, b.Lines[0])
+	assert.Equal(t, "0.11 EUR", b.Lines[1].Tax.String())
+	assert.Equal(t, "1.67 EUR", b.Lines[2].Tax.String())
+	assert.Equal(t, "8.32 EUR", b.Lines[2].Net.String())
+	assert.Equal(t, "24.10 EUR", b.Gross.String())
+	assert.Equal(t, "3.78 EUR", b.Tax.String())
+	assert.Equal(t, "20.32 EUR", b.Net.String())
+
+	// The tie on the last line goes to the even cent
+	j.Rounding = RoundHalfEven
+	b, err = j.CalculateTax(lines)
+	require.NoError(t, err)
+	assert.Equal(t, "1.66 EUR", b.Lines[2].Tax.String())
+	assert.Equal(t, "24.10 EUR", b.Gross.String())
+}
+
+func TestCalculateTaxErrors(t *testing.T) {
+	j := Jurisdiction{ID: "x", Name: "X", Rates: []TaxRate{{ID: "sales", Percent: "10"}}}
+	_, err := j.CalculateTax(nil)
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{{Field: "lines", Message: "cannot be empty"}}, err.(*Error).Fields)
+	_, err = j.CalculateTax([]TaxableLine{
+		{Category: "tobacco", Amount: usd("1")},
+		{Amount: MustParseMoney("1", "EUR")},
+		{Amount: usd("-1")},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "lines[0].category", Message: `unknown tax category "tobacco"`},
+		{Field: "lines[1].amount", Message: "must be in USD"},
+		{Field: "lines[2].amount", Message: "cannot be negative"},
+	}, err.(*Error).Fields)
+}
+
+func TestJurisdictionValidate(t *testing.T) {
+	j := Jurisdiction{
+		ID: "x",
+		Rates: []TaxRate{
+			{ID: "a", Percent: "abc"},
+			{ID: "a", Percent: "101", Categories: []TaxCategory{"tobacco"}},
+			{Percent: "1e2"},
+		},
+		RoundPer: "never",
+		Rounding: "sideways",
+	}
+	err := j.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "cannot be empty string"},
+		{Field: "rates[0].percent", Message: "must be a non-negative decimal number"},
+		{Field: "rates[1].id", Message: `duplicate rate "a"`},
+		{Field: "rates[1].percent", Message: "cannot be more than 100"},
+		{Field: "rates[1].categories[0]", Message: `unknown tax category "tobacco"`},
+		{Field: "rates[2].id", Message: "cannot be empty string"},
+		{Field: "rates[2].percent", Message: "must be a non-negative decimal number"},
+		{Field: "roundPer", Message: "must be per_line or per_order"},
+		{Field: "rounding", Message: `unknown rounding mode "sideways"`},
+	}, err.(*Error).Fields)
+}
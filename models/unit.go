@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Unit is a unit of measure for ingredients
+type Unit string
+
+const (
+	UnitGram     Unit = "g"
+	UnitKilogram Unit = "kg"
+	UnitOunce    Unit = "oz"
+	UnitPound    Unit = "lb"
+
+	UnitMilliliter Unit = "ml"
+	UnitLiter      Unit = "l"
+	UnitCup        Unit = "cup"
+
+	// UnitEach counts whole items, e.g. eggs
+	UnitEach Unit = "each"
+)
+
+// Dimension is what a unit measures, quantities only convert between units
+// of the same dimension
+type Dimension string
+
+const (
+	DimensionMass   Dimension = "mass"
+	DimensionVolume Dimension = "volume"
+	DimensionCount  Dimension = "count"
+)
+
+type unitInfo struct {
+	dimension Dimension
+
+	// base is the size of the unit in grams, milliliters or items
+	base *big.Rat
+}
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("invalid rational " + s)
+	}
+	return r
+}
+
+// units holds exact conversion factors. Ounces and pounds are avoirdupois,
+// cups are US customary cups.
+var units = map[Unit]unitInfo{
+	UnitGram:       {DimensionMass, rat("1")},
+	UnitKilogram:   {DimensionMass, rat("1000")},
+	UnitOunce:      {DimensionMass, rat("28.349523125")},
+	UnitPound:      {DimensionMass, rat("453.59237")},
+	UnitMilliliter: {DimensionVolume, rat("1")},
+	UnitLiter:      {DimensionVolume, rat("1000")},
+	UnitCup:        {DimensionVolume, rat("236.5882365")},
+	UnitEach:       {DimensionCount, rat("1")},
+}
+
+// baseUnits are the units of each dimension every other unit is sized in
+var baseUnits = map[Dimension]Unit{
+	DimensionMass:   UnitGram,
+	DimensionVolume: UnitMilliliter,
+	DimensionCount:  UnitEach,
+}
+
+// Valid reports if the unit is supported
+func (u Unit) Valid() bool {
+	_, ok := units[u]
+	return ok
+}
+
+// Dimension returns what the unit measures
+func (u Unit) Dimension() Dimension {
+	return units[u].dimension
+}
+
+// Quantity is an amount of some unit, e.g. 150 g
+type Quantity struct {
+	Amount float64 `json:"amount"`
+	Unit   Unit    `json:"unit"`
+}
+
+func (q Quantity) String() string {
+	return fmt.Sprintf("%v %v", q.Amount, q.Unit)
+}
+
+// Ratio returns the exact amount of the unit the quantity makes up. It fails
+// with an invalid argument error if the units measure different dimensions.
+func (q Quantity) Ratio(to Unit) (*big.Rat, error) {
+	from, ok := units[q.Unit]
+	if !ok {
+		return nil, InvalidArgument("unknown unit %q", q.Unit)
+	}
+	target, ok := units[to]
+	if !ok {
+		return nil, InvalidArgument("unknown unit %q", to)
+	}
+	if from.dimension != target.dimension {
+		return nil, InvalidArgument("cannot convert %v to %v", q.Unit, to)
+	}
+	amount := new(big.Rat).SetFloat64(q.Amount)
+	if amount == nil {
+		return nil, InvalidArgument("invalid amount %v", q.Amount)
+	}
+	amount.Mul(amount, from.base)
+	return amount.Quo(amount, target.base), nil
+}
+
+// Convert returns the quantity in another unit of the same dimension
+func (q Quantity) Convert(to Unit) (Quantity, error) {
+	ratio, err := q.Ratio(to)
+	if err != nil {
+		return Quantity{}, err
+	}
+	amount, _ := ratio.Float64()
+	return Quantity{Amount: amount, Unit: to}, nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuantityConvert(t *testing.T) {
+	testcases := []struct {
+		from     Quantity
+		to       Unit
+		expected float64
+	}{
+		{Quantity{1500, UnitGram}, UnitKilogram, 1.5},
+		{Quantity{1, UnitPound}, UnitOunce, 16},
+		{Quantity{8, UnitOunce}, UnitGram, 226.796185},
+		{Quantity{2, UnitCup}, UnitMilliliter, 473.176473},
+		{Quantity{0.25, UnitLiter}, UnitMilliliter, 250},
+		{Quantity{3, UnitEach}, UnitEach, 3},
+	}
+	for _, tc := range testcases {
+		q, err := tc.from.Convert(tc.to)
+		require.NoError(t, err, tc.from.String())
+		assert.InDelta(t, tc.expected, q.Amount, 1e-9, tc.from.String())
+		assert.Equal(t, tc.to, q.Unit)
+	}
+
+	_, err := Quantity{1, UnitCup}.Convert(UnitGram)
+	assert.Equal(t, KindInvalidArgument, KindOf(err))
+	_, err = Quantity{1, "pinch"}.Convert(UnitGram)
+	assert.Equal(t, KindInvalidArgument, KindOf(err))
+}
+
+func TestDishCosting(t *testing.T) {
+	flour := NewIngredient(IngredientParams{})
+	flour.Name, flour.Cost, flour.Unit = "Flour", MustParseMoney("1.20", "USD"), UnitKilogram
+	require.NoError(t, flour.Validate())
+
+	cost, err := flour.CostOf(Quantity{250, UnitGram})
+	require.NoError(t, err)
+	assert.Equal(t, "0.30 USD", cost.String())
+	cost, err = flour.CostOf(Quantity{1, UnitOunce})
+	require.NoError(t, err)
+	assert.Equal(t, "0.03 USD", cost.String())
+
+	d := Dish{Price: MustParseMoney("8.00", "USD")}
+	costing, err := NewDishCosting(d, []CostLine{
+		{Cost: MustParseMoney("0.30", "USD")},
+		{Cost: MustParseMoney("2.15", "USD")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2.45 USD", costing.Cost.String())
+	assert.Equal(t, "5.55 USD", costing.Margin.String())
+	assert.Equal(t, 69.4, costing.MarginPercent)
+}
+
+func TestDishValidateRecipe(t *testing.T) {
+	d := NewDish(DishParams{})
+	d.Name, d.Price = "Bread", MustParseMoney("4", "USD")
+	d.Recipe = []RecipeLine{
+		{IngredientID: "flour", Quantity: Quantity{500, UnitGram}},
+		{IngredientID: "flour", Quantity: Quantity{1, UnitCup}},
+		{IngredientID: "salt", Quantity: Quantity{0, UnitGram}},
+		{IngredientID: "", Quantity: Quantity{1, "pinch"}},
+	}
+	err := d.Validate()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "recipe[1].ingredientId", Message: "duplicate ingredient"},
+		{Field: "recipe[2].quantity.amount", Message: "must be positive"},
+		{Field: "recipe[3].ingredientId", Message: "cannot be empty string"},
+		{Field: "recipe[3].quantity.unit", Message: `unknown unit "pinch"`},
+	}, err.(*Error).Fields)
+}
@@ -0,0 +1,201 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package orders
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the order endpoints, see dishes.Endpoints
+type Endpoints struct {
+	PlaceOrderEndpoint      endpoint.Endpoint
+	GetOrderEndpoint        endpoint.Endpoint
+	ListOrdersEndpoint      endpoint.Endpoint
+	TransitionOrderEndpoint endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in an orders
+// server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		PlaceOrderEndpoint:      MakePlaceOrderEndpoint(s),
+		GetOrderEndpoint:        MakeGetOrderEndpoint(s),
+		ListOrdersEndpoint:      MakeListOrdersEndpoint(s),
+		TransitionOrderEndpoint: MakeTransitionOrderEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "PlaceOrder".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		PlaceOrderEndpoint:      mw("PlaceOrder")(e.PlaceOrderEndpoint),
+		GetOrderEndpoint:        mw("GetOrder")(e.GetOrderEndpoint),
+		ListOrdersEndpoint:      mw("ListOrders")(e.ListOrdersEndpoint),
+		TransitionOrderEndpoint: mw("TransitionOrder")(e.TransitionOrderEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service. Orders
+// are never deleted, cancelling one is a transition.
+var Policy = auth.Policy{
+	"GetOrder":        auth.RoleViewer,
+	"ListOrders":      auth.RoleViewer,
+	"PlaceOrder":      auth.RoleEditor,
+	"TransitionOrder": auth.RoleEditor,
+}
+
+// PlaceOrder implements Service. Primarily useful in a client.
+func (e Endpoints) PlaceOrder(ctx context.Context, p models.OrderParams) (*models.Order, error) {
+	response, err := e.PlaceOrderEndpoint(ctx, placeOrderRequest{OrderParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(placeOrderResponse)
+	return resp.Order, resp.Err
+}
+
+// GetOrder implements Service. Primarily useful in a client.
+func (e Endpoints) GetOrder(ctx context.Context, id string) (*models.Order, error) {
+	response, err := e.GetOrderEndpoint(ctx, getOrderRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(orderResponse)
+	return resp.Order, resp.Err
+}
+
+// ListOrders implements Service. Primarily useful in a client.
+func (e Endpoints) ListOrders(ctx context.Context, status models.OrderStatus) ([]models.Order, error) {
+	response, err := e.ListOrdersEndpoint(ctx, listOrdersRequest{Status: status})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listOrdersResponse)
+	return resp.Orders, resp.Err
+}
+
+// TransitionOrder implements Service. Primarily useful in a client.
+func (e Endpoints) TransitionOrder(ctx context.Context, id string, status models.OrderStatus, version int64) (*models.Order, error) {
+	response, err := e.TransitionOrderEndpoint(ctx, transitionOrderRequest{ID: id, Status: status, Version: version})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(orderResponse)
+	return resp.Order, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+// etagHeader exposes the order version as a strong entity tag
+func etagHeader(o *models.Order) http.Header {
+	if o == nil {
+		return http.Header{}
+	}
+	return etag.Header(o.Version)
+}
+
+type placeOrderRequest struct {
+	models.OrderParams
+}
+
+type placeOrderResponse struct {
+	*models.Order
+	Err error `json:"-"`
+}
+
+func (r placeOrderResponse) error() error { return r.Err }
+
+func (r placeOrderResponse) Headers() http.Header { return etagHeader(r.Order) }
+
+// StatusCode reports 201 since a new order was placed
+func (r placeOrderResponse) StatusCode() int { return http.StatusCreated }
+
+func MakePlaceOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(placeOrderRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		order, err := s.PlaceOrder(ctx, req.OrderParams)
+		resp := placeOrderResponse{Order: order, Err: err}
+		return resp, nil
+	}
+}
+
+type getOrderRequest struct {
+	ID string `json:"id"`
+}
+
+// orderResponse carries a single existing order
+type orderResponse struct {
+	*models.Order
+	Err error `json:"-"`
+}
+
+func (r orderResponse) error() error { return r.Err }
+
+func (r orderResponse) Headers() http.Header { return etagHeader(r.Order) }
+
+func MakeGetOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(getOrderRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		order, err := s.GetOrder(ctx, req.ID)
+		resp := orderResponse{Order: order, Err: err}
+		return resp, nil
+	}
+}
+
+type listOrdersRequest struct {
+	Status models.OrderStatus `json:"status"`
+}
+
+type listOrdersResponse struct {
+	Orders []models.Order `json:"orders"`
+	Err    error          `json:"-"`
+}
+
+func (r listOrdersResponse) error() error { return r.Err }
+
+func MakeListOrdersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(listOrdersRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		orders, err := s.ListOrders(ctx, req.Status)
+		resp := listOrdersResponse{Orders: orders, Err: err}
+		return resp, nil
+	}
+}
+
+type transitionOrderRequest struct {
+	ID      string             `json:"id"`
+	Status  models.OrderStatus `json:"status"`
+	Version int64              `json:"version"`
+}
+
+func MakeTransitionOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(transitionOrderRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		order, err := s.TransitionOrder(ctx, req.ID, req.Status, req.Version)
+		resp := orderResponse{Order: order, Err: err}
+		return resp, nil
+	}
+}
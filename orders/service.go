@@ -0,0 +1,276 @@
+// Service implements the business logic for orders
+package orders
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service places orders for dishes and moves them through their lifecycle,
+// placed, accepted, preparing, ready and then completed, or cancelled at any
+// point before completion. Every operation acts for the tenant carried by
+// the context, see tenant.NewContext, and never sees the orders of other
+// tenants.
+type Service interface {
+
+	// PlaceOrder prices every line from the current dish, snapshotting its
+	// name, price and tax category, taxes the order and takes what it uses
+	// out of stock. It fails with a conflict error if a dish cannot be
+	// ordered right now, or there is not enough stock left.
+	PlaceOrder(ctx context.Context, p models.OrderParams) (*models.Order, error)
+
+	GetOrder(ctx context.Context, id string) (*models.Order, error)
+
+	// ListOrders returns the orders in status, or every order if status is
+	// empty, oldest first
+	ListOrders(ctx context.Context, status models.OrderStatus) ([]models.Order, error)
+
+	// TransitionOrder moves an order into a status. It fails with a conflict
+	// error if the order cannot move there from its current status, or is
+	// no longer at the expected version, see the dishes service.
+	TransitionOrder(ctx context.Context, id string, status models.OrderStatus, version int64) (*models.Order, error)
+}
+
+// Dishes looks up the dishes orders are for, it is implemented by
+// dishes.Service
+type Dishes interface {
+	GetDish(ctx context.Context, id string, currency string) (*models.Dish, error)
+}
+
+// Inventory takes what orders use out of stock, it is implemented by
+// inventory.Service
+type Inventory interface {
+	Deplete(ctx context.Context, usage []models.StockUsage) error
+}
+
+// Taxes looks up the tax rules orders are taxed by, it is implemented by
+// taxes.Service
+type Taxes interface {
+	GetJurisdiction(ctx context.Context, id string) (*models.Jurisdiction, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithInventory depletes stock as orders are placed. Without an inventory
+// stock is not tracked.
+func WithInventory(i Inventory) Option {
+	return func(r *resource) { r.inventory = i }
+}
+
+// WithTaxes taxes orders by the rules of the jurisdiction, as they are when
+// each order is placed. Every tenant sets up its own rules for the
+// jurisdiction. Without taxes orders are not taxed.
+func WithTaxes(t Taxes, jurisdictionID string) Option {
+	return func(r *resource) {
+		r.taxes = t
+		r.jurisdictionID = jurisdictionID
+	}
+}
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(dishes Dishes, opts ...Option) Service {
+	r := &resource{
+		local:  make(map[key]models.Order),
+		mu:     &sync.RWMutex{},
+		dishes: dishes,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// key identifies an order, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	local   map[key]models.Order
+	mu      *sync.RWMutex
+	tenants tenant.Registry
+
+	dishes    Dishes
+	inventory Inventory
+
+	taxes          Taxes
+	jurisdictionID string
+}
+
+func (r *resource) PlaceOrder(ctx context.Context, p models.OrderParams) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Price every line from the current dishes
+	bad := models.InvalidArgument("invalid order")
+	lines := make([]models.OrderLine, 0, len(p.Lines))
+	var usage []models.StockUsage
+	for i, l := range p.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		dish, err := r.dishes.GetDish(ctx, l.DishID, "")
+		if models.KindOf(err) == models.KindNotFound {
+			bad = bad.WithField(field+".dishId", "unknown dish")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if a := dish.Availability; a != nil && !a.Available {
+			return nil, models.Conflict("%v cannot be ordered right now", dish.Name).
+				WithField(field+".dishId", fmt.Sprintf("unavailable, %v", a.Reason))
+		}
+		price, err := dish.CalculatePrice(l.Configuration)
+		if e, ok := err.(*models.Error); ok && e.Kind == models.KindInvalidArgument {
+			for _, f := range e.Fields {
+				bad = bad.WithField(field+"."+f.Field, f.Message)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, models.OrderLine{
+			DishID:      dish.ID,
+			Name:        dish.Name,
+			Price:       *price,
+			TaxCategory: dish.TaxedAs(),
+			Notes:       l.Notes,
+		})
+		usage = append(usage, dish.StockUsage(price.Quantity)...)
+	}
+	if len(bad.Fields) > 0 {
+		return nil, bad
+	}
+
+	// Create model
+	order, err := models.NewOrder(lines, p.Notes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tax it
+	if r.taxes != nil {
+		j, err := r.taxes.GetJurisdiction(ctx, r.jurisdictionID)
+		if models.KindOf(err) == models.KindNotFound {
+			return nil, models.Conflict("tax jurisdiction %q is not set up", r.jurisdictionID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := order.ApplyTax(*j); err != nil {
+			return nil, err
+		}
+	}
+
+	// Take it out of stock, last since it cannot be undone
+	if r.inventory != nil {
+		if err := r.inventory.Deplete(ctx, usage); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save model
+	r.local[key{t, order.ID}] = *order
+	return order, nil
+}
+
+func (r *resource) GetOrder(ctx context.Context, id string) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	order, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &order, nil
+}
+
+func (r *resource) ListOrders(ctx context.Context, status models.OrderStatus) ([]models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if status != "" && !status.Valid() {
+		return nil, models.InvalidArgument("invalid query").
+			WithField("status", fmt.Sprintf("unknown order status %q", status))
+	}
+
+	orders := []models.Order{}
+	for k, o := range r.local {
+		if k.tenant == t && (status == "" || o.Status == status) {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := &orders[i], &orders[j]
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+	return orders, nil
+}
+
+func (r *resource) TransitionOrder(ctx context.Context, id string, status models.OrderStatus, version int64) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	order, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	if err := order.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	// Update the copy, with a history of its own so orders returned
+	// earlier never share it
+	order.History = append([]models.StatusChange(nil), order.History...)
+	if err := order.Transition(status, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, id}] = order
+	return &order, nil
+}
@@ -0,0 +1,296 @@
+//go:build integration
+// +build integration
+
+package orders
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/jeffizhungry/polygon/dishes"
+	"github.com/jeffizhungry/polygon/inventory"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/jeffizhungry/polygon/taxes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeString(s string) *string {
+	return &s
+}
+
+func makePrice(amount string) *models.Money {
+	m := models.MustParseMoney(amount, "USD")
+	return &m
+}
+
+func TestIntegrationOrdersHTTP(t *testing.T) {
+	ctx := context.Background()
+	stock := inventory.NewService()
+	menu := dishes.NewService(dishes.WithInventory(stock))
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(menu, WithInventory(stock)))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	coffee, err := menu.CreateDish(ctx, models.DishParams{
+		Name:  makeString("Coffee"),
+		Price: makePrice("3"),
+		ModifierGroups: &[]models.ModifierGroup{{
+			ID: "milk", Name: "Milk", Max: 1,
+			Modifiers: []models.Modifier{{ID: "oat", Name: "Oat", PriceDelta: *makePrice("0.60")}},
+		}},
+	})
+	require.NoError(t, err)
+	cake, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Cake"), Price: makePrice("4.50")})
+	require.NoError(t, err)
+	_, err = stock.SetStock(ctx, models.StockDish, cake.ID, models.Quantity{Amount: 2, Unit: models.UnitEach})
+	require.NoError(t, err)
+
+	// Place
+	order, err := client.PlaceOrder(ctx, models.OrderParams{Lines: []models.OrderLineParams{
+		{DishID: coffee.ID, Configuration: models.Configuration{
+			Quantity:  2,
+			Modifiers: []models.ModifierChoice{{GroupID: "milk", ModifierID: "oat"}},
+		}},
+		{DishID: cake.ID, Notes: "birthday candle"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderPlaced, order.Status)
+	require.Len(t, order.Lines, 2)
+	assert.Equal(t, "Coffee", order.Lines[0].Name)
+	assert.Equal(t, "7.20 USD", order.Lines[0].Price.Total.String())
+	assert.Equal(t, "birthday candle", order.Lines[1].Notes)
+	assert.Equal(t, "11.70 USD", order.Total.String())
+	level, err := stock.GetStock(ctx, models.StockDish, cake.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, level.Quantity.Amount)
+
+	// Lines are snapshots
+	_, err = menu.UpdateDish(ctx, coffee.ID, models.DishParams{Name: makeString("Espresso"), Price: makePrice("4")})
+	require.NoError(t, err)
+	got, err := client.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Coffee", got.Lines[0].Name)
+	assert.Equal(t, "11.70 USD", got.Total.String())
+
+	// Bad lines
+	_, err = client.PlaceOrder(ctx, models.OrderParams{Lines: []models.OrderLineParams{
+		{DishID: "missing"},
+		{DishID: coffee.ID, Configuration: models.Configuration{Quantity: -1}},
+	}})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{
+		{Field: "lines[0].dishId", Message: "unknown dish"},
+		{Field: "lines[1].quantity", Message: "cannot be negative"},
+	}, err.(*models.Error).Fields)
+
+	// Sold out dishes cannot be ordered
+	_, err = client.PlaceOrder(ctx, models.OrderParams{Lines: []models.OrderLineParams{
+		{DishID: cake.ID, Configuration: models.Configuration{Quantity: 2}},
+	}})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	_, err = client.PlaceOrder(ctx, models.OrderParams{Lines: []models.OrderLineParams{{DishID: cake.ID}}})
+	require.NoError(t, err)
+	_, err = client.PlaceOrder(ctx, models.OrderParams{Lines: []models.OrderLineParams{{DishID: cake.ID}}})
+	require.Error(t, err)
+	assert.Equal(t, "Cake cannot be ordered right now: lines[0].dishId unavailable, sold_out", err.Error())
+
+	// Lifecycle
+	order, err = client.TransitionOrder(ctx, order.ID, models.OrderAccepted, order.Version)
+	require.NoError(t, err)
+	_, err = client.TransitionOrder(ctx, order.ID, models.OrderPreparing, 1)
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	_, err = client.TransitionOrder(ctx, order.ID, models.OrderCompleted, 0)
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "status", Message: "must be one of preparing, cancelled"}}, err.(*models.Error).Fields)
+	order, err = client.TransitionOrder(ctx, order.ID, models.OrderPreparing, 0)
+	require.NoError(t, err)
+	assert.Len(t, order.History, 3)
+	assert.False(t, order.StatusAt(models.OrderPreparing).IsZero())
+
+	// List
+	list, err := client.ListOrders(ctx, models.OrderPreparing)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, order.ID, list[0].ID)
+	list, err = client.ListOrders(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+	_, err = client.ListOrders(ctx, "eaten")
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+}
+
+// unknownJurisdictions finds no jurisdiction, with an error of its own
+type unknownJurisdictions struct{}
+
+func (unknownJurisdictions) GetJurisdiction(ctx context.Context, id string) (*models.Jurisdiction, error) {
+	return nil, models.NotFound("no jurisdiction %v", id)
+}
+
+func TestIntegrationOrdersTax(t *testing.T) {
+	ctx := context.Background()
+	menu := dishes.NewService()
+	rules := taxes.NewService()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(menu, WithTaxes(rules, "springfield")))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	burger, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Burger"), Price: makePrice("12")})
+	require.NoError(t, err)
+	alcohol := models.TaxAlcohol
+	beer, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Beer"), Price: makePrice("6"), TaxCategory: &alcohol})
+	require.NoError(t, err)
+	params := models.OrderParams{Lines: []models.OrderLineParams{{DishID: burger.ID}, {DishID: beer.ID}}}
+
+	// Orders cannot be taxed before the jurisdiction is set up
+	_, err = client.PlaceOrder(ctx, params)
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+	_, err = NewService(menu, WithTaxes(unknownJurisdictions{}, "shelbyville")).PlaceOrder(ctx, params)
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+
+	_, err = rules.SetJurisdiction(ctx, models.Jurisdiction{
+		ID:   "springfield",
+		Name: "Springfield",
+		Rates: []models.TaxRate{
+			{ID: "sales", Name: "Sales tax", Percent: "5"},
+			{ID: "liquor", Name: "Liquor tax", Percent: "10", Categories: []models.TaxCategory{models.TaxAlcohol}},
+		},
+	})
+	require.NoError(t, err)
+	order, err := client.PlaceOrder(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaxPreparedFood, order.Lines[0].TaxCategory)
+	assert.Equal(t, models.TaxAlcohol, order.Lines[1].TaxCategory)
+	require.NotNil(t, order.Tax)
+	assert.Equal(t, "18.00 USD", order.Tax.Net.String())
+	assert.Equal(t, "1.50 USD", order.Tax.Tax.String())
+	assert.Equal(t, "19.50 USD", order.Total.String())
+	require.Len(t, order.Tax.Rates, 2)
+	assert.Equal(t, "0.60 USD", order.Tax.Rates[1].Amount.String())
+}
+
+func TestIntegrationOrdersTenants(t *testing.T) {
+	stock := inventory.NewService()
+	menu := dishes.NewService(dishes.WithInventory(stock))
+	rules := taxes.NewService()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(menu, WithInventory(stock), WithTaxes(rules, "springfield")))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	for _, ctx := range []context.Context{bistro, diner} {
+		_, err = rules.SetJurisdiction(ctx, models.Jurisdiction{
+			ID:    "springfield",
+			Name:  "Springfield",
+			Rates: []models.TaxRate{{ID: "sales", Name: "Sales tax", Percent: "5"}},
+		})
+		require.NoError(t, err)
+	}
+	cake, err := menu.CreateDish(bistro, models.DishParams{Name: makeString("Cake"), Price: makePrice("4.50")})
+	require.NoError(t, err)
+	_, err = stock.SetStock(bistro, models.StockDish, cake.ID, models.Quantity{Amount: 2, Unit: models.UnitEach})
+	require.NoError(t, err)
+	params := models.OrderParams{Lines: []models.OrderLineParams{{DishID: cake.ID}}}
+
+	// Orders only take the tenant's own dishes
+	_, err = client.PlaceOrder(diner, params)
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "lines[0].dishId", Message: "unknown dish"}}, err.(*models.Error).Fields)
+	order, err := client.PlaceOrder(bistro, params)
+	require.NoError(t, err)
+	level, err := stock.GetStock(bistro, models.StockDish, cake.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, level.Quantity.Amount)
+
+	// The diner sees none of the bistro's orders
+	_, err = client.GetOrder(diner, order.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.TransitionOrder(diner, order.ID, models.OrderCancelled, 0)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	list, err := client.ListOrders(diner, "")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	got, err := client.GetOrder(bistro, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderPlaced, got.Status)
+
+	// Once tenants are registered, no other tenant is served
+	registry := tenant.Registry{"bistro": {}}
+	registered := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(dishes.NewService(dishes.WithTenants(registry)), WithTenants(registry)))))
+	defer registered.Close()
+	client, err = MakeClientEndpoints(registered.URL)
+	require.NoError(t, err)
+	cafe := tenant.NewContext(context.Background(), "cafe")
+	_, err = client.PlaceOrder(cafe, params)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.ListOrders(cafe, "")
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.ListOrders(context.Background(), "")
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+	list, err = client.ListOrders(bistro, "")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestIntegrationOrdersAuth(t *testing.T) {
+	key, stored := auth.NewAPIKey("kitchen", "bistro", auth.RoleEditor)
+	authenticator := auth.NewAuthenticator(auth.WithAPIKeys([]auth.APIKey{stored}))
+	menu := dishes.NewService()
+	endpoints := MakeServerEndpoints(NewService(menu)).Wrap(func(method string) endpoint.Middleware {
+		return endpoint.Chain(auth.Middleware(method), Policy.Middleware(method))
+	})
+	server := httptest.NewServer(MakeHTTPHandler(endpoints, httptransport.ServerBefore(authenticator.ToContext)))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	kitchen := auth.WithAPIKey(context.Background(), key)
+
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+	cake, err := menu.CreateDish(bistro, models.DishParams{Name: makeString("Cake"), Price: makePrice("4.50")})
+	require.NoError(t, err)
+	pie, err := menu.CreateDish(diner, models.DishParams{Name: makeString("Pie"), Price: makePrice("3")})
+	require.NoError(t, err)
+	theirs, err := NewService(menu).PlaceOrder(diner, models.OrderParams{Lines: []models.OrderLineParams{{DishID: pie.ID}}})
+	require.NoError(t, err)
+
+	// The key acts for the bistro without naming it
+	order, err := client.PlaceOrder(kitchen, models.OrderParams{Lines: []models.OrderLineParams{{DishID: cake.ID}}})
+	require.NoError(t, err)
+	got, err := client.GetOrder(tenant.NewContext(kitchen, "bistro"), order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order.ID, got.ID)
+
+	// And never for the diner
+	_, err = client.GetOrder(kitchen, theirs.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	list, err := client.ListOrders(kitchen, "")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, order.ID, list[0].ID)
+	_, err = client.PlaceOrder(kitchen, models.OrderParams{Lines: []models.OrderLineParams{{DishID: pie.ID}}})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "lines[0].dishId", Message: "unknown dish"}}, err.(*models.Error).Fields)
+	_, err = client.ListOrders(tenant.NewContext(kitchen, "diner"), "")
+	assert.Equal(t, models.KindPermissionDenied, models.KindOf(err))
+
+	req, err := http.NewRequest("GET", server.URL+"/orders", nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.APIKeyHeader, key)
+	req.Header.Set(tenant.Header, "diner")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,260 @@
+// Transport exposes the service endpoints over HTTP.
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// POST    /orders              places an order
+// GET     /orders              lists orders oldest first, optionally only those in status
+// GET     /orders/{id}         retrieves an order
+// PUT     /orders/{id}/status  moves an order into the status {"status": "accepted"}
+//
+// Responses carry the order version in an ETag header. Status changes honour
+// If-Match, and fail with 409 Conflict when the version changed or the order
+// cannot move into the status.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("POST").Path("/orders").Handler(httptransport.NewServer(
+		context.Background(),
+		e.PlaceOrderEndpoint,
+		decodePlaceOrderRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/orders").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListOrdersEndpoint,
+		decodeListOrdersRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/orders/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetOrderEndpoint,
+		decodeGetOrderRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/orders/{id}/status").Handler(httptransport.NewServer(
+		context.Background(),
+		e.TransitionOrderEndpoint,
+		decodeTransitionOrderRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in an orders client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		PlaceOrderEndpoint:      httptransport.NewClient("POST", tgt, encodePlaceOrderRequest, decodePlaceOrderResponse, options...).Endpoint(),
+		GetOrderEndpoint:        httptransport.NewClient("GET", tgt, encodeGetOrderRequest, decodeOrderResponse, options...).Endpoint(),
+		ListOrdersEndpoint:      httptransport.NewClient("GET", tgt, encodeListOrdersRequest, decodeListOrdersResponse, options...).Endpoint(),
+		TransitionOrderEndpoint: httptransport.NewClient("PUT", tgt, encodeTransitionOrderRequest, decodeOrderResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodePlaceOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req placeOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.OrderParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getOrderRequest{ID: id}, nil
+}
+
+func decodeListOrdersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listOrdersRequest{Status: models.OrderStatus(r.URL.Query().Get("status"))}, nil
+}
+
+func decodeTransitionOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Status  models.OrderStatus `json:"status"`
+		Version *int64             `json:"version,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	version, err := etag.ExpectedVersion(r, body.Version)
+	if err != nil {
+		return nil, err
+	}
+	req := transitionOrderRequest{ID: id, Status: body.Status}
+	if version != nil {
+		req.Version = *version
+	}
+	return req, nil
+}
+
+// pathID extracts the {id} path variable
+func pathID(r *http.Request) (string, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return id, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodePlaceOrderRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(placeOrderRequest)
+	urlpath.Append(r.URL, "orders")
+	return httptransport.EncodeJSONRequest(ctx, r, req.OrderParams)
+}
+
+func encodeGetOrderRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getOrderRequest)
+	urlpath.Append(r.URL, "orders", req.ID)
+	return nil
+}
+
+func encodeListOrdersRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(listOrdersRequest)
+	urlpath.Append(r.URL, "orders")
+	if req.Status != "" {
+		q := r.URL.Query()
+		q.Set("status", string(req.Status))
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func encodeTransitionOrderRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(transitionOrderRequest)
+	urlpath.Append(r.URL, "orders", req.ID, "status")
+	etag.SetIfMatch(r, req.Version)
+	return httptransport.EncodeJSONRequest(ctx, r, struct {
+		Status models.OrderStatus `json:"status"`
+	}{req.Status})
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodePlaceOrderResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp placeOrderResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeOrderResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp orderResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListOrdersResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listOrdersResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
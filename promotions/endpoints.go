@@ -0,0 +1,275 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package promotions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the promotion endpoints, see dishes.Endpoints
+type Endpoints struct {
+	CreatePromotionEndpoint endpoint.Endpoint
+	UpdatePromotionEndpoint endpoint.Endpoint
+	DeletePromotionEndpoint endpoint.Endpoint
+	GetPromotionEndpoint    endpoint.Endpoint
+	ListPromotionsEndpoint  endpoint.Endpoint
+	PreviewCartEndpoint     endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in a promotions
+// server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		CreatePromotionEndpoint: MakeCreatePromotionEndpoint(s),
+		UpdatePromotionEndpoint: MakeUpdatePromotionEndpoint(s),
+		DeletePromotionEndpoint: MakeDeletePromotionEndpoint(s),
+		GetPromotionEndpoint:    MakeGetPromotionEndpoint(s),
+		ListPromotionsEndpoint:  MakeListPromotionsEndpoint(s),
+		PreviewCartEndpoint:     MakePreviewCartEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "DeletePromotion".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		CreatePromotionEndpoint: mw("CreatePromotion")(e.CreatePromotionEndpoint),
+		UpdatePromotionEndpoint: mw("UpdatePromotion")(e.UpdatePromotionEndpoint),
+		DeletePromotionEndpoint: mw("DeletePromotion")(e.DeletePromotionEndpoint),
+		GetPromotionEndpoint:    mw("GetPromotion")(e.GetPromotionEndpoint),
+		ListPromotionsEndpoint:  mw("ListPromotions")(e.ListPromotionsEndpoint),
+		PreviewCartEndpoint:     mw("PreviewCart")(e.PreviewCartEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service.
+// Promotions change what customers pay, so only managers set them up.
+var Policy = auth.Policy{
+	"GetPromotion":    auth.RoleViewer,
+	"ListPromotions":  auth.RoleViewer,
+	"PreviewCart":     auth.RoleViewer,
+	"CreatePromotion": auth.RoleManager,
+	"UpdatePromotion": auth.RoleManager,
+	"DeletePromotion": auth.RoleAdmin,
+}
+
+// CreatePromotion implements Service. Primarily useful in a client.
+func (e Endpoints) CreatePromotion(ctx context.Context, p models.PromotionParams) (*models.Promotion, error) {
+	response, err := e.CreatePromotionEndpoint(ctx, createPromotionRequest{PromotionParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(createPromotionResponse)
+	return resp.Promotion, resp.Err
+}
+
+// GetPromotion implements Service. Primarily useful in a client.
+func (e Endpoints) GetPromotion(ctx context.Context, id string) (*models.Promotion, error) {
+	response, err := e.GetPromotionEndpoint(ctx, getPromotionRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(promotionResponse)
+	return resp.Promotion, resp.Err
+}
+
+// UpdatePromotion implements Service. Primarily useful in a client.
+func (e Endpoints) UpdatePromotion(ctx context.Context, id string, p models.PromotionParams) (*models.Promotion, error) {
+	response, err := e.UpdatePromotionEndpoint(ctx, updatePromotionRequest{ID: id, PromotionParams: p})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(promotionResponse)
+	return resp.Promotion, resp.Err
+}
+
+// DeletePromotion implements Service. Primarily useful in a client.
+func (e Endpoints) DeletePromotion(ctx context.Context, id string, version int64) error {
+	response, err := e.DeletePromotionEndpoint(ctx, deletePromotionRequest{ID: id, Version: version})
+	if err != nil {
+		return err
+	}
+	resp := response.(deletePromotionResponse)
+	return resp.Err
+}
+
+// ListPromotions implements Service. Primarily useful in a client.
+func (e Endpoints) ListPromotions(ctx context.Context) ([]models.Promotion, error) {
+	response, err := e.ListPromotionsEndpoint(ctx, listPromotionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listPromotionsResponse)
+	return resp.Promotions, resp.Err
+}
+
+// PreviewCart implements Service. Primarily useful in a client.
+func (e Endpoints) PreviewCart(ctx context.Context, cart models.Cart) (*models.CartPrice, error) {
+	response, err := e.PreviewCartEndpoint(ctx, previewCartRequest{Cart: cart})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(previewCartResponse)
+	return resp.CartPrice, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+// etagHeader exposes the promotion version as a strong entity tag
+func etagHeader(p *models.Promotion) http.Header {
+	if p == nil {
+		return http.Header{}
+	}
+	return etag.Header(p.Version)
+}
+
+type createPromotionRequest struct {
+	models.PromotionParams
+}
+
+type createPromotionResponse struct {
+	*models.Promotion
+	Err error `json:"-"`
+}
+
+func (r createPromotionResponse) error() error { return r.Err }
+
+func (r createPromotionResponse) Headers() http.Header { return etagHeader(r.Promotion) }
+
+// StatusCode reports 201 since a new promotion was created
+func (r createPromotionResponse) StatusCode() int { return http.StatusCreated }
+
+func MakeCreatePromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(createPromotionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		promotion, err := s.CreatePromotion(ctx, req.PromotionParams)
+		resp := createPromotionResponse{Promotion: promotion, Err: err}
+		return resp, nil
+	}
+}
+
+type getPromotionRequest struct {
+	ID string `json:"id"`
+}
+
+// promotionResponse is returned by the endpoints reading or updating a
+// promotion
+type promotionResponse struct {
+	*models.Promotion
+	Err error `json:"-"`
+}
+
+func (r promotionResponse) error() error { return r.Err }
+
+func (r promotionResponse) Headers() http.Header { return etagHeader(r.Promotion) }
+
+func MakeGetPromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(getPromotionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		promotion, err := s.GetPromotion(ctx, req.ID)
+		resp := promotionResponse{Promotion: promotion, Err: err}
+		return resp, nil
+	}
+}
+
+type updatePromotionRequest struct {
+	ID string `json:"id"`
+	models.PromotionParams
+}
+
+func MakeUpdatePromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(updatePromotionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		promotion, err := s.UpdatePromotion(ctx, req.ID, req.PromotionParams)
+		resp := promotionResponse{Promotion: promotion, Err: err}
+		return resp, nil
+	}
+}
+
+type deletePromotionRequest struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+type deletePromotionResponse struct {
+	Err error `json:"-"`
+}
+
+func (r deletePromotionResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing left to return
+func (r deletePromotionResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeDeletePromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(deletePromotionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeletePromotion(ctx, req.ID, req.Version)
+		resp := deletePromotionResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listPromotionsRequest struct{}
+
+type listPromotionsResponse struct {
+	Promotions []models.Promotion `json:"values"`
+	Err        error              `json:"-"`
+}
+
+func (r listPromotionsResponse) error() error { return r.Err }
+
+func MakeListPromotionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if _, ok := request.(listPromotionsRequest); !ok {
+			return nil, errors.New("programmer error")
+		}
+		promotions, err := s.ListPromotions(ctx)
+		resp := listPromotionsResponse{Promotions: promotions, Err: err}
+		return resp, nil
+	}
+}
+
+type previewCartRequest struct {
+	models.Cart
+}
+
+type previewCartResponse struct {
+	*models.CartPrice
+	Err error `json:"-"`
+}
+
+func (r previewCartResponse) error() error { return r.Err }
+
+func MakePreviewCartEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(previewCartRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		price, err := s.PreviewCart(ctx, req.Cart)
+		resp := previewCartResponse{CartPrice: price, Err: err}
+		return resp, nil
+	}
+}
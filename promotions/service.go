@@ -0,0 +1,324 @@
+// Service implements the business logic for promotions
+package promotions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service manages promotions, such as happy hours, buy one get one free
+// deals and coupons, and prices carts after them. Every operation acts for
+// the tenant carried by the context, see tenant.NewContext. Promotions of
+// other tenants are never seen nor applied, and coupon codes only need to be
+// unique within a tenant.
+type Service interface {
+	CreatePromotion(ctx context.Context, p models.PromotionParams) (*models.Promotion, error)
+	GetPromotion(ctx context.Context, id string) (*models.Promotion, error)
+
+	// UpdatePromotion and DeletePromotion fail with a conflict error if the
+	// promotion is no longer at the expected version, see the dishes
+	// service.
+	UpdatePromotion(ctx context.Context, id string, p models.PromotionParams) (*models.Promotion, error)
+	DeletePromotion(ctx context.Context, id string, version int64) error
+
+	// ListPromotions returns every promotion in the order they are tried,
+	// see models.SortPromotions
+	ListPromotions(ctx context.Context) ([]models.Promotion, error)
+
+	// PreviewCart prices every line from the current dish and applies the
+	// promotions running at the cart's time, in the restaurant's time zone,
+	// see models.ApplyPromotions. Nothing is ordered.
+	PreviewCart(ctx context.Context, cart models.Cart) (*models.CartPrice, error)
+}
+
+// Dishes looks up the dishes and categories promotions refer to, it is
+// implemented by dishes.Service
+type Dishes interface {
+	GetDish(ctx context.Context, id string, currency string) (*models.Dish, error)
+	GetCategory(ctx context.Context, id string) (*models.Category, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithLocation sets the restaurant's time zone, UTC by default. Happy hours
+// of a tenant with its own time zone, see tenant.Config, follow that one.
+func WithLocation(loc *time.Location) Option {
+	return func(r *resource) { r.location = loc }
+}
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(dishes Dishes, opts ...Option) Service {
+	r := &resource{
+		local:    make(map[key]models.Promotion),
+		mu:       &sync.RWMutex{},
+		dishes:   dishes,
+		location: time.UTC,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// key identifies a promotion, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	local    map[key]models.Promotion
+	mu       *sync.RWMutex
+	dishes   Dishes
+	location *time.Location
+	tenants  tenant.Registry
+}
+
+// checkConditions verifies every dish and category the promotion qualifies
+// exists
+func (r *resource) checkConditions(ctx context.Context, p *models.Promotion) error {
+	bad := models.InvalidArgument("invalid promotion")
+	for i, id := range p.Conditions.DishIDs {
+		_, err := r.dishes.GetDish(ctx, id, "")
+		if models.KindOf(err) == models.KindNotFound {
+			bad = bad.WithField(fmt.Sprintf("conditions.dishIds[%d]", i), "unknown dish")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i, id := range p.Conditions.CategoryIDs {
+		_, err := r.dishes.GetCategory(ctx, id)
+		if models.KindOf(err) == models.KindNotFound {
+			bad = bad.WithField(fmt.Sprintf("conditions.categoryIds[%d]", i), "unknown category")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(bad.Fields) > 0 {
+		return bad
+	}
+	return nil
+}
+
+// checkCode verifies no other promotion of the tenant has the promotion's
+// coupon code
+func (r *resource) checkCode(t string, p *models.Promotion) error {
+	if p.Code == "" {
+		return nil
+	}
+	for k, other := range r.local {
+		if k.tenant == t && other.ID != p.ID && other.Code == p.Code {
+			return models.Conflict("coupon code %v is taken", p.Code).
+				WithField("code", "already used by "+other.Name)
+		}
+	}
+	return nil
+}
+
+func (r *resource) CreatePromotion(ctx context.Context, p models.PromotionParams) (*models.Promotion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create model
+	promotion := models.NewPromotion(p)
+
+	// Validate
+	if err := promotion.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.checkConditions(ctx, promotion); err != nil {
+		return nil, err
+	}
+	if err := r.checkCode(t, promotion); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, promotion.ID}] = *promotion
+	return promotion, nil
+}
+
+func (r *resource) GetPromotion(ctx context.Context, id string) (*models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	promotion, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &promotion, nil
+}
+
+func (r *resource) UpdatePromotion(ctx context.Context, id string, p models.PromotionParams) (*models.Promotion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	promotion, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	if p.Version != nil {
+		if err := promotion.CheckVersion(*p.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update the copy
+	promotion.Apply(p)
+	promotion.Version++
+	promotion.Updated = time.Now()
+
+	// Validate
+	if err := promotion.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Dishes and categories deleted since are tolerated, unless the
+	// conditions are replaced
+	if p.Conditions != nil {
+		if err := r.checkConditions(ctx, &promotion); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.checkCode(t, &promotion); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	r.local[key{t, id}] = promotion
+	return &promotion, nil
+}
+
+func (r *resource) DeletePromotion(ctx context.Context, id string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	promotion, found := r.local[key{t, id}]
+	if !found {
+		return models.ErrNotFound
+	}
+	if err := promotion.CheckVersion(version); err != nil {
+		return err
+	}
+
+	// Delete model
+	delete(r.local, key{t, id})
+	return nil
+}
+
+func (r *resource) ListPromotions(ctx context.Context) ([]models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.sorted(t), nil
+}
+
+// sorted returns every promotion of the tenant in the order they are tried
+func (r *resource) sorted(t string) []models.Promotion {
+	promotions := []models.Promotion{}
+	for k, p := range r.local {
+		if k.tenant == t {
+			promotions = append(promotions, p)
+		}
+	}
+	models.SortPromotions(promotions)
+	return promotions
+}
+
+func (r *resource) PreviewCart(ctx context.Context, cart models.Cart) (*models.CartPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, config, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if err := (models.OrderParams{Lines: cart.Lines}).Validate(); err != nil {
+		return nil, err
+	}
+	at := cart.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	// Price every line from the current dishes
+	bad := models.InvalidArgument("invalid cart")
+	lines := make([]models.CartLine, 0, len(cart.Lines))
+	for i, l := range cart.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		dish, err := r.dishes.GetDish(ctx, l.DishID, "")
+		if models.KindOf(err) == models.KindNotFound {
+			bad = bad.WithField(field+".dishId", "unknown dish")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		price, err := dish.CalculatePrice(l.Configuration)
+		if e, ok := err.(*models.Error); ok && e.Kind == models.KindInvalidArgument {
+			for _, f := range e.Fields {
+				bad = bad.WithField(field+"."+f.Field, f.Message)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, models.CartLine{
+			DishID:     dish.ID,
+			CategoryID: dish.CategoryID,
+			Name:       dish.Name,
+			Price:      *price,
+		})
+	}
+	if len(bad.Fields) > 0 {
+		return nil, bad
+	}
+
+	// Apply the promotions
+	return models.ApplyPromotions(r.sorted(t), lines, cart.Codes, at.In(config.LocationOr(r.location)))
+}
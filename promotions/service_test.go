@@ -0,0 +1,207 @@
+//go:build integration
+// +build integration
+
+package promotions
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeffizhungry/polygon/dishes"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeString(s string) *string {
+	return &s
+}
+
+func makePrice(amount string) *models.Money {
+	m := models.MustParseMoney(amount, "USD")
+	return &m
+}
+
+func TestIntegrationPromotionsHTTP(t *testing.T) {
+	ctx := context.Background()
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	menu := dishes.NewService()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(menu, WithLocation(nyc)))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	drinks, err := menu.CreateCategory(ctx, models.CategoryParams{Name: makeString("Drinks")})
+	require.NoError(t, err)
+	beer, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Beer"), Price: makePrice("6"), CategoryID: &drinks.ID})
+	require.NoError(t, err)
+	burger, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Burger"), Price: makePrice("14")})
+	require.NoError(t, err)
+
+	// Create
+	happy, err := client.CreatePromotion(ctx, models.PromotionParams{
+		Name: makeString("Happy hour"),
+		Conditions: &models.PromotionConditions{
+			CategoryIDs:  []string{drinks.ID},
+			Availability: []models.Availability{{Days: models.Weekdays, Start: models.NewTimeOfDay(16, 0), End: models.NewTimeOfDay(18, 0)}},
+		},
+		Discount: &models.Discount{Kind: models.DiscountPercent, Percent: "50"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), happy.Version)
+	_, err = client.CreatePromotion(ctx, models.PromotionParams{
+		Name:       makeString("Burger deal"),
+		Conditions: &models.PromotionConditions{DishIDs: []string{"missing"}},
+		Discount:   &models.Discount{Kind: models.DiscountFixed, Amount: makePrice("2")},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "conditions.dishIds[0]", Message: "unknown dish"}}, err.(*models.Error).Fields)
+	coupon, err := client.CreatePromotion(ctx, models.PromotionParams{
+		Name:       makeString("Burger deal"),
+		Code:       makeString("burger2"),
+		Conditions: &models.PromotionConditions{DishIDs: []string{burger.ID}},
+		Discount:   &models.Discount{Kind: models.DiscountFixed, Amount: makePrice("2")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "BURGER2", coupon.Code)
+	_, err = client.CreatePromotion(ctx, models.PromotionParams{
+		Name:     makeString("Copycat"),
+		Code:     makeString("Burger2"),
+		Discount: &models.Discount{Kind: models.DiscountPercent, Percent: "10"},
+	})
+	assert.Equal(t, models.KindConflict, models.KindOf(err))
+
+	// Preview during happy hour, in the restaurant's time zone. Promotions
+	// of the same priority are tried by name.
+	cart := models.Cart{
+		Lines: []models.OrderLineParams{
+			{DishID: beer.ID, Configuration: models.Configuration{Quantity: 2}},
+			{DishID: burger.ID},
+		},
+		Codes: []string{"burger2"},
+		At:    time.Date(2017, 6, 7, 17, 30, 0, 0, nyc),
+	}
+	price, err := client.PreviewCart(ctx, cart)
+	require.NoError(t, err)
+	require.Len(t, price.Discounts, 2)
+	assert.Equal(t, "Burger deal", price.Discounts[0].Name)
+	assert.Equal(t, "2.00 USD off (Burger)", price.Discounts[0].Explanation)
+	assert.Equal(t, "50% off (Beer)", price.Discounts[1].Explanation)
+	assert.Equal(t, "26.00 USD", price.Subtotal.String())
+	assert.Equal(t, "18.00 USD", price.Total.String())
+
+	// Outside happy hour
+	cart.At = time.Date(2017, 6, 7, 17, 30, 0, 0, time.UTC)
+	price, err = client.PreviewCart(ctx, cart)
+	require.NoError(t, err)
+	assert.Len(t, price.Discounts, 1)
+	assert.Equal(t, "not running at Wed 13:30", price.Skipped[0].Reason)
+	assert.Equal(t, "24.00 USD", price.Total.String())
+
+	// Bad carts
+	_, err = client.PreviewCart(ctx, models.Cart{Lines: []models.OrderLineParams{{DishID: "missing"}}})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "lines[0].dishId", Message: "unknown dish"}}, err.(*models.Error).Fields)
+
+	// Update, list and delete
+	priority := 5
+	coupon, err = client.UpdatePromotion(ctx, coupon.ID, models.PromotionParams{Priority: &priority, Version: &coupon.Version})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), coupon.Version)
+	list, err := client.ListPromotions(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, coupon.ID, list[0].ID)
+	assert.Equal(t, models.KindConflict, models.KindOf(client.DeletePromotion(ctx, coupon.ID, 1)))
+	require.NoError(t, client.DeletePromotion(ctx, coupon.ID, 2))
+	_, err = client.GetPromotion(ctx, coupon.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationPromotionsTenants(t *testing.T) {
+	menu := dishes.NewService()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService(menu))))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	burger, err := menu.CreateDish(bistro, models.DishParams{Name: makeString("Burger"), Price: makePrice("14")})
+	require.NoError(t, err)
+	params := models.PromotionParams{
+		Name:       makeString("Burger deal"),
+		Code:       makeString("burger2"),
+		Conditions: &models.PromotionConditions{DishIDs: []string{burger.ID}},
+		Discount:   &models.Discount{Kind: models.DiscountFixed, Amount: makePrice("2")},
+	}
+
+	// Promotions only qualify the tenant's own dishes
+	_, err = client.CreatePromotion(diner, params)
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "conditions.dishIds[0]", Message: "unknown dish"}}, err.(*models.Error).Fields)
+	coupon, err := client.CreatePromotion(bistro, params)
+	require.NoError(t, err)
+
+	// The diner sees none of the bistro's promotions, and may use the same
+	// coupon code
+	_, err = client.GetPromotion(diner, coupon.ID)
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	_, err = client.UpdatePromotion(diner, coupon.ID, models.PromotionParams{Name: makeString("Stolen")})
+	assert.Equal(t, models.KindNotFound, models.KindOf(err))
+	assert.Equal(t, models.KindNotFound, models.KindOf(client.DeletePromotion(diner, coupon.ID, 0)))
+	list, err := client.ListPromotions(diner)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	_, err = client.CreatePromotion(diner, models.PromotionParams{
+		Name:     makeString("Diner deal"),
+		Code:     makeString("burger2"),
+		Discount: &models.Discount{Kind: models.DiscountPercent, Percent: "10"},
+	})
+	require.NoError(t, err)
+
+	// Carts only get the tenant's own promotions
+	price, err := client.PreviewCart(bistro, models.Cart{
+		Lines: []models.OrderLineParams{{DishID: burger.ID}},
+		Codes: []string{"burger2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, price.Discounts, 1)
+	assert.Equal(t, "12.00 USD", price.Total.String())
+	_, err = client.PreviewCart(diner, models.Cart{Lines: []models.OrderLineParams{{DishID: burger.ID}}})
+	assert.Equal(t, models.KindInvalidArgument, models.KindOf(err))
+
+	// Happy hours follow the tenant's own time zone
+	nyc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	registry := tenant.Registry{"bistro": {Location: nyc}, "diner": {}}
+	menu = dishes.NewService(dishes.WithTenants(registry))
+	s := NewService(menu, WithTenants(registry))
+	for _, ctx := range []context.Context{bistro, diner} {
+		beer, err := menu.CreateDish(ctx, models.DishParams{Name: makeString("Beer"), Price: makePrice("6")})
+		require.NoError(t, err)
+		_, err = s.CreatePromotion(ctx, models.PromotionParams{
+			Name: makeString("Happy hour"),
+			Conditions: &models.PromotionConditions{
+				DishIDs:      []string{beer.ID},
+				Availability: []models.Availability{{Days: models.Weekdays, Start: models.NewTimeOfDay(16, 0), End: models.NewTimeOfDay(18, 0)}},
+			},
+			Discount: &models.Discount{Kind: models.DiscountPercent, Percent: "50"},
+		})
+		require.NoError(t, err)
+		price, err := s.PreviewCart(ctx, models.Cart{
+			Lines: []models.OrderLineParams{{DishID: beer.ID}},
+			At:    time.Date(2017, 6, 7, 21, 30, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+		if ctx == bistro {
+			assert.Equal(t, "3.00 USD", price.Total.String(), "17:30 in New York")
+		} else {
+			assert.Equal(t, "6.00 USD", price.Total.String(), "21:30 in UTC")
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Transport exposes the service endpoints over HTTP.
+package promotions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/etag"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// POST    /promotions       creates a promotion
+// GET     /promotions       lists every promotion, in the order they are tried
+// GET     /promotions/{id}  retrieves a promotion
+// PUT     /promotions/{id}  replaces a promotion, name and discount are required
+// PATCH   /promotions/{id}  partially updates a promotion
+// DELETE  /promotions/{id}  deletes a promotion
+// POST    /cart/preview     prices {"lines": [...], "codes": [...]} after promotions
+//
+// Responses carry the promotion version in an ETag header. PUT, PATCH and
+// DELETE honour If-Match and fail with 409 Conflict when it changed.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("POST").Path("/promotions").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CreatePromotionEndpoint,
+		decodeCreatePromotionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/promotions").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListPromotionsEndpoint,
+		decodeListPromotionsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/promotions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetPromotionEndpoint,
+		decodeGetPromotionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/promotions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdatePromotionEndpoint,
+		decodePutPromotionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/promotions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.UpdatePromotionEndpoint,
+		decodePatchPromotionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/promotions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeletePromotionEndpoint,
+		decodeDeletePromotionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/cart/preview").Handler(httptransport.NewServer(
+		context.Background(),
+		e.PreviewCartEndpoint,
+		decodePreviewCartRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in a promotions client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		CreatePromotionEndpoint: httptransport.NewClient("POST", tgt, encodeCreatePromotionRequest, decodeCreatePromotionResponse, options...).Endpoint(),
+		UpdatePromotionEndpoint: httptransport.NewClient("PATCH", tgt, encodeUpdatePromotionRequest, decodePromotionResponse, options...).Endpoint(),
+		DeletePromotionEndpoint: httptransport.NewClient("DELETE", tgt, encodeDeletePromotionRequest, decodeDeletePromotionResponse, options...).Endpoint(),
+		GetPromotionEndpoint:    httptransport.NewClient("GET", tgt, encodeGetPromotionRequest, decodePromotionResponse, options...).Endpoint(),
+		ListPromotionsEndpoint:  httptransport.NewClient("GET", tgt, encodeListPromotionsRequest, decodeListPromotionsResponse, options...).Endpoint(),
+		PreviewCartEndpoint:     httptransport.NewClient("POST", tgt, encodePreviewCartRequest, decodePreviewCartResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeCreatePromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req createPromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.PromotionParams); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+func decodeGetPromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	return getPromotionRequest{ID: id}, nil
+}
+
+func decodePutPromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeUpdatePromotionRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// PUT replaces the whole resource, missing fields are reset
+	if req.Name == nil {
+		return nil, models.InvalidArgument("name is required")
+	}
+	if req.Discount == nil {
+		return nil, models.InvalidArgument("discount is required")
+	}
+	if req.Code == nil {
+		req.Code = new(string)
+	}
+	if req.Conditions == nil {
+		req.Conditions = &models.PromotionConditions{}
+	}
+	if req.Priority == nil {
+		req.Priority = new(int)
+	}
+	if req.Exclusive == nil {
+		req.Exclusive = new(bool)
+	}
+	return req, nil
+}
+
+func decodePatchPromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return decodeUpdatePromotionRequest(r)
+}
+
+// decodeUpdatePromotionRequest decodes the parts shared by PUT and PATCH
+func decodeUpdatePromotionRequest(r *http.Request) (updatePromotionRequest, error) {
+	var req updatePromotionRequest
+	id, err := pathID(r)
+	if err != nil {
+		return req, err
+	}
+	var params models.PromotionParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return req, models.InvalidArgument("malformed request body: %v", err)
+	}
+	params.Version, err = etag.ExpectedVersion(r, params.Version)
+	if err != nil {
+		return req, err
+	}
+	return updatePromotionRequest{ID: id, PromotionParams: params}, nil
+}
+
+func decodeDeletePromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	version, err := etag.ParseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	return deletePromotionRequest{ID: id, Version: version}, nil
+}
+
+func decodeListPromotionsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listPromotionsRequest{}, nil
+}
+
+func decodePreviewCartRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req previewCartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Cart); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	return req, nil
+}
+
+// pathID extracts the {id} path variable
+func pathID(r *http.Request) (string, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return "", ErrBadRouting
+	}
+	return id, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeCreatePromotionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(createPromotionRequest)
+	urlpath.Append(r.URL, "promotions")
+	return httptransport.EncodeJSONRequest(ctx, r, req.PromotionParams)
+}
+
+func encodeGetPromotionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(getPromotionRequest)
+	urlpath.Append(r.URL, "promotions", req.ID)
+	return nil
+}
+
+func encodeUpdatePromotionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(updatePromotionRequest)
+	urlpath.Append(r.URL, "promotions", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.PromotionParams)
+}
+
+func encodeDeletePromotionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(deletePromotionRequest)
+	urlpath.Append(r.URL, "promotions", req.ID)
+	etag.SetIfMatch(r, req.Version)
+	return nil
+}
+
+func encodeListPromotionsRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "promotions")
+	return nil
+}
+
+func encodePreviewCartRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(previewCartRequest)
+	urlpath.Append(r.URL, "cart", "preview")
+	return httptransport.EncodeJSONRequest(ctx, r, req.Cart)
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeCreatePromotionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createPromotionResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodePromotionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp promotionResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeDeletePromotionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp deletePromotionResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListPromotionsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listPromotionsResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodePreviewCartResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp previewCartResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
@@ -0,0 +1,217 @@
+// Endpoint creates endpoints mapping requests and responses to service argument
+// and return values.
+package taxes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Endpoints aggregates the tax endpoints, see dishes.Endpoints
+type Endpoints struct {
+	SetJurisdictionEndpoint    endpoint.Endpoint
+	GetJurisdictionEndpoint    endpoint.Endpoint
+	DeleteJurisdictionEndpoint endpoint.Endpoint
+	ListJurisdictionsEndpoint  endpoint.Endpoint
+	CalculateTaxEndpoint       endpoint.Endpoint
+}
+
+// MakeServerEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the provided service. Useful in a taxes
+// server.
+func MakeServerEndpoints(s Service) Endpoints {
+	return Endpoints{
+		SetJurisdictionEndpoint:    MakeSetJurisdictionEndpoint(s),
+		GetJurisdictionEndpoint:    MakeGetJurisdictionEndpoint(s),
+		DeleteJurisdictionEndpoint: MakeDeleteJurisdictionEndpoint(s),
+		ListJurisdictionsEndpoint:  MakeListJurisdictionsEndpoint(s),
+		CalculateTaxEndpoint:       MakeCalculateTaxEndpoint(s),
+	}
+}
+
+// Wrap returns the endpoints wrapped in middleware, which is made for each
+// endpoint from the name of the Service method it invokes, e.g. "SetJurisdiction".
+// Useful to authenticate requests in a server.
+func (e Endpoints) Wrap(mw func(method string) endpoint.Middleware) Endpoints {
+	return Endpoints{
+		SetJurisdictionEndpoint:    mw("SetJurisdiction")(e.SetJurisdictionEndpoint),
+		GetJurisdictionEndpoint:    mw("GetJurisdiction")(e.GetJurisdictionEndpoint),
+		DeleteJurisdictionEndpoint: mw("DeleteJurisdiction")(e.DeleteJurisdictionEndpoint),
+		ListJurisdictionsEndpoint:  mw("ListJurisdictions")(e.ListJurisdictionsEndpoint),
+		CalculateTaxEndpoint:       mw("CalculateTax")(e.CalculateTaxEndpoint),
+	}
+}
+
+// Policy is the least role allowed to call each method of the service. Tax
+// rates change what customers pay, so only managers set them.
+var Policy = auth.Policy{
+	"GetJurisdiction":    auth.RoleViewer,
+	"ListJurisdictions":  auth.RoleViewer,
+	"CalculateTax":       auth.RoleViewer,
+	"SetJurisdiction":    auth.RoleManager,
+	"DeleteJurisdiction": auth.RoleAdmin,
+}
+
+// SetJurisdiction implements Service. Primarily useful in a client.
+func (e Endpoints) SetJurisdiction(ctx context.Context, j models.Jurisdiction) (*models.Jurisdiction, error) {
+	response, err := e.SetJurisdictionEndpoint(ctx, setJurisdictionRequest{Jurisdiction: j})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(jurisdictionResponse)
+	return resp.Jurisdiction, resp.Err
+}
+
+// GetJurisdiction implements Service. Primarily useful in a client.
+func (e Endpoints) GetJurisdiction(ctx context.Context, id string) (*models.Jurisdiction, error) {
+	response, err := e.GetJurisdictionEndpoint(ctx, jurisdictionRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(jurisdictionResponse)
+	return resp.Jurisdiction, resp.Err
+}
+
+// DeleteJurisdiction implements Service. Primarily useful in a client.
+func (e Endpoints) DeleteJurisdiction(ctx context.Context, id string) error {
+	response, err := e.DeleteJurisdictionEndpoint(ctx, jurisdictionRequest{ID: id})
+	if err != nil {
+		return err
+	}
+	resp := response.(noContentResponse)
+	return resp.Err
+}
+
+// ListJurisdictions implements Service. Primarily useful in a client.
+func (e Endpoints) ListJurisdictions(ctx context.Context) ([]models.Jurisdiction, error) {
+	response, err := e.ListJurisdictionsEndpoint(ctx, listJurisdictionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(listJurisdictionsResponse)
+	return resp.Jurisdictions, resp.Err
+}
+
+// CalculateTax implements Service. Primarily useful in a client.
+func (e Endpoints) CalculateTax(ctx context.Context, jurisdictionID string, lines []models.TaxableLine) (*models.TaxBreakdown, error) {
+	response, err := e.CalculateTaxEndpoint(ctx, calculateTaxRequest{JurisdictionID: jurisdictionID, Lines: lines})
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(calculateTaxResponse)
+	return resp.TaxBreakdown, resp.Err
+}
+
+// Translate request payloads to service arguments and
+// services return values into response payloads.
+
+type setJurisdictionRequest struct {
+	models.Jurisdiction
+}
+
+type jurisdictionRequest struct {
+	ID string
+}
+
+type jurisdictionResponse struct {
+	*models.Jurisdiction
+	Err error `json:"-"`
+}
+
+func (r jurisdictionResponse) error() error { return r.Err }
+
+// noContentResponse is returned by endpoints with nothing to return
+type noContentResponse struct {
+	Err error `json:"-"`
+}
+
+func (r noContentResponse) error() error { return r.Err }
+
+// StatusCode reports 204 since there is nothing to return
+func (r noContentResponse) StatusCode() int { return http.StatusNoContent }
+
+func MakeSetJurisdictionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(setJurisdictionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		j, err := s.SetJurisdiction(ctx, req.Jurisdiction)
+		resp := jurisdictionResponse{Jurisdiction: j, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeGetJurisdictionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(jurisdictionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		j, err := s.GetJurisdiction(ctx, req.ID)
+		resp := jurisdictionResponse{Jurisdiction: j, Err: err}
+		return resp, nil
+	}
+}
+
+func MakeDeleteJurisdictionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(jurisdictionRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		err = s.DeleteJurisdiction(ctx, req.ID)
+		resp := noContentResponse{Err: err}
+		return resp, nil
+	}
+}
+
+type listJurisdictionsRequest struct{}
+
+type listJurisdictionsResponse struct {
+	Jurisdictions []models.Jurisdiction `json:"jurisdictions"`
+	Err           error                 `json:"-"`
+}
+
+func (r listJurisdictionsResponse) error() error { return r.Err }
+
+func MakeListJurisdictionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		_, ok := request.(listJurisdictionsRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		jurisdictions, err := s.ListJurisdictions(ctx)
+		resp := listJurisdictionsResponse{Jurisdictions: jurisdictions, Err: err}
+		return resp, nil
+	}
+}
+
+type calculateTaxRequest struct {
+	JurisdictionID string               `json:"-"`
+	Lines          []models.TaxableLine `json:"lines"`
+}
+
+type calculateTaxResponse struct {
+	*models.TaxBreakdown
+	Err error `json:"-"`
+}
+
+func (r calculateTaxResponse) error() error { return r.Err }
+
+func MakeCalculateTaxEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(calculateTaxRequest)
+		if !ok {
+			return nil, errors.New("programmer error")
+		}
+		b, err := s.CalculateTax(ctx, req.JurisdictionID, req.Lines)
+		resp := calculateTaxResponse{TaxBreakdown: b, Err: err}
+		return resp, nil
+	}
+}
@@ -0,0 +1,153 @@
+// Service implements the business logic for taxes
+package taxes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+// Service keeps the tax rules of every jurisdiction the restaurant trades in
+// and levies them, see models.Jurisdiction.CalculateTax. Orders are taxed
+// in the restaurant's jurisdiction, see orders.WithTaxes.
+//
+// Every operation acts for the tenant carried by the context, see
+// tenant.NewContext. Tenants set up their own jurisdictions and never see
+// those of other tenants.
+type Service interface {
+
+	// SetJurisdiction creates or replaces a jurisdiction's tax rules
+	SetJurisdiction(ctx context.Context, j models.Jurisdiction) (*models.Jurisdiction, error)
+
+	GetJurisdiction(ctx context.Context, id string) (*models.Jurisdiction, error)
+
+	DeleteJurisdiction(ctx context.Context, id string) error
+
+	// ListJurisdictions returns every jurisdiction ordered by ID
+	ListJurisdictions(ctx context.Context) ([]models.Jurisdiction, error)
+
+	// CalculateTax itemizes the tax a jurisdiction levies on the lines,
+	// e.g. to preview a receipt
+	CalculateTax(ctx context.Context, jurisdictionID string, lines []models.TaxableLine) (*models.TaxBreakdown, error)
+}
+
+// Option configures the service returned by NewService
+type Option func(*resource)
+
+// WithTenants restricts the service to the registered tenants, requests for
+// any other tenant, or for none, are rejected, see tenant.Registry
+func WithTenants(tenants tenant.Registry) Option {
+	return func(r *resource) { r.tenants = tenants }
+}
+
+func NewService(opts ...Option) Service {
+	r := &resource{
+		local: make(map[key]models.Jurisdiction),
+		mu:    &sync.RWMutex{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// key identifies a jurisdiction, IDs are only unique within a tenant
+type key struct {
+	tenant string
+	id     string
+}
+
+type resource struct {
+	local   map[key]models.Jurisdiction
+	mu      *sync.RWMutex
+	tenants tenant.Registry
+}
+
+func (r *resource) SetJurisdiction(ctx context.Context, j models.Jurisdiction) (*models.Jurisdiction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Save model
+	j.Updated = time.Now()
+	r.local[key{t, j.ID}] = j
+	return &j, nil
+}
+
+func (r *resource) GetJurisdiction(ctx context.Context, id string) (*models.Jurisdiction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get model
+	j, found := r.local[key{t, id}]
+	if !found {
+		return nil, models.ErrNotFound
+	}
+	return &j, nil
+}
+
+func (r *resource) DeleteJurisdiction(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if it exists
+	if _, found := r.local[key{t, id}]; !found {
+		return models.ErrNotFound
+	}
+
+	// Delete model
+	delete(r.local, key{t, id})
+	return nil
+}
+
+func (r *resource) ListJurisdictions(ctx context.Context) ([]models.Jurisdiction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, _, err := r.tenants.Lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jurisdictions := []models.Jurisdiction{}
+	for k, j := range r.local {
+		if k.tenant == t {
+			jurisdictions = append(jurisdictions, j)
+		}
+	}
+	sort.Slice(jurisdictions, func(i, j int) bool {
+		return jurisdictions[i].ID < jurisdictions[j].ID
+	})
+	return jurisdictions, nil
+}
+
+func (r *resource) CalculateTax(ctx context.Context, jurisdictionID string, lines []models.TaxableLine) (*models.TaxBreakdown, error) {
+	j, err := r.GetJurisdiction(ctx, jurisdictionID)
+	if err != nil {
+		return nil, err
+	}
+	return j.CalculateTax(lines)
+}
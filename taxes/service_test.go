@@ -0,0 +1,111 @@
+//go:build integration
+// +build integration
+
+package taxes
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationTaxesHTTP(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+
+	// Set
+	nyc, err := client.SetJurisdiction(ctx, models.Jurisdiction{
+		ID:   "us-ny-nyc",
+		Name: "New York City",
+		Rates: []models.TaxRate{
+			{ID: "state", Name: "New York State", Percent: "4"},
+			{ID: "city", Name: "New York City", Percent: "4.5"},
+			{ID: "mctd", Name: "Metropolitan Commuter Transportation District", Percent: "0.375"},
+		},
+	})
+	require.NoError(t, err)
+	assert.False(t, nyc.Updated.IsZero())
+	_, err = client.SetJurisdiction(ctx, models.Jurisdiction{ID: "us-nj", Name: "New Jersey", Rates: []models.TaxRate{
+		{ID: "state", Percent: "6.625", Categories: []models.TaxCategory{"candy"}},
+	}})
+	require.Error(t, err)
+	assert.Equal(t, []models.FieldError{{Field: "rates[0].categories[0]", Message: `unknown tax category "candy"`}}, err.(*models.Error).Fields)
+	_, err = client.SetJurisdiction(ctx, models.Jurisdiction{ID: "us-nj", Name: "New Jersey", Rates: []models.TaxRate{
+		{ID: "state", Percent: "6.625", Categories: []models.TaxCategory{models.TaxPreparedFood, models.TaxAlcohol}},
+	}})
+	require.NoError(t, err)
+
+	// Calculate
+	b, err := client.CalculateTax(ctx, "us-ny-nyc", []models.TaxableLine{
+		{Amount: models.MustParseMoney("24", "USD")},
+		{Category: models.TaxAlcohol, Amount: models.MustParseMoney("12", "USD")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "us-ny-nyc", b.JurisdictionID)
+	assert.Equal(t, "3.20 USD", b.Tax.String())
+	assert.Equal(t, "39.20 USD", b.Gross.String())
+	require.Len(t, b.Rates, 3)
+	assert.Equal(t, "0.14 USD", b.Rates[2].Amount.String())
+	_, err = client.CalculateTax(ctx, "us-ca", []models.TaxableLine{{Amount: models.MustParseMoney("1", "USD")}})
+	assert.Equal(t, models.ErrNotFound, err)
+
+	// List and delete
+	list, err := client.ListJurisdictions(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "us-nj", list[0].ID)
+	require.NoError(t, client.DeleteJurisdiction(ctx, "us-nj"))
+	_, err = client.GetJurisdiction(ctx, "us-nj")
+	assert.Equal(t, models.ErrNotFound, err)
+}
+
+func TestIntegrationTaxesTenants(t *testing.T) {
+	server := httptest.NewServer(MakeHTTPHandler(MakeServerEndpoints(NewService())))
+	defer server.Close()
+	client, err := MakeClientEndpoints(server.URL)
+	require.NoError(t, err)
+	bistro := tenant.NewContext(context.Background(), "bistro")
+	diner := tenant.NewContext(context.Background(), "diner")
+
+	lines := []models.TaxableLine{{Amount: models.MustParseMoney("10", "USD")}}
+	for _, tc := range []struct {
+		ctx     context.Context
+		percent string
+	}{
+		{bistro, "8"},
+		{diner, "5"},
+	} {
+		_, err := client.SetJurisdiction(tc.ctx, models.Jurisdiction{
+			ID:    "springfield",
+			Name:  "Springfield",
+			Rates: []models.TaxRate{{ID: "sales", Name: "Sales tax", Percent: tc.percent}},
+		})
+		require.NoError(t, err)
+	}
+
+	// Every tenant has its own rules for the same jurisdiction
+	b, err := client.CalculateTax(bistro, "springfield", lines)
+	require.NoError(t, err)
+	assert.Equal(t, "0.80 USD", b.Tax.String())
+	b, err = client.CalculateTax(diner, "springfield", lines)
+	require.NoError(t, err)
+	assert.Equal(t, "0.50 USD", b.Tax.String())
+
+	// And cannot see nor delete the others'
+	require.NoError(t, client.DeleteJurisdiction(diner, "springfield"))
+	_, err = client.GetJurisdiction(diner, "springfield")
+	assert.Equal(t, models.ErrNotFound, err)
+	_, err = client.GetJurisdiction(bistro, "springfield")
+	require.NoError(t, err)
+	list, err := client.ListJurisdictions(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,251 @@
+// Transport exposes the service endpoints over HTTP.
+package taxes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/jeffizhungry/polygon/lib/auth"
+	"github.com/jeffizhungry/polygon/lib/problem"
+	"github.com/jeffizhungry/polygon/lib/tenant"
+	"github.com/jeffizhungry/polygon/lib/urlpath"
+	"github.com/jeffizhungry/polygon/models"
+)
+
+var (
+	// ErrBadRouting is returned when an expected path variable is missing.
+	// It always indicates programmer error.
+	ErrBadRouting = models.InvalidArgument("inconsistent mapping between route and handler (programmer error)")
+)
+
+// MakeHTTPHandler mounts all of the service endpoints into an http.Handler.
+//
+// GET     /jurisdictions            lists every jurisdiction
+// GET     /jurisdictions/{id}       retrieves a jurisdiction
+// PUT     /jurisdictions/{id}       creates or replaces a jurisdiction
+// DELETE  /jurisdictions/{id}       removes a jurisdiction
+// POST    /jurisdictions/{id}/tax   itemizes the tax on {"lines": [...]}
+//
+// The ID in the path takes precedence over any ID in the body.
+//
+// The options given apply to every route, e.g. to authenticate requests.
+func MakeHTTPHandler(e Endpoints, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().UseEncodedPath()
+	options = append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(problem.ServerErrorEncoder),
+		httptransport.ServerBefore(tenant.ToContext),
+	}, options...)
+
+	r.Methods("GET").Path("/jurisdictions").Handler(httptransport.NewServer(
+		context.Background(),
+		e.ListJurisdictionsEndpoint,
+		decodeListJurisdictionsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/jurisdictions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.GetJurisdictionEndpoint,
+		decodeJurisdictionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/jurisdictions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.SetJurisdictionEndpoint,
+		decodeSetJurisdictionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/jurisdictions/{id}").Handler(httptransport.NewServer(
+		context.Background(),
+		e.DeleteJurisdictionEndpoint,
+		decodeJurisdictionRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/jurisdictions/{id}/tax").Handler(httptransport.NewServer(
+		context.Background(),
+		e.CalculateTaxEndpoint,
+		decodeCalculateTaxRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+// MakeClientEndpoints returns an Endpoints struct where each endpoint invokes
+// the corresponding method on the remote instance, via a transport/http.Client.
+// Useful in a taxes client.
+func MakeClientEndpoints(instance string) (Endpoints, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	tgt.Path = strings.TrimSuffix(tgt.Path, "/")
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tenant.ToHTTP, auth.ToHTTP),
+	}
+
+	return Endpoints{
+		SetJurisdictionEndpoint:    httptransport.NewClient("PUT", tgt, encodeSetJurisdictionRequest, decodeJurisdictionResponse, options...).Endpoint(),
+		GetJurisdictionEndpoint:    httptransport.NewClient("GET", tgt, encodeJurisdictionRequest, decodeJurisdictionResponse, options...).Endpoint(),
+		DeleteJurisdictionEndpoint: httptransport.NewClient("DELETE", tgt, encodeJurisdictionRequest, decodeNoContentResponse, options...).Endpoint(),
+		ListJurisdictionsEndpoint:  httptransport.NewClient("GET", tgt, encodeListJurisdictionsRequest, decodeListJurisdictionsResponse, options...).Endpoint(),
+		CalculateTaxEndpoint:       httptransport.NewClient("POST", tgt, encodeCalculateTaxRequest, decodeCalculateTaxResponse, options...).Endpoint(),
+	}, nil
+}
+
+/**************************************
+ * Server decoders
+ *	- translate http requests into
+ *	  endpoint requests
+ *************************************/
+
+func decodeListJurisdictionsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listJurisdictionsRequest{}, nil
+}
+
+func decodeJurisdictionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	return jurisdictionRequest{ID: id}, nil
+}
+
+func decodeSetJurisdictionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	var req setJurisdictionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Jurisdiction); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	req.ID = id
+	return req, nil
+}
+
+func decodeCalculateTaxRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := urlpath.Var(r, "id")
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	var req calculateTaxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, models.InvalidArgument("malformed request body: %v", err)
+	}
+	req.JurisdictionID = id
+	return req, nil
+}
+
+/**************************************
+ * Server encoders
+ *	- translate endpoint responses into
+ *	  http responses
+ *************************************/
+
+// errorer is implemented by all concrete response types that may contain
+// errors, see dishes.errorer
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client. Responses that implement httptransport.StatusCoder choose their own
+// success status code.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		problem.ServerErrorEncoder(ctx, e.error(), w)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+/**************************************
+ * Client encoders
+ *	- translate endpoint requests into
+ *	  http requests
+ *************************************/
+
+func encodeListJurisdictionsRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	urlpath.Append(r.URL, "jurisdictions")
+	return nil
+}
+
+func encodeJurisdictionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(jurisdictionRequest)
+	urlpath.Append(r.URL, "jurisdictions", req.ID)
+	return nil
+}
+
+func encodeSetJurisdictionRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(setJurisdictionRequest)
+	urlpath.Append(r.URL, "jurisdictions", req.ID)
+	return httptransport.EncodeJSONRequest(ctx, r, req.Jurisdiction)
+}
+
+func encodeCalculateTaxRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(calculateTaxRequest)
+	urlpath.Append(r.URL, "jurisdictions", req.JurisdictionID, "tax")
+	return httptransport.EncodeJSONRequest(ctx, r, req)
+}
+
+/**************************************
+ * Client decoders
+ *	- translate http responses into
+ *	  endpoint responses
+ *************************************/
+
+func decodeJurisdictionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp jurisdictionResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeListJurisdictionsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listJurisdictionsResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeNoContentResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp noContentResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func decodeCalculateTaxResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp calculateTaxResponse
+	if err := decodeClientResponse(r, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeClientResponse decodes a successful response body into v, or
+// translates an error response back into the error the service returned.
+func decodeClientResponse(r *http.Response, v interface{}) error {
+	if r.StatusCode >= 300 {
+		return problem.DecodeError(r)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}